@@ -0,0 +1,488 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/gskll/chirpy2/internal/audit"
+	"github.com/gskll/chirpy2/internal/auth"
+	"github.com/gskll/chirpy2/internal/database"
+	"github.com/gskll/chirpy2/internal/storage"
+	"github.com/gskll/chirpy2/internal/user"
+)
+
+const userUsage = `Usage: chirpy user <action> [flags] [args]
+
+  create -email <email> [-password <password>] [-red]
+                              create a user, a password is generated if not given
+  list [-limit n] [-offset n] list users, oldest first
+  show <id|email>             show a user with their chirp and session counts
+  delete <id|email>           delete a user with their chirps and tokens
+  set-red <id|email> [true|false]
+                              grant or remove Chirpy Red, default true
+  reset-password [-password <password>] <id|email>
+                              set a new password and revoke refresh tokens
+  set-role <id|email> user|moderator|admin
+                              change a user's role
+`
+
+const chirpUsage = `Usage: chirpy chirp delete [flags] <chirp id>
+`
+
+const tokensUsage = `Usage: chirpy tokens revoke -user <id|email> [flags]
+`
+
+func userCommand(args []string) {
+	action, args := splitAction(args)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	switch action {
+	case "create":
+		userCreate(ctx, args)
+	case "list":
+		userList(ctx, args)
+	case "show":
+		userShow(ctx, args)
+	case "delete":
+		userDelete(ctx, args)
+	case "set-red":
+		userSetRed(ctx, args)
+	case "reset-password":
+		userResetPassword(ctx, args)
+	case "set-role":
+		userSetRole(ctx, args)
+	default:
+		fmt.Fprint(os.Stderr, userUsage)
+		os.Exit(2)
+	}
+}
+
+func userCreate(ctx context.Context, args []string) {
+	var (
+		email    string
+		password string
+		red      bool
+	)
+	_, sqlDB, queries := connect(ctx, args, func(fs *flag.FlagSet) {
+		fs.StringVar(&email, "email", "", "email of the new user")
+		fs.StringVar(&password, "password", "", "password, generated if empty")
+		fs.BoolVar(&red, "red", false, "grant Chirpy Red")
+	})
+	if email == "" {
+		fmt.Fprint(os.Stderr, userUsage)
+		os.Exit(2)
+	}
+
+	generated := password == ""
+	if generated {
+		password = generatePassword()
+	}
+	hashedPassword, err := auth.HashPassword(password)
+	if err != nil {
+		fatal("hash password", err)
+	}
+
+	tx, err := sqlDB.BeginTx(ctx, nil)
+	if err != nil {
+		fatal("begin transaction", err)
+	}
+	defer tx.Rollback()
+	qtx := queries.WithTx(tx)
+
+	user, err := qtx.CreateUser(ctx, database.CreateUserParams{Email: email, HashedPassword: hashedPassword})
+	if err != nil {
+		fatal("create user", err)
+	}
+	if red {
+		err := qtx.SetUserChirpyRed(ctx, database.SetUserChirpyRedParams{IsChirpyRed: true, ID: user.ID})
+		if err != nil {
+			fatal("grant chirpy red", err)
+		}
+		err = audit.Record(ctx, qtx, audit.Event{
+			Action:       audit.AdminUserChirpyRed,
+			TargetUserID: user.ID,
+			Detail:       map[string]any{"is_chirpy_red": true, "source": "cli"},
+		})
+		if err != nil {
+			fatal("record audit event", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		fatal("commit", err)
+	}
+
+	fmt.Printf("created user %s %s\n", user.ID, user.Email)
+	if generated {
+		fmt.Printf("password: %s\n", password)
+	}
+}
+
+func userList(ctx context.Context, args []string) {
+	var limit, offset int
+	_, _, queries := connect(ctx, args, func(fs *flag.FlagSet) {
+		fs.IntVar(&limit, "limit", 50, "max users to list")
+		fs.IntVar(&offset, "offset", 0, "users to skip")
+	})
+
+	users, err := queries.ListUsers(ctx, database.ListUsersParams{Limit: int32(limit), Offset: int32(offset)})
+	if err != nil {
+		fatal("list users", err)
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tEMAIL\tROLE\tCHIRPY RED\tSUSPENDED\tCREATED AT")
+	for _, user := range users {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%t\t%t\t%s\n",
+			user.ID, user.Email, user.Role, user.IsChirpyRed, user.SuspendedAt.Valid, user.CreatedAt.Format(time.RFC3339))
+	}
+	tw.Flush()
+}
+
+func userShow(ctx context.Context, args []string) {
+	conf, _, queries := connect(ctx, args, nil)
+	user := lookupUser(ctx, queries, singleArg(conf.Args(), userUsage))
+
+	chirps, err := queries.CountChirpsByAuthor(ctx, user.ID)
+	if err != nil {
+		fatal("count chirps", err)
+	}
+	sessions, err := queries.CountActiveRefreshTokens(ctx, user.ID)
+	if err != nil {
+		fatal("count refresh tokens", err)
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "id:\t%s\n", user.ID)
+	fmt.Fprintf(tw, "email:\t%s\n", user.Email)
+	fmt.Fprintf(tw, "chirpy red:\t%t\n", user.IsChirpyRed)
+	fmt.Fprintf(tw, "role:\t%s\n", user.Role)
+	if user.SuspendedAt.Valid {
+		fmt.Fprintf(tw, "suspended at:\t%s (%s)\n", user.SuspendedAt.Time.Format(time.RFC3339), user.SuspendedReason.String)
+	}
+	fmt.Fprintf(tw, "created at:\t%s\n", user.CreatedAt.Format(time.RFC3339))
+	fmt.Fprintf(tw, "updated at:\t%s\n", user.UpdatedAt.Format(time.RFC3339))
+	fmt.Fprintf(tw, "chirps:\t%d\n", chirps)
+	fmt.Fprintf(tw, "active sessions:\t%d\n", sessions)
+	tw.Flush()
+}
+
+func userDelete(ctx context.Context, args []string) {
+	conf, sqlDB, queries := connect(ctx, args, nil)
+	user := lookupUser(ctx, queries, singleArg(conf.Args(), userUsage))
+	store := openStorage(conf)
+
+	tx, err := sqlDB.BeginTx(ctx, nil)
+	if err != nil {
+		fatal("begin transaction", err)
+	}
+	defer tx.Rollback()
+	qtx := queries.WithTx(tx)
+
+	mediaKeys, err := qtx.ListUserMediaKeys(ctx, user.ID)
+	if err != nil {
+		fatal("list media", err)
+	}
+	if _, err := qtx.DeleteUser(ctx, user.ID); err != nil {
+		fatal("delete user", err)
+	}
+	err = audit.Record(ctx, qtx, audit.Event{
+		Action:       audit.AdminUserDelete,
+		TargetUserID: user.ID,
+		Detail:       map[string]string{"email": user.Email, "source": "cli"},
+	})
+	if err != nil {
+		fatal("record audit event", err)
+	}
+	if err := tx.Commit(); err != nil {
+		fatal("commit", err)
+	}
+	deleteBlobs(ctx, store, mediaKeys)
+	fmt.Printf("deleted user %s %s\n", user.ID, user.Email)
+}
+
+func userSetRed(ctx context.Context, args []string) {
+	conf, sqlDB, queries := connect(ctx, args, nil)
+
+	rest := conf.Args()
+	if len(rest) < 1 || len(rest) > 2 {
+		fmt.Fprint(os.Stderr, userUsage)
+		os.Exit(2)
+	}
+	red := true
+	if len(rest) == 2 {
+		var err error
+		if red, err = strconv.ParseBool(rest[1]); err != nil {
+			fmt.Fprint(os.Stderr, userUsage)
+			os.Exit(2)
+		}
+	}
+	user := lookupUser(ctx, queries, rest[0])
+
+	tx, err := sqlDB.BeginTx(ctx, nil)
+	if err != nil {
+		fatal("begin transaction", err)
+	}
+	defer tx.Rollback()
+	qtx := queries.WithTx(tx)
+
+	err = qtx.SetUserChirpyRed(ctx, database.SetUserChirpyRedParams{IsChirpyRed: red, ID: user.ID})
+	if err != nil {
+		fatal("set chirpy red", err)
+	}
+	err = audit.Record(ctx, qtx, audit.Event{
+		Action:       audit.AdminUserChirpyRed,
+		TargetUserID: user.ID,
+		Detail:       map[string]any{"is_chirpy_red": red, "source": "cli"},
+	})
+	if err != nil {
+		fatal("record audit event", err)
+	}
+	if err := tx.Commit(); err != nil {
+		fatal("commit", err)
+	}
+	fmt.Printf("set chirpy red to %t for %s\n", red, user.Email)
+}
+
+func userSetRole(ctx context.Context, args []string) {
+	conf, sqlDB, queries := connect(ctx, args, nil)
+
+	rest := conf.Args()
+	if len(rest) != 2 || !user.ValidRole(rest[1]) {
+		fmt.Fprint(os.Stderr, userUsage)
+		os.Exit(2)
+	}
+	target, role := lookupUser(ctx, queries, rest[0]), rest[1]
+
+	tx, err := sqlDB.BeginTx(ctx, nil)
+	if err != nil {
+		fatal("begin transaction", err)
+	}
+	defer tx.Rollback()
+	qtx := queries.WithTx(tx)
+
+	if err := qtx.SetUserRole(ctx, database.SetUserRoleParams{Role: role, ID: target.ID}); err != nil {
+		fatal("set role", err)
+	}
+	err = audit.Record(ctx, qtx, audit.Event{
+		Action:       audit.AdminUserRole,
+		TargetUserID: target.ID,
+		Detail:       map[string]string{"from": target.Role, "to": role, "source": "cli"},
+	})
+	if err != nil {
+		fatal("record audit event", err)
+	}
+	if err := tx.Commit(); err != nil {
+		fatal("commit", err)
+	}
+	fmt.Printf("set role of %s to %s\n", target.Email, role)
+}
+
+func userResetPassword(ctx context.Context, args []string) {
+	var password string
+	conf, sqlDB, queries := connect(ctx, args, func(fs *flag.FlagSet) {
+		fs.StringVar(&password, "password", "", "new password, generated if empty")
+	})
+	user := lookupUser(ctx, queries, singleArg(conf.Args(), userUsage))
+
+	generated := password == ""
+	if generated {
+		password = generatePassword()
+	}
+	hashedPassword, err := auth.HashPassword(password)
+	if err != nil {
+		fatal("hash password", err)
+	}
+
+	tx, err := sqlDB.BeginTx(ctx, nil)
+	if err != nil {
+		fatal("begin transaction", err)
+	}
+	defer tx.Rollback()
+	qtx := queries.WithTx(tx)
+
+	err = qtx.UpdateUserPassword(ctx, database.UpdateUserPasswordParams{HashedPassword: hashedPassword, ID: user.ID})
+	if err != nil {
+		fatal("update password", err)
+	}
+	revoked, err := qtx.RevokeUserRefreshTokens(ctx, user.ID)
+	if err != nil {
+		fatal("revoke refresh tokens", err)
+	}
+	err = audit.Record(ctx, qtx, audit.Event{
+		Action:       audit.UserPasswordChange,
+		TargetUserID: user.ID,
+		Detail:       map[string]any{"revoked_tokens": revoked, "source": "cli"},
+	})
+	if err != nil {
+		fatal("record audit event", err)
+	}
+	if err := tx.Commit(); err != nil {
+		fatal("commit", err)
+	}
+
+	fmt.Printf("reset password for %s, revoked %d refresh tokens\n", user.Email, revoked)
+	if generated {
+		fmt.Printf("password: %s\n", password)
+	}
+}
+
+func chirpCommand(args []string) {
+	action, args := splitAction(args)
+	if action != "delete" {
+		fmt.Fprint(os.Stderr, chirpUsage)
+		os.Exit(2)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	conf, sqlDB, queries := connect(ctx, args, nil)
+	chirpID, err := uuid.Parse(singleArg(conf.Args(), chirpUsage))
+	if err != nil {
+		fatal("invalid chirp id", err)
+	}
+
+	chirp, err := queries.GetChirp(ctx, chirpID)
+	if errors.Is(err, sql.ErrNoRows) {
+		fatal("delete chirp", fmt.Errorf("no chirp %s", chirpID))
+	}
+	if err != nil {
+		fatal("get chirp", err)
+	}
+
+	store := openStorage(conf)
+
+	tx, err := sqlDB.BeginTx(ctx, nil)
+	if err != nil {
+		fatal("begin transaction", err)
+	}
+	defer tx.Rollback()
+	qtx := queries.WithTx(tx)
+
+	mediaKeys, err := qtx.ListChirpMediaKeys(ctx, chirp.ID)
+	if err != nil {
+		fatal("list media", err)
+	}
+	if err := qtx.DeleteChirp(ctx, chirp.ID); err != nil {
+		fatal("delete chirp", err)
+	}
+	err = audit.Record(ctx, qtx, audit.Event{
+		Action:       audit.ChirpDelete,
+		TargetUserID: chirp.UserID,
+		Detail:       map[string]any{"chirp_id": chirp.ID, "source": "cli"},
+	})
+	if err != nil {
+		fatal("record audit event", err)
+	}
+	if err := tx.Commit(); err != nil {
+		fatal("commit", err)
+	}
+	deleteBlobs(ctx, store, mediaKeys)
+	fmt.Printf("deleted chirp %s by %s\n", chirp.ID, chirp.UserID)
+}
+
+func tokensCommand(args []string) {
+	action, args := splitAction(args)
+	if action != "revoke" {
+		fmt.Fprint(os.Stderr, tokensUsage)
+		os.Exit(2)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	var ref string
+	_, sqlDB, queries := connect(ctx, args, func(fs *flag.FlagSet) {
+		fs.StringVar(&ref, "user", "", "id or email of the user")
+	})
+	if ref == "" {
+		fmt.Fprint(os.Stderr, tokensUsage)
+		os.Exit(2)
+	}
+	user := lookupUser(ctx, queries, ref)
+
+	tx, err := sqlDB.BeginTx(ctx, nil)
+	if err != nil {
+		fatal("begin transaction", err)
+	}
+	defer tx.Rollback()
+	qtx := queries.WithTx(tx)
+
+	revoked, err := qtx.RevokeUserRefreshTokens(ctx, user.ID)
+	if err != nil {
+		fatal("revoke refresh tokens", err)
+	}
+	err = audit.Record(ctx, qtx, audit.Event{
+		Action:       audit.AuthTokenRevoke,
+		TargetUserID: user.ID,
+		Detail:       map[string]any{"revoked_tokens": revoked, "source": "cli"},
+	})
+	if err != nil {
+		fatal("record audit event", err)
+	}
+	if err := tx.Commit(); err != nil {
+		fatal("commit", err)
+	}
+	fmt.Printf("revoked %d refresh tokens for %s\n", revoked, user.Email)
+}
+
+// lookupUser finds a user by id or email and exits if there isn't one.
+func lookupUser(ctx context.Context, queries *database.Queries, ref string) database.User {
+	var (
+		user database.User
+		err  error
+	)
+	if id, parseErr := uuid.Parse(ref); parseErr == nil {
+		user, err = queries.GetUser(ctx, id)
+	} else {
+		user, err = queries.GetUserByEmail(ctx, ref)
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		fatal("find user", fmt.Errorf("no user %q", ref))
+	}
+	if err != nil {
+		fatal("find user", err)
+	}
+	return user
+}
+
+// singleArg returns the only positional argument or prints usage and exits.
+func singleArg(args []string, usage string) string {
+	if len(args) != 1 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	return args[0]
+}
+
+func generatePassword() string {
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		fatal("generate password", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// deleteBlobs removes the media blobs of uploads deleted with a user or chirp.
+// The delete is committed by then, so failures only warn.
+func deleteBlobs(ctx context.Context, store storage.Storage, keys []string) {
+	if err := storage.DeleteAll(ctx, store, keys...); err != nil {
+		slog.Warn("delete media blobs", "error", err)
+	}
+}
@@ -1,59 +1,157 @@
 package main
 
 import (
+	"context"
 	"database/sql"
-	"log"
-	"net/http"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
 	"os"
+	"strings"
 
 	"github.com/joho/godotenv"
 	_ "github.com/lib/pq"
 
 	"github.com/gskll/chirpy2/internal/config"
 	"github.com/gskll/chirpy2/internal/database"
-	"github.com/gskll/chirpy2/internal/handlers"
-	"github.com/gskll/chirpy2/internal/middleware"
+	"github.com/gskll/chirpy2/internal/migrations"
+	"github.com/gskll/chirpy2/internal/storage"
 )
 
+const usage = `Usage: chirpy [command] [flags]
+
+Commands:
+  serve                                                run the server, the default
+  migrate up|down|status|version                       manage the database schema
+  user create|list|show|delete|set-red|reset-password  manage users
+  chirp delete                                         delete a chirp
+  tokens revoke                                        revoke a user's refresh tokens
+  seed                                                 generate demo data, dev platform only
+
+Run "chirpy <command> -help" for the flags.
+`
+
 func main() {
 	godotenv.Load()
 
-	jwtSecret := os.Getenv("JWT_SECRET")
-	platform := os.Getenv("PLATFORM")
-	dbUrl := os.Getenv("DB_URL")
-	polkaKey := os.Getenv("POLKA_KEY")
+	cmd, args := "serve", os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		cmd, args = args[0], args[1:]
+	}
+
+	switch cmd {
+	case "serve":
+		serve(args)
+	case "migrate":
+		migrate(args)
+	case "user":
+		userCommand(args)
+	case "chirp":
+		chirpCommand(args)
+	case "tokens":
+		tokensCommand(args)
+	case "seed":
+		seed(args)
+	case "help":
+		fmt.Print(usage)
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s", cmd, usage)
+		os.Exit(2)
+	}
+}
 
-	db, err := sql.Open("postgres", dbUrl)
+// exitOnConfigError exits 0 after -help and 2 for an invalid config.
+func exitOnConfigError(err error) {
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(0)
+	}
 	if err != nil {
-		log.Fatal(err)
+		fmt.Fprintf(os.Stderr, "invalid configuration:\n%v\n", err)
+		os.Exit(2)
 	}
+}
 
-	var (
-		dbQueries  = database.New(db)
-		mux        = http.NewServeMux()
-		cfg        = config.NewApiConfig(dbQueries, platform, jwtSecret, polkaKey)
-		middleware = middleware.NewMiddleware(cfg)
-	)
+// splitAction takes the action off the front of a command's args, e.g. "up"
+// in "chirpy migrate up".
+func splitAction(args []string) (string, []string) {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		return "", args
+	}
+	return args[0], args[1:]
+}
 
-	var (
-		filepathRoot      = "./public"
-		fileServer        = http.FileServer(http.Dir(filepathRoot))
-		fileServerHandler = http.StripPrefix("/app", fileServer)
-	)
+// connect loads the config for an admin command, which only needs the
+// database, and opens it. It refuses to run against an outdated schema.
+func connect(ctx context.Context, args []string, register func(fs *flag.FlagSet)) (*config.Config, *sql.DB, *database.Queries) {
+	conf, err := config.ParseWith(args, os.Getenv, register)
+	if err == nil {
+		err = conf.ValidateDatabase()
+	}
+	exitOnConfigError(err)
 
-	mux.Handle("/app/", middleware.Metrics(fileServerHandler))
+	slog.SetDefault(newLogger(conf.LogFormat, conf.LogLevel))
 
-	handlers.RegisterAdminHandlers("/admin", cfg, mux)
-	handlers.RegisterAPIHandlers("/api", cfg, mux)
+	db := openDB(conf)
+	migrator, err := migrations.New(db)
+	if err != nil {
+		fatal("load migrations", err)
+	}
+	if err := migrator.CheckCurrent(ctx); err != nil {
+		fatal("check schema version", err)
+	}
+	return conf, db, database.New(db)
+}
 
-	wrappedMux := middleware.Logger(mux)
+func openDB(conf *config.Config) *sql.DB {
+	db, err := sql.Open("postgres", conf.DBURL)
+	if err != nil {
+		fatal("open database", err)
+	}
+	db.SetMaxOpenConns(conf.DBMaxOpenConns)
+	db.SetMaxIdleConns(conf.DBMaxIdleConns)
+	db.SetConnMaxLifetime(conf.DBConnMaxLifetime)
+	db.SetConnMaxIdleTime(conf.DBConnMaxIdleTime)
+	return db
+}
 
-	port := "8080"
-	srv := &http.Server{
-		Handler: wrappedMux,
-		Addr:    ":" + port,
+// openStorage opens the media storage, local storage creates its directory.
+func openStorage(conf *config.Config) storage.Storage {
+	if conf.MediaStorage == "s3" {
+		s3, err := storage.NewS3(storage.S3Config{
+			Endpoint:  conf.S3Endpoint,
+			Bucket:    conf.S3Bucket,
+			Region:    conf.S3Region,
+			AccessKey: conf.S3AccessKey,
+			SecretKey: conf.S3SecretKey,
+			UseSSL:    conf.S3UseSSL,
+		})
+		if err != nil {
+			fatal("open media storage", err)
+		}
+		return s3
+	}
+	local, err := storage.NewLocal(conf.MediaDir)
+	if err != nil {
+		fatal("open media storage", err)
 	}
+	return local
+}
+
+func newLogger(format, level string) *slog.Logger {
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(level)); err != nil {
+		lvl = slog.LevelInfo
+	}
+	opts := &slog.HandlerOptions{Level: lvl}
+
+	if format == "json" {
+		return slog.New(slog.NewJSONHandler(os.Stdout, opts))
+	}
+	return slog.New(slog.NewTextHandler(os.Stdout, opts))
+}
 
-	log.Printf("Serving files from %s on port: %s\n", filepathRoot, port)
-	log.Fatal(srv.ListenAndServe())
+func fatal(msg string, err error, args ...any) {
+	slog.Error(msg, append(args, "error", err)...)
+	os.Exit(1)
 }
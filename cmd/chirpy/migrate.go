@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/gskll/chirpy2/internal/config"
+	"github.com/gskll/chirpy2/internal/migrations"
+)
+
+const migrateUsage = `Usage: chirpy migrate up|down|status|version [flags]
+
+  up       apply all pending migrations
+  down     roll back the most recent migration
+  status   list migrations and when they were applied
+  version  print the current schema version
+`
+
+func migrate(args []string) {
+	action, args := splitAction(args)
+
+	conf, err := config.Parse(args, os.Getenv)
+	if err == nil {
+		err = conf.ValidateDatabase()
+	}
+	exitOnConfigError(err)
+
+	slog.SetDefault(newLogger(conf.LogFormat, conf.LogLevel))
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	db := openDB(conf)
+	defer db.Close()
+
+	migrator, err := migrations.New(db)
+	if err != nil {
+		fatal("load migrations", err)
+	}
+
+	switch action {
+	case "up":
+		results, err := migrator.Up(ctx)
+		for _, result := range results {
+			fmt.Println(result)
+		}
+		if err != nil {
+			fatal("apply migrations", err)
+		}
+		if len(results) == 0 {
+			fmt.Println("no migrations to apply")
+		}
+	case "down":
+		result, err := migrator.Down(ctx)
+		if err != nil {
+			fatal("roll back migration", err)
+		}
+		fmt.Println(result)
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			fatal("migration status", err)
+		}
+		tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "VERSION\tSTATE\tAPPLIED AT\tSOURCE")
+		for _, status := range statuses {
+			appliedAt := "-"
+			if !status.AppliedAt.IsZero() {
+				appliedAt = status.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Fprintf(tw, "%d\t%s\t%s\t%s\n", status.Source.Version, status.State, appliedAt, status.Source.Path)
+		}
+		tw.Flush()
+	case "version":
+		version, err := migrator.Version(ctx)
+		if err != nil {
+			fatal("schema version", err)
+		}
+		fmt.Printf("%d (latest %d)\n", version, migrator.Latest())
+	default:
+		fmt.Fprint(os.Stderr, migrateUsage)
+		os.Exit(2)
+	}
+}
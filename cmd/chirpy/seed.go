@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/gskll/chirpy2/internal/auth"
+	"github.com/gskll/chirpy2/internal/config"
+	"github.com/gskll/chirpy2/internal/database"
+	"github.com/gskll/chirpy2/internal/filter"
+)
+
+// seedWindow is how far back seeded chirps are spread.
+const seedWindow = 30 * 24 * time.Hour
+
+var (
+	seedFirstNames = []string{
+		"ada", "alan", "barbara", "claude", "donald", "edsger", "frances", "grace",
+		"hedy", "ken", "linus", "margaret", "niklaus", "radia", "rob", "sophie",
+	}
+	seedLastNames = []string{
+		"hopper", "lovelace", "turing", "liskov", "knuth", "dijkstra", "allen",
+		"thompson", "pike", "hamilton", "wirth", "perlman", "wilson", "shannon",
+	}
+	seedOpeners = []string{
+		"Just shipped", "Finally fixed", "Spent all morning on", "Can't stop thinking about",
+		"Hot take on", "Anyone else excited about", "Today I learned about", "Refactoring",
+	}
+	seedTopics = []string{
+		"the new release", "my side project", "goroutine leaks", "database migrations",
+		"a flaky test", "rate limiting", "the coffee machine", "our deploy pipeline",
+		"websockets", "structured logging", "a tricky merge conflict", "tabs vs spaces",
+	}
+	seedClosers = []string{
+		"", "!", " :)", ", wish me luck", ", more soon", " and it was worth it",
+		". Thoughts?", ", send help", " #golang", " before lunch",
+	}
+)
+
+func seed(args []string) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	var (
+		users    int
+		chirps   int
+		password string
+		seedNum  uint64
+	)
+	conf, sqlDB, queries := connect(ctx, args, func(fs *flag.FlagSet) {
+		fs.IntVar(&users, "users", 10, "users to create")
+		fs.IntVar(&chirps, "chirps", 20, "max chirps per user")
+		fs.StringVar(&password, "password", "password", "password for every seeded user")
+		fs.Uint64Var(&seedNum, "seed", uint64(time.Now().UnixNano()), "random seed, for repeatable data")
+	})
+	if conf.Platform != config.DEV {
+		fatal("seed", fmt.Errorf("only allowed on the %q platform", config.DEV))
+	}
+
+	rng := rand.New(rand.NewPCG(seedNum, seedNum))
+
+	// bcrypt is slow, every seeded user shares one hash
+	hashedPassword, err := auth.HashPassword(password)
+	if err != nil {
+		fatal("hash password", err)
+	}
+
+	tx, err := sqlDB.BeginTx(ctx, nil)
+	if err != nil {
+		fatal("begin transaction", err)
+	}
+	defer tx.Rollback()
+	qtx := queries.WithTx(tx)
+
+	suffix := fmt.Sprintf("%04x", rng.IntN(1<<16))
+	now := time.Now().UTC()
+	created := 0
+	for i := range users {
+		first := seedFirstNames[rng.IntN(len(seedFirstNames))]
+		last := seedLastNames[rng.IntN(len(seedLastNames))]
+		email := fmt.Sprintf("%s.%s.%s%d@example.com", first, last, suffix, i)
+
+		user, err := qtx.CreateUser(ctx, database.CreateUserParams{Email: email, HashedPassword: hashedPassword})
+		if err != nil {
+			fatal("create user", err)
+		}
+		if rng.IntN(5) == 0 {
+			err := qtx.SetUserChirpyRed(ctx, database.SetUserChirpyRedParams{IsChirpyRed: true, ID: user.ID})
+			if err != nil {
+				fatal("grant chirpy red", err)
+			}
+		}
+
+		for range rng.IntN(chirps + 1) {
+			_, err := qtx.CreateChirpAt(ctx, database.CreateChirpAtParams{
+				CreatedAt: now.Add(-time.Duration(rng.Int64N(int64(seedWindow)))),
+				Body:      seedChirp(rng),
+				UserID:    user.ID,
+			})
+			if err != nil {
+				fatal("create chirp", err)
+			}
+			created++
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		fatal("commit", err)
+	}
+	fmt.Printf("seeded %d users and %d chirps, password %q, seed %d\n", users, created, password, seedNum)
+}
+
+func seedChirp(rng *rand.Rand) string {
+	body := seedOpeners[rng.IntN(len(seedOpeners))] + " " +
+		seedTopics[rng.IntN(len(seedTopics))] +
+		seedClosers[rng.IntN(len(seedClosers))]
+	if rng.IntN(3) == 0 {
+		body = strings.ToLower(body[:1]) + body[1:]
+	}
+	return filter.Default().Check(body).Text
+}
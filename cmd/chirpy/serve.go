@@ -0,0 +1,247 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/gskll/chirpy2/internal/config"
+	"github.com/gskll/chirpy2/internal/database"
+	"github.com/gskll/chirpy2/internal/filter"
+	"github.com/gskll/chirpy2/internal/handlers"
+	"github.com/gskll/chirpy2/internal/health"
+	"github.com/gskll/chirpy2/internal/media"
+	"github.com/gskll/chirpy2/internal/metrics"
+	"github.com/gskll/chirpy2/internal/middleware"
+	"github.com/gskll/chirpy2/internal/migrations"
+	"github.com/gskll/chirpy2/internal/ratelimit"
+	"github.com/gskll/chirpy2/internal/tracing"
+)
+
+func serve(args []string) {
+	conf, err := config.Load(args, os.Getenv)
+	exitOnConfigError(err)
+	if conf.PrintOnly {
+		conf.Print(os.Stdout)
+		return
+	}
+
+	slog.SetDefault(newLogger(conf.LogFormat, conf.LogLevel))
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	shutdownTracing, err := tracing.Setup(ctx, conf.TraceExporter)
+	if err != nil {
+		fatal("setup tracing", err)
+	}
+
+	db := openDB(conf)
+
+	migrator, err := migrations.New(db)
+	if err != nil {
+		fatal("load migrations", err)
+	}
+	if conf.AutoMigrate {
+		results, err := migrator.Up(ctx)
+		if err != nil {
+			fatal("apply migrations", err)
+		}
+		for _, result := range results {
+			slog.Info("applied migration", "version", result.Source.Version, "duration", result.Duration)
+		}
+	}
+	if err := migrator.CheckCurrent(ctx); errors.Is(err, migrations.ErrSchemaBehind) {
+		fatal("refusing to serve, run `chirpy migrate up` or pass -auto-migrate", err)
+	} else if err != nil {
+		fatal("check schema version", err)
+	}
+
+	var (
+		dbQueries  = database.New(tracing.WrapDB(db))
+		mux        = http.NewServeMux()
+		cfg        = config.NewApiConfig(dbQueries, conf.Platform, conf.JWTSecret, conf.PolkaKey)
+		middleware = middleware.NewMiddleware(cfg)
+	)
+
+	shutdown := make(chan struct{})
+	cfg.DB = db
+	cfg.Shutdown = shutdown
+	cfg.Metrics = metrics.New(db)
+	cfg.RequestTimeout = conf.RequestTimeout
+	cfg.MaxBodyBytes = conf.MaxBodyBytes
+	cfg.AllowedOrigins = conf.AllowedOrigins()
+	cfg.AccessTokenTTL = conf.AccessTokenTTL
+	cfg.RefreshTokenTTL = conf.RefreshTokenTTL
+	cfg.Storage = openStorage(conf)
+	cfg.MaxMediaBytes = conf.MediaMaxBytes
+	cfg.Thumbnails = media.NewThumbnailer(dbQueries, cfg.Storage, conf.MediaWorkers)
+	// validated by config.Load
+	cfg.TrustedProxies, _ = conf.ProxyPrefixes()
+
+	cfg.Health = health.NewChecker(conf.ReadyCheckTimeout)
+	cfg.Health.Add("database", health.Ping(db))
+	cfg.Health.Add("migrations", health.SchemaVersion(db, migrator.Latest()))
+
+	var workers sync.WaitGroup
+
+	cfg.Filter, err = filter.NewLoader(conf.FilterWords, dbQueries)
+	if err != nil {
+		fatal("load filter rules", err)
+	}
+	if err := cfg.Filter.Reload(ctx); err != nil {
+		fatal("load filter rules", err)
+	}
+	if conf.FilterReloadInterval > 0 {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			cfg.Filter.Watch(ctx, conf.FilterReloadInterval)
+		}()
+	}
+
+	workers.Add(1)
+	go func() {
+		defer workers.Done()
+		cfg.Thumbnails.Run(ctx)
+	}()
+
+	workers.Add(1)
+	go func() {
+		defer workers.Done()
+		media.RemoveUnattached(ctx, dbQueries, cfg.Storage, time.Hour, 24*time.Hour)
+	}()
+
+	var store ratelimit.Store = ratelimit.NewMemoryStore()
+	if conf.RateLimitStore == "postgres" {
+		pgStore := ratelimit.NewPostgresStore(dbQueries)
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			pgStore.Cleanup(ctx, time.Minute, time.Hour)
+		}()
+		store = pgStore
+	}
+	limiter := ratelimit.NewLimiter(store)
+	limiter.AddGroup("auth", ratelimit.Limit{Requests: 10, Per: time.Minute},
+		"POST /api/login", "POST /api/users", "POST /api/refresh")
+	limiter.AddGroup("chirps", ratelimit.Limit{Requests: 30, Per: time.Minute},
+		"POST /api/chirps")
+	limiter.AddGroup("media", ratelimit.Limit{Requests: 30, Per: time.Minute},
+		"POST /api/media")
+	limiter.AddGroup("messages", ratelimit.Limit{Requests: 60, Per: time.Minute},
+		"POST /api/conversations", "POST /api/conversations/{conversationID}/messages")
+	limiter.AddGroup("api", ratelimit.Limit{Requests: 300, Per: time.Minute},
+		"/api/")
+
+	var (
+		fileServer        = http.FileServer(http.Dir(conf.PublicRoot))
+		fileServerHandler = http.StripPrefix("/app", fileServer)
+	)
+
+	mux.Handle("/app/", middleware.Metrics(fileServerHandler))
+
+	handlers.RegisterAdminHandlers("/admin", cfg, middleware, mux)
+	handlers.RegisterAPIHandlers("/api", cfg, middleware, mux)
+
+	wrappedMux := middleware.Logger(
+		middleware.Trace(
+			middleware.Recover(
+				middleware.Instrument(
+					middleware.CORS(
+						middleware.RateLimit(limiter, mux),
+					),
+				),
+			),
+		),
+	)
+
+	srv := &http.Server{
+		Handler:           wrappedMux,
+		Addr:              ":" + strconv.Itoa(conf.Port),
+		ReadHeaderTimeout: conf.ReadHeaderTimeout,
+		ReadTimeout:       conf.ReadTimeout,
+		WriteTimeout:      conf.WriteTimeout,
+		IdleTimeout:       conf.IdleTimeout,
+	}
+
+	// closing shutdown ends streams, they would otherwise hold the drain open
+	srv.RegisterOnShutdown(func() { close(shutdown) })
+
+	serveErr := make(chan error, 2)
+	go func() {
+		slog.Info("serving", "root", conf.PublicRoot, "port", conf.Port)
+		serveErr <- srv.ListenAndServe()
+	}()
+
+	// metrics get their own listener so they can be kept off the public
+	// network
+	var metricsSrv *http.Server
+	if conf.MetricsAddr != "" {
+		metricsMux := http.NewServeMux()
+		metricsMux.Handle("GET /metrics", cfg.Metrics.Handler())
+		metricsSrv = &http.Server{
+			Handler:           metricsMux,
+			Addr:              conf.MetricsAddr,
+			ReadHeaderTimeout: conf.ReadHeaderTimeout,
+			ReadTimeout:       conf.ReadTimeout,
+			WriteTimeout:      conf.WriteTimeout,
+			IdleTimeout:       conf.IdleTimeout,
+		}
+		go func() {
+			slog.Info("serving metrics", "addr", conf.MetricsAddr)
+			serveErr <- metricsSrv.ListenAndServe()
+		}()
+	}
+
+	exitCode := 0
+	select {
+	case err := <-serveErr:
+		slog.Error("server stopped", "error", err)
+		exitCode = 1
+		// the background workers only stop when ctx is done, without this a
+		// failed listener would hang in workers.Wait
+		stop()
+	case <-ctx.Done():
+		// a second signal kills the process immediately
+		stop()
+		slog.Info("shutting down", "timeout", conf.ShutdownTimeout)
+
+		// give load balancers time to see /api/readyz fail before the
+		// listener closes
+		cfg.Health.Drain()
+		time.Sleep(conf.ShutdownDelay)
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), conf.ShutdownTimeout)
+	defer cancel()
+
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		slog.Error("drain connections", "error", err)
+		srv.Close()
+		exitCode = 1
+	}
+	if metricsSrv != nil {
+		// scrapes are quick, there's nothing worth draining
+		metricsSrv.Close()
+	}
+	workers.Wait()
+	if err := db.Close(); err != nil {
+		slog.Error("close database", "error", err)
+		exitCode = 1
+	}
+	if err := shutdownTracing(shutdownCtx); err != nil {
+		slog.Error("flush traces", "error", err)
+		exitCode = 1
+	}
+
+	slog.Info("shutdown complete", "exit_code", exitCode)
+	os.Exit(exitCode)
+}
@@ -0,0 +1,148 @@
+package audit
+
+import (
+	"context"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/gskll/chirpy2/internal/database"
+)
+
+const (
+	AdminReset              = "admin.reset"
+	AdminUserSuspend        = "admin.user.suspend"
+	AdminUserUnsuspend      = "admin.user.unsuspend"
+	AdminUserDelete         = "admin.user.delete"
+	AdminUserRevokeSessions = "admin.user.revoke_sessions"
+	AdminUserChirpyRed      = "admin.user.chirpy_red"
+	AdminUserRole           = "admin.user.role"
+	AdminFilterRuleSet      = "admin.filter.rule_set"
+	AdminFilterRuleDelete   = "admin.filter.rule_delete"
+
+	AuthLogin          = "auth.login"
+	AuthLoginFailed    = "auth.login_failed"
+	AuthTokenRevoke    = "auth.token_revoke"
+	UserEmailChange    = "user.email_change"
+	UserPasswordChange = "user.password_change"
+	UserUpgrade        = "user.upgrade"
+	ChirpDelete        = "chirp.delete"
+
+	ModerationReportResolve = "moderation.report.resolve"
+	ModerationChirpHide     = "moderation.chirp.hide"
+	ModerationChirpUnhide   = "moderation.chirp.unhide"
+	ModerationUserSuspend   = "moderation.user.suspend"
+)
+
+// Event is an action to record. A nil ActorID means the action was taken
+// outside the API, e.g. from the CLI or a webhook.
+type Event struct {
+	ActorID      uuid.UUID
+	Action       string
+	TargetUserID uuid.UUID
+	IP           string
+	UserAgent    string
+	Detail       any
+}
+
+// Record writes the event, pass transaction-scoped queries to make it atomic
+// with the action it records. The database assigns the sequence number and
+// links the event into the hash chain.
+func Record(ctx context.Context, db *database.Queries, event Event) error {
+	detail := []byte("{}")
+	if event.Detail != nil {
+		var err error
+		detail, err = json.Marshal(event.Detail)
+		if err != nil {
+			return err
+		}
+	}
+	return db.CreateAuditEvent(ctx, database.CreateAuditEventParams{
+		ActorID:      nullUUID(event.ActorID),
+		Action:       event.Action,
+		TargetUserID: nullUUID(event.TargetUserID),
+		Ip:           nullString(event.IP),
+		UserAgent:    nullString(event.UserAgent),
+		Detail:       detail,
+	})
+}
+
+// Entry is a recorded event as returned by the API, hashes are hex encoded.
+type Entry struct {
+	Seq          int64           `json:"seq"`
+	ID           uuid.UUID       `json:"id"`
+	ActorID      *uuid.UUID      `json:"actor_id"`
+	Action       string          `json:"action"`
+	TargetUserID *uuid.UUID      `json:"target_user_id"`
+	IP           string          `json:"ip,omitempty"`
+	UserAgent    string          `json:"user_agent,omitempty"`
+	Detail       json.RawMessage `json:"detail"`
+	CreatedAt    time.Time       `json:"created_at"`
+	PrevHash     string          `json:"prev_hash"`
+	Hash         string          `json:"hash"`
+}
+
+func NewEntry(e database.AuditEvent) Entry {
+	entry := Entry{
+		Seq:       e.Seq,
+		ID:        e.ID,
+		Action:    e.Action,
+		IP:        e.Ip.String,
+		UserAgent: e.UserAgent.String,
+		Detail:    e.Detail,
+		CreatedAt: e.CreatedAt,
+		PrevHash:  hex.EncodeToString(e.PrevHash),
+		Hash:      hex.EncodeToString(e.Hash),
+	}
+	if e.ActorID.Valid {
+		entry.ActorID = &e.ActorID.UUID
+	}
+	if e.TargetUserID.Valid {
+		entry.TargetUserID = &e.TargetUserID.UUID
+	}
+	if len(entry.Detail) == 0 {
+		entry.Detail = json.RawMessage("{}")
+	}
+	return entry
+}
+
+// Verification is the result of checking the hash chain.
+type Verification struct {
+	OK       bool    `json:"ok"`
+	HeadSeq  int64   `json:"head_seq"`
+	HeadHash string  `json:"head_hash"`
+	Broken   []int64 `json:"broken"`
+}
+
+// Verify recomputes every event's hash and checks it links to the event
+// before it. Broken lists the sequence numbers of events that don't match,
+// an edited event also breaks the link of the one after it.
+func Verify(ctx context.Context, db *database.Queries) (Verification, error) {
+	v := Verification{Broken: []int64{}}
+	head, err := db.GetAuditChainHead(ctx)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return v, err
+	}
+	v.HeadSeq = head.Seq
+	v.HeadHash = hex.EncodeToString(head.Hash)
+
+	broken, err := db.FindAuditChainBreaks(ctx)
+	if err != nil {
+		return v, err
+	}
+	v.Broken = append(v.Broken, broken...)
+	v.OK = len(v.Broken) == 0
+	return v, nil
+}
+
+func nullUUID(id uuid.UUID) uuid.NullUUID {
+	return uuid.NullUUID{UUID: id, Valid: id != uuid.Nil}
+}
+
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
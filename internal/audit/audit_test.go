@@ -0,0 +1,76 @@
+package audit
+
+import (
+	"database/sql"
+	"encoding/json"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/gskll/chirpy2/internal/database"
+)
+
+func TestNewEntry(t *testing.T) {
+	actor := uuid.New()
+
+	tests := []struct {
+		name       string
+		event      database.AuditEvent
+		wantActor  *uuid.UUID
+		wantDetail string
+		wantHash   string
+		wantIP     string
+	}{
+		{
+			name: "user action",
+			event: database.AuditEvent{
+				ActorID:  uuid.NullUUID{UUID: actor, Valid: true},
+				Action:   AuthLogin,
+				Ip:       sql.NullString{String: "203.0.113.7", Valid: true},
+				Detail:   json.RawMessage(`{"reason":"bad_password"}`),
+				PrevHash: []byte{},
+				Hash:     []byte{0xde, 0xad, 0xbe, 0xef},
+			},
+			wantActor:  &actor,
+			wantDetail: `{"reason":"bad_password"}`,
+			wantHash:   "deadbeef",
+			wantIP:     "203.0.113.7",
+		},
+		{
+			name:       "system action without detail",
+			event:      database.AuditEvent{Action: UserUpgrade, Hash: []byte{0x01}},
+			wantDetail: `{}`,
+			wantHash:   "01",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entry := NewEntry(tt.event)
+			if (entry.ActorID == nil) != (tt.wantActor == nil) || (entry.ActorID != nil && *entry.ActorID != *tt.wantActor) {
+				t.Errorf("Expected actor %v, got %v", tt.wantActor, entry.ActorID)
+			}
+			if entry.TargetUserID != nil {
+				t.Errorf("Expected no target, got %v", entry.TargetUserID)
+			}
+			if string(entry.Detail) != tt.wantDetail {
+				t.Errorf("Expected detail %s, got %s", tt.wantDetail, entry.Detail)
+			}
+			if entry.Hash != tt.wantHash {
+				t.Errorf("Expected hash %s, got %s", tt.wantHash, entry.Hash)
+			}
+			if entry.IP != tt.wantIP {
+				t.Errorf("Expected ip %q, got %q", tt.wantIP, entry.IP)
+			}
+		})
+	}
+}
+
+func TestNullUUID(t *testing.T) {
+	if nullUUID(uuid.Nil).Valid {
+		t.Errorf("Expected nil uuid to be NULL")
+	}
+	if id := uuid.New(); nullUUID(id) != (uuid.NullUUID{UUID: id, Valid: true}) {
+		t.Errorf("Expected %s to be valid", id)
+	}
+}
@@ -2,51 +2,69 @@ package auth
 
 import (
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 )
 
-func MakeJWT(userID uuid.UUID, tokenSecret string, expiresIn time.Duration) (string, error) {
+type Claims struct {
+	jwt.RegisteredClaims
+	Scope string `json:"scope,omitempty"`
+}
+
+// Scopes returns the space separated scope claim as a slice.
+func (c *Claims) Scopes() []string {
+	return strings.Fields(c.Scope)
+}
+
+func MakeJWT(userID uuid.UUID, tokenSecret string, expiresIn time.Duration, scopes ...string) (string, error) {
 	now := time.Now().UTC()
 	return jwt.NewWithClaims(
 		jwt.SigningMethodHS256,
-		jwt.RegisteredClaims{
-			Issuer:    "chirpy",
-			IssuedAt:  jwt.NewNumericDate(now),
-			ExpiresAt: jwt.NewNumericDate(now.Add(expiresIn)),
-			Subject:   userID.String(),
+		Claims{
+			RegisteredClaims: jwt.RegisteredClaims{
+				Issuer:    "chirpy",
+				IssuedAt:  jwt.NewNumericDate(now),
+				ExpiresAt: jwt.NewNumericDate(now.Add(expiresIn)),
+				Subject:   userID.String(),
+			},
+			Scope: strings.Join(scopes, " "),
 		},
 	).SignedString([]byte(tokenSecret))
 }
 
 func ValidateJWT(tokenString, tokenSecret string) (uuid.UUID, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &jwt.RegisteredClaims{
-		Issuer: "chirpy",
-	}, func(t *jwt.Token) (interface{}, error) {
+	userID, _, err := ParseJWT(tokenString, tokenSecret)
+	return userID, err
+}
+
+// ParseJWT validates a token like ValidateJWT and also returns its claims.
+func ParseJWT(tokenString, tokenSecret string) (uuid.UUID, *Claims, error) {
+	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(t *jwt.Token) (interface{}, error) {
 		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
 			return nil, fmt.Errorf("unexpected signing method: %v", t.Header["alg"])
 		}
 		return []byte(tokenSecret), nil
 	})
 	if err != nil {
-		return uuid.UUID{}, fmt.Errorf("invalid token: %w", err)
+		return uuid.UUID{}, nil, fmt.Errorf("invalid token: %w", err)
 	}
 
-	claims, ok := token.Claims.(*jwt.RegisteredClaims)
+	claims, ok := token.Claims.(*Claims)
 	if !ok || !token.Valid {
-		return uuid.UUID{}, fmt.Errorf("invalid token claims")
+		return uuid.UUID{}, nil, fmt.Errorf("invalid token claims")
 	}
 
 	if claims.Issuer != "chirpy" {
-		return uuid.UUID{}, fmt.Errorf("invalid issuer")
+		return uuid.UUID{}, nil, fmt.Errorf("invalid issuer")
 	}
 
 	userID, err := uuid.Parse(claims.Subject)
 	if err != nil {
-		return uuid.UUID{}, fmt.Errorf("invalid user id in token")
+		return uuid.UUID{}, nil, fmt.Errorf("invalid user id in token")
 	}
 
-	return userID, nil
+	return userID, claims, nil
 }
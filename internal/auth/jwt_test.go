@@ -78,3 +78,53 @@ func TestMakeAndValidateJWT(t *testing.T) {
 		t.Error("ValidateJWT did not return an error for an invalid signing method")
 	}
 }
+
+func TestParseJWTExpiresAt(t *testing.T) {
+	token, err := MakeJWT(uuid.New(), "test-secret", time.Hour)
+	if err != nil {
+		t.Fatalf("MakeJWT returned an error: %v", err)
+	}
+
+	_, claims, err := ParseJWT(token, "test-secret")
+	if err != nil {
+		t.Fatalf("ParseJWT returned an error: %v", err)
+	}
+	if d := time.Until(claims.ExpiresAt.Time); d <= 59*time.Minute || d > time.Hour {
+		t.Errorf("Unexpected expiry %v", claims.ExpiresAt.Time)
+	}
+
+	if _, _, err := ParseJWT(token, "wrong-secret"); err == nil {
+		t.Error("ParseJWT did not return an error for a token signed with another secret")
+	}
+}
+
+func TestParseJWTScopes(t *testing.T) {
+	userID := uuid.New()
+	tokenSecret := "test-secret"
+
+	token, err := MakeJWT(userID, tokenSecret, time.Hour, "chirps:write", "admin")
+	if err != nil {
+		t.Fatalf("MakeJWT returned an error: %v", err)
+	}
+
+	parsedUserID, claims, err := ParseJWT(token, tokenSecret)
+	if err != nil {
+		t.Fatalf("ParseJWT returned an error: %v", err)
+	}
+	if parsedUserID != userID {
+		t.Errorf("ParseJWT returned incorrect userID. Got %v, want %v", parsedUserID, userID)
+	}
+	scopes := claims.Scopes()
+	if len(scopes) != 2 || scopes[0] != "chirps:write" || scopes[1] != "admin" {
+		t.Errorf("Unexpected scopes: %v", scopes)
+	}
+
+	unscoped, _ := MakeJWT(userID, tokenSecret, time.Hour)
+	_, claims, err = ParseJWT(unscoped, tokenSecret)
+	if err != nil {
+		t.Fatalf("ParseJWT returned an error: %v", err)
+	}
+	if len(claims.Scopes()) != 0 {
+		t.Errorf("Expected no scopes, got %v", claims.Scopes())
+	}
+}
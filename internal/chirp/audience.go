@@ -0,0 +1,104 @@
+package chirp
+
+import (
+	"fmt"
+
+	"github.com/google/uuid"
+)
+
+// Visibility is who can read a chirp, on top of the author's account being
+// protected. Mentioned users can always read it.
+const (
+	Public    = "public"
+	Unlisted  = "unlisted"
+	Followers = "followers"
+	Mentioned = "mentioned"
+)
+
+// Reply policies, the author can always reply.
+const (
+	ReplyEveryone  = "everyone"
+	ReplyFollowers = "followers"
+	ReplyMentioned = "mentioned"
+)
+
+// MaxMentions is how many users a chirp can mention.
+const MaxMentions = 10
+
+const (
+	CodeInvalidValue = "invalid_value"
+	CodeRequired     = "required"
+	CodeTooMany      = "too_many"
+	CodeNotFound     = "not_found"
+)
+
+var (
+	visibilities  = map[string]bool{Public: true, Unlisted: true, Followers: true, Mentioned: true}
+	replyPolicies = map[string]bool{ReplyEveryone: true, ReplyFollowers: true, ReplyMentioned: true}
+)
+
+// Audience is who can read a chirp and who can reply to it.
+type Audience struct {
+	Visibility  string
+	ReplyPolicy string
+	Mentions    []uuid.UUID
+}
+
+// ValidateAudience fills in the defaults, public and everyone, and drops the
+// author and duplicates from the mentions. The error is ValidationErrors when
+// a is invalid.
+func ValidateAudience(a Audience, authorID uuid.UUID) (Audience, error) {
+	var errs ValidationErrors
+	if a.Visibility == "" {
+		a.Visibility = Public
+	}
+	if !visibilities[a.Visibility] {
+		errs = append(errs, &ValidationError{
+			Field:   "visibility",
+			Code:    CodeInvalidValue,
+			Message: fmt.Sprintf("Invalid visibility %q, must be public, unlisted, followers or mentioned", a.Visibility),
+		})
+	}
+	if a.ReplyPolicy == "" {
+		a.ReplyPolicy = ReplyEveryone
+	}
+	if !replyPolicies[a.ReplyPolicy] {
+		errs = append(errs, &ValidationError{
+			Field:   "reply_policy",
+			Code:    CodeInvalidValue,
+			Message: fmt.Sprintf("Invalid reply policy %q, must be everyone, followers or mentioned", a.ReplyPolicy),
+		})
+	}
+
+	seen := make(map[uuid.UUID]bool, len(a.Mentions))
+	mentions := make([]uuid.UUID, 0, len(a.Mentions))
+	for _, id := range a.Mentions {
+		if id == authorID || id == uuid.Nil || seen[id] {
+			continue
+		}
+		seen[id] = true
+		mentions = append(mentions, id)
+	}
+	a.Mentions = mentions
+	if len(a.Mentions) > MaxMentions {
+		errs = append(errs, &ValidationError{
+			Field:   "mentions",
+			Code:    CodeTooMany,
+			Message: fmt.Sprintf("Chirp mentions too many users. Max %d. Actual: %d", MaxMentions, len(a.Mentions)),
+			Max:     MaxMentions,
+			Length:  len(a.Mentions),
+		})
+	}
+	if a.Visibility == Mentioned && len(a.Mentions) == 0 {
+		errs = append(errs, &ValidationError{
+			Field:   "mentions",
+			Code:    CodeRequired,
+			Message: "Chirps visible to mentioned users must mention someone",
+		})
+	}
+
+	if len(errs) > 0 {
+		return Audience{}, errs
+	}
+	return a, nil
+}
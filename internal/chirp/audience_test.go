@@ -0,0 +1,71 @@
+package chirp
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestValidateAudience(t *testing.T) {
+	author := uuid.New()
+	other := uuid.New()
+	tooMany := make([]uuid.UUID, MaxMentions+1)
+	for i := range tooMany {
+		tooMany[i] = uuid.New()
+	}
+
+	tests := []struct {
+		name     string
+		audience Audience
+		want     Audience
+		codes    []string
+	}{
+		{"Defaults", Audience{}, Audience{Visibility: Public, ReplyPolicy: ReplyEveryone, Mentions: []uuid.UUID{}}, nil},
+		{"Unlisted", Audience{Visibility: Unlisted, ReplyPolicy: ReplyFollowers}, Audience{Visibility: Unlisted, ReplyPolicy: ReplyFollowers, Mentions: []uuid.UUID{}}, nil},
+		{"Mentions deduplicated", Audience{Visibility: Mentioned, Mentions: []uuid.UUID{other, author, other}}, Audience{Visibility: Mentioned, ReplyPolicy: ReplyEveryone, Mentions: []uuid.UUID{other}}, nil},
+		{"Invalid visibility", Audience{Visibility: "friends"}, Audience{}, []string{CodeInvalidValue}},
+		{"Invalid reply policy", Audience{ReplyPolicy: "nobody"}, Audience{}, []string{CodeInvalidValue}},
+		{"Too many mentions", Audience{Mentions: tooMany}, Audience{}, []string{CodeTooMany}},
+		{"Mentioned without mentions", Audience{Visibility: Mentioned, Mentions: []uuid.UUID{author}}, Audience{}, []string{CodeRequired}},
+		{"Several problems", Audience{Visibility: "friends", ReplyPolicy: "nobody"}, Audience{}, []string{CodeInvalidValue, CodeInvalidValue}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ValidateAudience(tt.audience, author)
+			if got.Visibility != tt.want.Visibility || got.ReplyPolicy != tt.want.ReplyPolicy || !slices.Equal(got.Mentions, tt.want.Mentions) {
+				t.Errorf("Expected %+v, got %+v", tt.want, got)
+			}
+			var errs ValidationErrors
+			if err != nil && !errors.As(err, &errs) {
+				t.Fatalf("Expected ValidationErrors, got %T", err)
+			}
+			if len(errs) != len(tt.codes) {
+				t.Fatalf("Expected %d errors, got %v", len(tt.codes), err)
+			}
+			for i, code := range tt.codes {
+				if errs[i].Code != code {
+					t.Errorf("Expected code %s, got %s", code, errs[i].Code)
+				}
+			}
+		})
+	}
+}
+
+func TestJoinErrors(t *testing.T) {
+	_, bodyErr := Validate("")
+	_, audienceErr := ValidateAudience(Audience{Visibility: "friends"}, uuid.New())
+
+	var errs ValidationErrors
+	if !errors.As(JoinErrors(bodyErr, nil, audienceErr), &errs) {
+		t.Fatal("Expected ValidationErrors")
+	}
+	if len(errs) != 2 || errs[0].Field != "body" || errs[1].Field != "visibility" {
+		t.Errorf("Expected body and visibility errors, got %v", errs)
+	}
+	if err := JoinErrors(nil, nil); err != nil {
+		t.Errorf("Expected nil, got %v", err)
+	}
+}
@@ -6,22 +6,78 @@ import (
 	"github.com/google/uuid"
 
 	"github.com/gskll/chirpy2/internal/database"
+	"github.com/gskll/chirpy2/internal/user"
 )
 
+// HiddenNotice is shown to the author of a chirp a moderator has hidden.
+const HiddenNotice = "This chirp was hidden by a moderator and is only visible to you."
+
 type Chirp struct {
-	ID        uuid.UUID `json:"id"`
-	UserId    uuid.UUID `json:"user_id"`
-	Body      string    `json:"body"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID          uuid.UUID   `json:"id"`
+	UserId      uuid.UUID   `json:"user_id"`
+	Body        string      `json:"body"`
+	Visibility  string      `json:"visibility"`
+	ReplyPolicy string      `json:"reply_policy"`
+	ReplyToId   *uuid.UUID  `json:"reply_to_id,omitempty"`
+	Mentions    []uuid.UUID `json:"mentions"`
+	Media       []Media     `json:"media"`
+	CreatedAt   time.Time   `json:"created_at"`
+	UpdatedAt   time.Time   `json:"updated_at"`
+	Moderation  *Moderation `json:"moderation,omitempty"`
+}
+
+// Moderation is only set for hidden chirps, and only in responses to the
+// author or a moderator.
+type Moderation struct {
+	HiddenAt time.Time `json:"hidden_at"`
+	Reason   string    `json:"reason"`
+	Notice   string    `json:"notice"`
 }
 
 func NewChirp(dbChirp database.Chirp) Chirp {
-	return Chirp{
-		ID:        dbChirp.ID,
-		UserId:    dbChirp.UserID,
-		Body:      dbChirp.Body,
-		CreatedAt: dbChirp.CreatedAt,
-		UpdatedAt: dbChirp.UpdatedAt,
+	c := Chirp{
+		ID:          dbChirp.ID,
+		UserId:      dbChirp.UserID,
+		Body:        dbChirp.Body,
+		Visibility:  dbChirp.Visibility,
+		ReplyPolicy: dbChirp.ReplyPolicy,
+		Mentions:    dbChirp.Mentions,
+		CreatedAt:   dbChirp.CreatedAt,
+		UpdatedAt:   dbChirp.UpdatedAt,
+	}
+	if dbChirp.ReplyToID.Valid {
+		c.ReplyToId = &dbChirp.ReplyToID.UUID
+	}
+	if c.Mentions == nil {
+		c.Mentions = []uuid.UUID{}
+	}
+	c.Media = []Media{}
+	return c
+}
+
+// NewModeratedChirp includes the moderation state, use it when the viewer is
+// allowed to see hidden chirps.
+func NewModeratedChirp(dbChirp database.Chirp) Chirp {
+	c := NewChirp(dbChirp)
+	if dbChirp.HiddenAt.Valid {
+		c.Moderation = &Moderation{
+			HiddenAt: dbChirp.HiddenAt.Time,
+			Reason:   dbChirp.HiddenReason.String,
+			Notice:   HiddenNotice,
+		}
+	}
+	return c
+}
+
+func Hidden(dbChirp database.Chirp) bool {
+	return dbChirp.HiddenAt.Valid
+}
+
+// CanSeeHidden reports whether viewer may see dbChirp while it's hidden, only
+// its author and moderators that aren't suspended can.
+func CanSeeHidden(dbChirp database.Chirp, viewer database.User) bool {
+	if viewer.ID == dbChirp.UserID {
+		return true
 	}
+	return !viewer.SuspendedAt.Valid && user.HasRole(viewer.Role, user.RoleModerator)
 }
@@ -0,0 +1,39 @@
+package chirp
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/gskll/chirpy2/internal/database"
+	"github.com/gskll/chirpy2/internal/user"
+)
+
+func TestCanSeeHidden(t *testing.T) {
+	author := uuid.New()
+	dbChirp := database.Chirp{ID: uuid.New(), UserID: author, HiddenAt: sql.NullTime{Time: time.Now(), Valid: true}}
+	suspended := sql.NullTime{Time: time.Now(), Valid: true}
+
+	tests := []struct {
+		name   string
+		viewer database.User
+		want   bool
+	}{
+		{"Author", database.User{ID: author, Role: user.RoleUser}, true},
+		{"Suspended author", database.User{ID: author, Role: user.RoleUser, SuspendedAt: suspended}, true},
+		{"Other user", database.User{ID: uuid.New(), Role: user.RoleUser}, false},
+		{"Moderator", database.User{ID: uuid.New(), Role: user.RoleModerator}, true},
+		{"Admin", database.User{ID: uuid.New(), Role: user.RoleAdmin}, true},
+		{"Suspended moderator", database.User{ID: uuid.New(), Role: user.RoleModerator, SuspendedAt: suspended}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := CanSeeHidden(dbChirp, tt.viewer); got != tt.want {
+				t.Errorf("Expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
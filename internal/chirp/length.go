@@ -1,10 +1,189 @@
 package chirp
 
-import "fmt"
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
 
-func ValidateLength(body string) error {
-	if len(body) > 140 {
-		return fmt.Errorf("Chirp is too long. Max 140 chars. Actual: %d", len(body))
+	"github.com/rivo/uniseg"
+	"golang.org/x/text/unicode/norm"
+)
+
+const (
+	// MaxLength is in user-perceived characters, see Length.
+	MaxLength = 140
+	// MaxBytes bounds the encoded text before it's normalised and counted,
+	// characters can be made of any number of combining marks.
+	MaxBytes = 4 << 10
+	// URLWeight is what every link counts for, however long it is.
+	URLWeight = 23
+)
+
+// Validation error codes.
+const (
+	CodeEmpty            = "empty"
+	CodeTooLong          = "too_long"
+	CodeInvalidEncoding  = "invalid_encoding"
+	CodeInvalidCharacter = "invalid_character"
+)
+
+// ValidationError is a single problem with a chirp body. Offset is the
+// position of the offending character in the normalised body, counted in
+// grapheme clusters. Unlike Length, links aren't weighted.
+type ValidationError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Max     int    `json:"max,omitempty"`
+	Length  int    `json:"length,omitempty"`
+	Offset  *int   `json:"offset,omitempty"`
+}
+
+func (e *ValidationError) Error() string {
+	return e.Message
+}
+
+// ValidationErrors is every problem found with a chirp body.
+type ValidationErrors []*ValidationError
+
+func (e ValidationErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Message
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// JoinErrors combines the ValidationErrors among errs, any other error is
+// returned as is.
+func JoinErrors(errs ...error) error {
+	var joined ValidationErrors
+	for _, err := range errs {
+		if err == nil {
+			continue
+		}
+		verrs, ok := err.(ValidationErrors)
+		if !ok {
+			return err
+		}
+		joined = append(joined, verrs...)
+	}
+	if len(joined) == 0 {
+		return nil
+	}
+	return joined
+}
+
+var urlPattern = regexp.MustCompile(`(?i)\b(?:https?://|www\.)[^\s<>"]+`)
+
+// Normalize puts body in NFC so the same text is always stored and counted
+// the same way, however it was typed.
+func Normalize(body string) string {
+	return norm.NFC.String(body)
+}
+
+// Length counts grapheme clusters, so an emoji or a letter with combining
+// accents is one character, and counts every URL as URLWeight.
+func Length(body string) int {
+	n := 0
+	last := 0
+	for _, loc := range urls(body) {
+		n += uniseg.GraphemeClusterCount(body[last:loc[0]]) + URLWeight
+		last = loc[1]
+	}
+	return n + uniseg.GraphemeClusterCount(body[last:])
+}
+
+// Validate normalises body and checks it, the returned body is what should be
+// stored. The error is ValidationErrors when body is invalid.
+func Validate(body string) (string, error) {
+	return ValidateText(body, "Chirp")
+}
+
+// ValidateText is Validate for other text held to the same rules, such as
+// direct messages. name starts the error messages.
+func ValidateText(body, name string) (string, error) {
+	if len(body) > MaxBytes {
+		return "", ValidationErrors{tooManyBytes("body", name, MaxBytes, len(body))}
+	}
+	if !utf8.ValidString(body) {
+		return "", ValidationErrors{{Field: "body", Code: CodeInvalidEncoding, Message: name + " is not valid UTF-8"}}
+	}
+	body = Normalize(body)
+	if strings.TrimFunc(body, isBlank) == "" {
+		return "", ValidationErrors{{Field: "body", Code: CodeEmpty, Message: name + " is empty"}}
+	}
+
+	var errs ValidationErrors
+	if offset, r, ok := findControl(body); ok {
+		errs = append(errs, &ValidationError{
+			Field:   "body",
+			Code:    CodeInvalidCharacter,
+			Message: fmt.Sprintf("%s contains a control character %U at position %d", name, r, offset),
+			Offset:  &offset,
+		})
+	}
+	if n := Length(body); n > MaxLength {
+		errs = append(errs, &ValidationError{
+			Field:   "body",
+			Code:    CodeTooLong,
+			Message: fmt.Sprintf("%s is too long. Max %d chars. Actual: %d", name, MaxLength, n),
+			Max:     MaxLength,
+			Length:  n,
+		})
+	}
+	if len(errs) > 0 {
+		return "", errs
+	}
+	return body, nil
+}
+
+func tooManyBytes(field, name string, max, n int) *ValidationError {
+	return &ValidationError{
+		Field:   field,
+		Code:    CodeTooLong,
+		Message: fmt.Sprintf("%s is too long. Max %d bytes. Actual: %d", name, max, n),
+		Max:     max,
+		Length:  n,
 	}
-	return nil
+}
+
+// urls returns the byte ranges of links, without trailing punctuation that
+// usually ends the sentence rather than the link.
+func urls(body string) [][]int {
+	locs := urlPattern.FindAllStringIndex(body, -1)
+	for _, loc := range locs {
+		loc[1] = loc[0] + len(strings.TrimRight(body[loc[0]:loc[1]], ".,;:!?)]}'"))
+	}
+	return locs
+}
+
+// findControl finds the first control character other than newlines and
+// tabs, or bidi override that can make text display in a different order.
+func findControl(body string) (int, rune, bool) {
+	offset := 0
+	state := -1
+	for rest := body; rest != ""; offset++ {
+		var cluster string
+		cluster, rest, _, state = uniseg.FirstGraphemeClusterInString(rest, state)
+		for _, r := range cluster {
+			if r == '\n' || r == '\t' {
+				continue
+			}
+			if unicode.IsControl(r) || isBidiControl(r) {
+				return offset, r, true
+			}
+		}
+	}
+	return 0, 0, false
+}
+
+func isBidiControl(r rune) bool {
+	return (r >= '\u202a' && r <= '\u202e') || (r >= '\u2066' && r <= '\u2069')
+}
+
+func isBlank(r rune) bool {
+	return unicode.IsSpace(r) || r == '\u200b' || r == '\u200c' || r == '\u200d' || r == '\u2060' || r == '\ufeff'
 }
@@ -0,0 +1,98 @@
+package chirp
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestLength(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want int
+	}{
+		{"ASCII", "Gale!", 5},
+		{"Emoji", "👍🏽🇬🇧👨‍👩‍👧", 3},
+		{"Combining accents", "e\u0301e\u0308\u0301", 2},
+		{"Precomposed accents", "\u00e9\u00eb", 2},
+		{"URL", "look https://example.com/a/very/long/path?with=query", 5 + URLWeight},
+		{"URL trailing punctuation", "see www.example.com.", 4 + URLWeight + 1},
+		{"Two URLs", "http://a.io http://b.io", 2*URLWeight + 1},
+		{"Newlines", "a\nb", 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Length(tt.body); got != tt.want {
+				t.Errorf("Expected %d, got %d", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name  string
+		body  string
+		want  string
+		codes []string
+	}{
+		{"Valid", "Gale!", "Gale!", nil},
+		{"Max length", strings.Repeat("a", MaxLength), strings.Repeat("a", MaxLength), nil},
+		{"Emoji at max length", strings.Repeat("👨‍👩‍👧", MaxLength), strings.Repeat("👨‍👩‍👧", MaxLength), nil},
+		{"Normalised to NFC", "cafe\u0301", "caf\u00e9", nil},
+		{"Multiline", "line one\n\tline two", "line one\n\tline two", nil},
+		{"Too long", strings.Repeat("a", MaxLength+1), "", []string{CodeTooLong}},
+		{"Too many bytes", "a" + strings.Repeat("\u0301", MaxBytes/2), "", []string{CodeTooLong}},
+		{"Long URL fits", strings.Repeat("a", 100) + " https://example.com/" + strings.Repeat("b", 200), strings.Repeat("a", 100) + " https://example.com/" + strings.Repeat("b", 200), nil},
+		{"Empty", "", "", []string{CodeEmpty}},
+		{"Whitespace", " \n\t ", "", []string{CodeEmpty}},
+		{"Zero width only", "\u200b\u200d", "", []string{CodeEmpty}},
+		{"Control character", "bell\a", "", []string{CodeInvalidCharacter}},
+		{"Bidi override", "abc\u202edef", "", []string{CodeInvalidCharacter}},
+		{"Invalid UTF-8", "abc\xff", "", []string{CodeInvalidEncoding}},
+		{"Several problems", "\x00" + strings.Repeat("a", MaxLength), "", []string{CodeInvalidCharacter, CodeTooLong}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Validate(tt.body)
+			if got != tt.want {
+				t.Errorf("Expected body %q, got %q", tt.want, got)
+			}
+			var errs ValidationErrors
+			if err != nil && !errors.As(err, &errs) {
+				t.Fatalf("Expected ValidationErrors, got %T", err)
+			}
+			if len(errs) != len(tt.codes) {
+				t.Fatalf("Expected %d errors, got %v", len(tt.codes), err)
+			}
+			for i, code := range tt.codes {
+				if errs[i].Code != code {
+					t.Errorf("Expected code %s, got %s", code, errs[i].Code)
+				}
+			}
+		})
+	}
+}
+
+func TestValidateOffset(t *testing.T) {
+	_, err := Validate("👍🏽e\u0301x\x07")
+	var errs ValidationErrors
+	if !errors.As(err, &errs) {
+		t.Fatalf("Expected ValidationErrors, got %v", err)
+	}
+	if errs[0].Offset == nil || *errs[0].Offset != 3 {
+		t.Errorf("Expected offset 3, got %v", errs[0].Offset)
+	}
+}
+
+func TestValidateText(t *testing.T) {
+	_, err := ValidateText(strings.Repeat("a", MaxLength+1), "Message")
+	want := fmt.Sprintf("Message is too long. Max %d chars. Actual: %d", MaxLength, MaxLength+1)
+	if err == nil || err.Error() != want {
+		t.Errorf("Expected %q, got %v", want, err)
+	}
+}
@@ -0,0 +1,131 @@
+package chirp
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+
+	"github.com/gskll/chirpy2/internal/database"
+)
+
+const (
+	// MaxMedia is how many images a chirp can have.
+	MaxMedia = 4
+	// MaxAltText is in characters, see Length.
+	MaxAltText = 1000
+	// MaxAltTextBytes is like MaxBytes for alt text.
+	MaxAltTextBytes = 16 << 10
+)
+
+// MediaPath is where uploads are served, followed by their id.
+const MediaPath = "/api/media/"
+
+// Media is an image attached to a chirp. Its thumbnails and blurhash are
+// generated in the background after upload, until then they're missing.
+type Media struct {
+	ID          uuid.UUID   `json:"id"`
+	URL         string      `json:"url"`
+	ContentType string      `json:"content_type"`
+	Width       int32       `json:"width"`
+	Height      int32       `json:"height"`
+	AltText     string      `json:"alt_text"`
+	Blurhash    string      `json:"blurhash,omitempty"`
+	Thumbnails  []Thumbnail `json:"thumbnails"`
+}
+
+func NewMedia(dbMedia database.MediaUpload) Media {
+	return Media{
+		ID:          dbMedia.ID,
+		URL:         MediaPath + dbMedia.ID.String(),
+		ContentType: dbMedia.ContentType,
+		Width:       dbMedia.Width,
+		Height:      dbMedia.Height,
+		AltText:     dbMedia.AltText,
+		Blurhash:    dbMedia.Blurhash,
+		Thumbnails:  []Thumbnail{},
+	}
+}
+
+// Thumbnail is a scaled down copy of an image, only made in sizes smaller
+// than the image.
+type Thumbnail struct {
+	Size        string `json:"size"`
+	URL         string `json:"url"`
+	ContentType string `json:"content_type"`
+	Width       int32  `json:"width"`
+	Height      int32  `json:"height"`
+}
+
+func NewThumbnail(dbThumbnail database.MediaThumbnail) Thumbnail {
+	return Thumbnail{
+		Size:        dbThumbnail.Size,
+		URL:         MediaPath + dbThumbnail.MediaID.String() + "/thumbnails/" + dbThumbnail.Size,
+		ContentType: dbThumbnail.ContentType,
+		Width:       dbThumbnail.Width,
+		Height:      dbThumbnail.Height,
+	}
+}
+
+// MediaRef is an upload to attach to a new chirp, with its description for
+// screen readers.
+type MediaRef struct {
+	ID      uuid.UUID `json:"id"`
+	AltText string    `json:"alt_text"`
+}
+
+// ValidateMedia normalises alt text like chirp bodies. The error is
+// ValidationErrors when refs are invalid.
+func ValidateMedia(refs []MediaRef) ([]MediaRef, error) {
+	var errs ValidationErrors
+	if len(refs) > MaxMedia {
+		errs = append(errs, &ValidationError{
+			Field:   "media",
+			Code:    CodeTooMany,
+			Message: fmt.Sprintf("Chirp has too many images. Max %d. Actual: %d", MaxMedia, len(refs)),
+			Max:     MaxMedia,
+			Length:  len(refs),
+		})
+	}
+
+	seen := make(map[uuid.UUID]bool, len(refs))
+	valid := make([]MediaRef, 0, len(refs))
+	for _, ref := range refs {
+		if ref.ID == uuid.Nil || seen[ref.ID] {
+			errs = append(errs, &ValidationError{
+				Field:   "media",
+				Code:    CodeInvalidValue,
+				Message: "Every image must have a different id",
+			})
+			continue
+		}
+		seen[ref.ID] = true
+
+		if len(ref.AltText) > MaxAltTextBytes {
+			errs = append(errs, tooManyBytes("media", "Alt text", MaxAltTextBytes, len(ref.AltText)))
+			continue
+		}
+		if !utf8.ValidString(ref.AltText) {
+			errs = append(errs, &ValidationError{Field: "media", Code: CodeInvalidEncoding, Message: "Alt text is not valid UTF-8"})
+			continue
+		}
+		ref.AltText = strings.TrimSpace(Normalize(ref.AltText))
+		if n := Length(ref.AltText); n > MaxAltText {
+			errs = append(errs, &ValidationError{
+				Field:   "media",
+				Code:    CodeTooLong,
+				Message: fmt.Sprintf("Alt text is too long. Max %d chars. Actual: %d", MaxAltText, n),
+				Max:     MaxAltText,
+				Length:  n,
+			})
+			continue
+		}
+		valid = append(valid, ref)
+	}
+
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	return valid, nil
+}
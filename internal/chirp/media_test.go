@@ -0,0 +1,61 @@
+package chirp
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestValidateMedia(t *testing.T) {
+	id := uuid.New()
+	refs := func(n int) []MediaRef {
+		r := make([]MediaRef, n)
+		for i := range r {
+			r[i] = MediaRef{ID: uuid.New()}
+		}
+		return r
+	}
+
+	tests := []struct {
+		name  string
+		refs  []MediaRef
+		alt   string
+		codes []string
+	}{
+		{"None", nil, "", nil},
+		{"Max images", refs(MaxMedia), "", nil},
+		{"Alt text normalised", []MediaRef{{ID: id, AltText: "  cafe\u0301 "}}, "caf\u00e9", nil},
+		{"Too many images", refs(MaxMedia + 1), "", []string{CodeTooMany}},
+		{"Duplicate", []MediaRef{{ID: id}, {ID: id}}, "", []string{CodeInvalidValue}},
+		{"Missing id", []MediaRef{{AltText: "a dog"}}, "", []string{CodeInvalidValue}},
+		{"Alt text too long", []MediaRef{{ID: id, AltText: strings.Repeat("a", MaxAltText+1)}}, "", []string{CodeTooLong}},
+		{"Alt text too many bytes", []MediaRef{{ID: id, AltText: "a" + strings.Repeat("\u0301", MaxAltTextBytes/2)}}, "", []string{CodeTooLong}},
+		{"Alt text invalid UTF-8", []MediaRef{{ID: id, AltText: "\xff"}}, "", []string{CodeInvalidEncoding}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ValidateMedia(tt.refs)
+			var errs ValidationErrors
+			if err != nil && !errors.As(err, &errs) {
+				t.Fatalf("Expected ValidationErrors, got %T", err)
+			}
+			if len(errs) != len(tt.codes) {
+				t.Fatalf("Expected %d errors, got %v", len(tt.codes), err)
+			}
+			for i, code := range tt.codes {
+				if errs[i].Code != code {
+					t.Errorf("Expected code %s, got %s", code, errs[i].Code)
+				}
+			}
+			if err == nil && len(got) != len(tt.refs) {
+				t.Errorf("Expected %d refs, got %d", len(tt.refs), len(got))
+			}
+			if tt.alt != "" && got[0].AltText != tt.alt {
+				t.Errorf("Expected alt text %q, got %q", tt.alt, got[0].AltText)
+			}
+		})
+	}
+}
@@ -1,21 +1,95 @@
 package config
 
 import (
-	"sync/atomic"
+	"context"
+	"database/sql"
+	"net/netip"
+	"time"
 
 	"github.com/gskll/chirpy2/internal/database"
+	"github.com/gskll/chirpy2/internal/events"
+	"github.com/gskll/chirpy2/internal/filter"
+	"github.com/gskll/chirpy2/internal/health"
+	"github.com/gskll/chirpy2/internal/media"
+	"github.com/gskll/chirpy2/internal/metrics"
+	"github.com/gskll/chirpy2/internal/storage"
+	"github.com/gskll/chirpy2/internal/tracing"
 )
 
 const DEV = "dev"
 
+const eventHistorySize = 256
+
+const (
+	DefaultMaxBodyBytes   = 1 << 20
+	DefaultMediaMaxBytes  = 8 << 20
+	DefaultRequestTimeout = 10 * time.Second
+
+	DefaultAccessTokenTTL  = time.Hour
+	DefaultRefreshTokenTTL = 60 * 24 * time.Hour
+
+	DefaultReadyCheckTimeout = 2 * time.Second
+)
+
 type ApiConfig struct {
-	FileServerHits atomic.Int32
-	Db             *database.Queries
+	Db *database.Queries
+	// DB backs Db, it's only used to start transactions.
+	DB             *sql.DB
 	Platform       string
 	JWTSecret      string
 	PolkaKey       string
+	Events         events.Broker
+	TrustedProxies []netip.Prefix
+	Metrics        *metrics.Metrics
+	Health         *health.Checker
+	MaxBodyBytes   int64
+	RequestTimeout time.Duration
+	// AllowedOrigins are the CORS origins, "*" allows any.
+	AllowedOrigins  []string
+	AccessTokenTTL  time.Duration
+	RefreshTokenTTL time.Duration
+	// Filter holds the content filter rules, they're reloaded when edited.
+	Filter *filter.Loader
+	// Storage holds uploaded media.
+	Storage       storage.Storage
+	MaxMediaBytes int64
+	// Thumbnails generates thumbnails of uploads in the background.
+	Thumbnails *media.Thumbnailer
+	// Shutdown is closed when the server starts shutting down, long-lived
+	// streams end when it is.
+	Shutdown <-chan struct{}
 }
 
 func NewApiConfig(db *database.Queries, platform, jwtSecret, polkaKey string) *ApiConfig {
-	return &ApiConfig{Db: db, Platform: platform, JWTSecret: jwtSecret, PolkaKey: polkaKey}
+	return &ApiConfig{
+		Db:        db,
+		Platform:  platform,
+		JWTSecret: jwtSecret,
+		PolkaKey:  polkaKey,
+		Events:    events.NewMemoryBroker(eventHistorySize),
+		Health:    health.NewChecker(DefaultReadyCheckTimeout),
+		Filter:    filter.DefaultLoader(),
+
+		MaxBodyBytes:   DefaultMaxBodyBytes,
+		MaxMediaBytes:  DefaultMediaMaxBytes,
+		RequestTimeout: DefaultRequestTimeout,
+
+		AllowedOrigins:  []string{"*"},
+		AccessTokenTTL:  DefaultAccessTokenTTL,
+		RefreshTokenTTL: DefaultRefreshTokenTTL,
+	}
+}
+
+// Tx runs fn with queries bound to a transaction, committing if fn returns nil.
+func (cfg *ApiConfig) Tx(ctx context.Context, fn func(q *database.Queries) error) error {
+	tx, err := cfg.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(database.New(tracing.WrapDB(tx))); err != nil {
+		return err
+	}
+	return tx.Commit()
 }
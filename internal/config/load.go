@@ -0,0 +1,457 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"net"
+	"net/netip"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/gskll/chirpy2/internal/filter"
+)
+
+const minSecretLength = 32
+
+// Config holds every tunable setting. Values are resolved with the
+// precedence flags > environment > config file > defaults.
+type Config struct {
+	ConfigFile  string
+	PrintOnly   bool
+	AutoMigrate bool
+
+	Port        int
+	MetricsAddr string
+	PublicRoot  string
+	Platform    string
+
+	DBURL             string
+	DBMaxOpenConns    int
+	DBMaxIdleConns    int
+	DBConnMaxLifetime time.Duration
+	DBConnMaxIdleTime time.Duration
+
+	JWTSecret       string
+	PolkaKey        string
+	AccessTokenTTL  time.Duration
+	RefreshTokenTTL time.Duration
+
+	ReadHeaderTimeout time.Duration
+	ReadTimeout       time.Duration
+	WriteTimeout      time.Duration
+	IdleTimeout       time.Duration
+	ShutdownTimeout   time.Duration
+	ShutdownDelay     time.Duration
+	ReadyCheckTimeout time.Duration
+	RequestTimeout    time.Duration
+	MaxBodyBytes      int64
+
+	CORSAllowedOrigins string
+	TrustedProxies     string
+	RateLimitStore     string
+
+	FilterWords          string
+	FilterReloadInterval time.Duration
+
+	MediaStorage  string
+	MediaDir      string
+	MediaMaxBytes int64
+	MediaWorkers  int
+	S3Endpoint    string
+	S3Bucket      string
+	S3Region      string
+	S3AccessKey   string
+	S3SecretKey   string
+	S3UseSSL      bool
+
+	LogFormat     string
+	LogLevel      string
+	TraceExporter string
+
+	flags *flag.FlagSet
+	// settings are the flags above, as opposed to a subcommand's own flags
+	settings map[string]bool
+	sources  map[string]string
+}
+
+// secrets are redacted by Print.
+var secrets = []string{"db-url", "jwt-secret", "polka-key", "s3-secret-key"}
+
+func newFlagSet(c *Config) *flag.FlagSet {
+	fs := flag.NewFlagSet("chirpy", flag.ContinueOnError)
+
+	fs.StringVar(&c.ConfigFile, "config", "", "path to a YAML config file")
+	fs.BoolVar(&c.PrintOnly, "print-config", false, "print the effective config with secrets redacted and exit")
+	fs.BoolVar(&c.AutoMigrate, "auto-migrate", false, "apply pending migrations before serving")
+
+	fs.IntVar(&c.Port, "port", 8080, "port to listen on")
+	fs.StringVar(&c.MetricsAddr, "metrics-addr", "127.0.0.1:9090", `address serving /metrics apart from the API, "" to disable`)
+	fs.StringVar(&c.PublicRoot, "public-root", "./public", "directory served under /app")
+	fs.StringVar(&c.Platform, "platform", "", `"dev" enables the admin reset endpoint`)
+
+	fs.StringVar(&c.DBURL, "db-url", "", "postgres connection string (required)")
+	fs.IntVar(&c.DBMaxOpenConns, "db-max-open-conns", 25, "max open database connections, 0 for unlimited")
+	fs.IntVar(&c.DBMaxIdleConns, "db-max-idle-conns", 5, "max idle database connections")
+	fs.DurationVar(&c.DBConnMaxLifetime, "db-conn-max-lifetime", 30*time.Minute, "max lifetime of a database connection")
+	fs.DurationVar(&c.DBConnMaxIdleTime, "db-conn-max-idle-time", 5*time.Minute, "max idle time of a database connection")
+
+	fs.StringVar(&c.JWTSecret, "jwt-secret", "", fmt.Sprintf("secret for signing access tokens, at least %d bytes (required)", minSecretLength))
+	fs.StringVar(&c.PolkaKey, "polka-key", "", "api key for the polka webhook (required)")
+	fs.DurationVar(&c.AccessTokenTTL, "access-token-ttl", DefaultAccessTokenTTL, "access token lifetime")
+	fs.DurationVar(&c.RefreshTokenTTL, "refresh-token-ttl", DefaultRefreshTokenTTL, "refresh token lifetime")
+
+	fs.DurationVar(&c.ReadHeaderTimeout, "server-read-header-timeout", 5*time.Second, "time to read request headers")
+	fs.DurationVar(&c.ReadTimeout, "server-read-timeout", 15*time.Second, "time to read the whole request")
+	fs.DurationVar(&c.WriteTimeout, "server-write-timeout", 30*time.Second, "time to write the response")
+	fs.DurationVar(&c.IdleTimeout, "server-idle-timeout", 2*time.Minute, "keep-alive idle timeout")
+	fs.DurationVar(&c.ShutdownTimeout, "shutdown-timeout", 30*time.Second, "time to drain requests on shutdown")
+	fs.DurationVar(&c.ShutdownDelay, "shutdown-delay", 0, "time to report not ready before closing the listener on shutdown")
+	fs.DurationVar(&c.ReadyCheckTimeout, "ready-check-timeout", DefaultReadyCheckTimeout, "timeout for each readiness check")
+	fs.DurationVar(&c.RequestTimeout, "request-timeout", DefaultRequestTimeout, "deadline for each API request")
+	fs.Int64Var(&c.MaxBodyBytes, "max-body-bytes", DefaultMaxBodyBytes, "default request body limit")
+
+	fs.StringVar(&c.CORSAllowedOrigins, "cors-allowed-origins", "*", `comma separated origins allowed by CORS, "*" for any`)
+	fs.StringVar(&c.TrustedProxies, "trusted-proxies", "", "comma separated CIDRs allowed to set X-Forwarded-For")
+	fs.StringVar(&c.RateLimitStore, "rate-limit-store", "memory", `"memory" or "postgres"`)
+
+	fs.StringVar(&c.FilterWords, "filter-words", "", "content filter word list, one \"<mask|reject|flag> <pattern>\" per line, built-in list if empty")
+	fs.DurationVar(&c.FilterReloadInterval, "filter-reload-interval", time.Minute, "how often to reload filter rules edited on other instances, 0 to disable")
+
+	fs.StringVar(&c.MediaStorage, "media-storage", "local", `where uploaded media is stored, "local" or "s3"`)
+	fs.StringVar(&c.MediaDir, "media-dir", "./media", "directory for local media storage, created if missing")
+	fs.Int64Var(&c.MediaMaxBytes, "media-max-bytes", DefaultMediaMaxBytes, "largest image upload")
+	fs.IntVar(&c.MediaWorkers, "media-workers", 2, "workers generating thumbnails of uploads")
+	fs.StringVar(&c.S3Endpoint, "s3-endpoint", "", `S3-compatible host and port without a scheme, e.g. "s3.us-east-1.amazonaws.com" or "localhost:9000"`)
+	fs.StringVar(&c.S3Bucket, "s3-bucket", "", "bucket for media, must already exist")
+	fs.StringVar(&c.S3Region, "s3-region", "us-east-1", "bucket region")
+	fs.StringVar(&c.S3AccessKey, "s3-access-key", "", "S3 access key id")
+	fs.StringVar(&c.S3SecretKey, "s3-secret-key", "", "S3 secret access key")
+	fs.BoolVar(&c.S3UseSSL, "s3-use-ssl", true, "connect to the S3 endpoint over TLS")
+
+	fs.StringVar(&c.LogFormat, "log-format", "text", `"text" or "json"`)
+	fs.StringVar(&c.LogLevel, "log-level", "info", `"debug", "info", "warn" or "error"`)
+	fs.StringVar(&c.TraceExporter, "trace-exporter", "", `"" to disable, "otlp" or "stdout"`)
+
+	return fs
+}
+
+// Load resolves the config from args, the environment and the optional config
+// file, then validates it.
+func Load(args []string, getenv func(string) string) (*Config, error) {
+	c, err := Parse(args, getenv)
+	if err != nil {
+		return nil, err
+	}
+	if c.PrintOnly {
+		return c, nil
+	}
+	return c, c.Validate()
+}
+
+// Parse resolves the config like Load without validating it, for commands
+// that only need part of it.
+func Parse(args []string, getenv func(string) string) (*Config, error) {
+	return ParseWith(args, getenv, nil)
+}
+
+// ParseWith is Parse for subcommands that register their own flags, those are
+// only read from args, never from the environment or the config file.
+func ParseWith(args []string, getenv func(string) string, register func(fs *flag.FlagSet)) (*Config, error) {
+	c := &Config{sources: make(map[string]string)}
+	c.flags = newFlagSet(c)
+	c.settings = make(map[string]bool)
+	c.flags.VisitAll(func(f *flag.Flag) { c.settings[f.Name] = true })
+	if register != nil {
+		register(c.flags)
+	}
+
+	if err := c.flags.Parse(args); err != nil {
+		return nil, err
+	}
+	explicit := make(map[string]bool)
+	c.flags.Visit(func(f *flag.Flag) {
+		explicit[f.Name] = true
+		c.sources[f.Name] = "flag"
+	})
+
+	if !explicit["config"] {
+		c.ConfigFile = getenv(EnvName("config"))
+	}
+	if c.ConfigFile != "" {
+		values, err := readFile(c.ConfigFile)
+		if err != nil {
+			return nil, err
+		}
+		for key, value := range values {
+			name := strings.ReplaceAll(key, "_", "-")
+			if !c.settings[name] || name == "config" {
+				return nil, fmt.Errorf("%s: unknown setting %q", c.ConfigFile, key)
+			}
+			if explicit[name] {
+				continue
+			}
+			if err := c.flags.Set(name, value); err != nil {
+				return nil, fmt.Errorf("%s: %s: %w", c.ConfigFile, key, err)
+			}
+			c.sources[name] = "file"
+		}
+	}
+
+	var errs []error
+	c.flags.VisitAll(func(f *flag.Flag) {
+		if explicit[f.Name] || !c.settings[f.Name] || f.Name == "config" {
+			return
+		}
+		value := getenv(EnvName(f.Name))
+		if value == "" {
+			return
+		}
+		if err := c.flags.Set(f.Name, value); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", EnvName(f.Name), err))
+			return
+		}
+		c.sources[f.Name] = "env"
+	})
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return c, nil
+}
+
+// Args are the arguments left after the flags.
+func (c *Config) Args() []string {
+	return c.flags.Args()
+}
+
+// Usage prints the flag defaults.
+func (c *Config) Usage(w io.Writer) {
+	c.flags.SetOutput(w)
+	c.flags.PrintDefaults()
+}
+
+// EnvName is the environment variable for a setting, e.g. DB_URL for db-url.
+func EnvName(name string) string {
+	return strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+}
+
+func readFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read config file: %w", err)
+	}
+
+	raw := map[string]any{}
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("parse config file %s: %w", path, err)
+	}
+
+	values := make(map[string]string, len(raw))
+	for key, value := range raw {
+		switch v := value.(type) {
+		case []any:
+			items := make([]string, 0, len(v))
+			for _, item := range v {
+				items = append(items, fmt.Sprint(item))
+			}
+			values[key] = strings.Join(items, ",")
+		case nil:
+			values[key] = ""
+		default:
+			values[key] = fmt.Sprint(v)
+		}
+	}
+	return values, nil
+}
+
+func (c *Config) Validate() error {
+	var errs []error
+	fail := func(format string, args ...any) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+
+	if c.Port < 1 || c.Port > 65535 {
+		fail("port must be between 1 and 65535")
+	}
+	if c.MetricsAddr != "" {
+		if _, port, err := net.SplitHostPort(c.MetricsAddr); err != nil || port == strconv.Itoa(c.Port) {
+			fail("metrics-addr %q must be a host:port apart from the API port", c.MetricsAddr)
+		}
+	}
+	if info, err := os.Stat(c.PublicRoot); err != nil || !info.IsDir() {
+		fail("public-root %q is not a directory", c.PublicRoot)
+	}
+
+	if err := c.ValidateDatabase(); err != nil {
+		errs = append(errs, err)
+	}
+
+	if c.JWTSecret == "" {
+		fail("jwt-secret is required")
+	} else if len(c.JWTSecret) < minSecretLength {
+		fail("jwt-secret must be at least %d bytes, generate one with `openssl rand -base64 64`", minSecretLength)
+	}
+	if c.PolkaKey == "" {
+		fail("polka-key is required")
+	}
+
+	durations := map[string]time.Duration{
+		"access-token-ttl":           c.AccessTokenTTL,
+		"refresh-token-ttl":          c.RefreshTokenTTL,
+		"server-read-header-timeout": c.ReadHeaderTimeout,
+		"server-read-timeout":        c.ReadTimeout,
+		"server-write-timeout":       c.WriteTimeout,
+		"server-idle-timeout":        c.IdleTimeout,
+		"shutdown-timeout":           c.ShutdownTimeout,
+		"request-timeout":            c.RequestTimeout,
+		"ready-check-timeout":        c.ReadyCheckTimeout,
+	}
+	for _, name := range slices.Sorted(maps.Keys(durations)) {
+		if durations[name] <= 0 {
+			fail("%s must be positive", name)
+		}
+	}
+	if c.ShutdownDelay < 0 {
+		fail("shutdown-delay must not be negative")
+	}
+	if c.MaxBodyBytes <= 0 {
+		fail("max-body-bytes must be positive")
+	}
+
+	for _, origin := range c.AllowedOrigins() {
+		if origin == "*" {
+			continue
+		}
+		if u, err := url.Parse(origin); err != nil || u.Scheme == "" || u.Host == "" || u.Path != "" {
+			fail("cors-allowed-origins: invalid origin %q", origin)
+		}
+	}
+	if _, err := c.ProxyPrefixes(); err != nil {
+		errs = append(errs, err)
+	}
+	if c.RateLimitStore != "memory" && c.RateLimitStore != "postgres" {
+		fail(`rate-limit-store must be "memory" or "postgres"`)
+	}
+	if c.FilterWords != "" {
+		if _, err := filter.LoadFile(c.FilterWords); err != nil {
+			fail("filter-words: %v", err)
+		}
+	}
+	if c.FilterReloadInterval < 0 {
+		fail("filter-reload-interval must not be negative")
+	}
+
+	switch c.MediaStorage {
+	case "local":
+		if c.MediaDir == "" {
+			fail("media-dir is required for local media storage")
+		}
+	case "s3":
+		required := map[string]string{
+			"s3-endpoint":   c.S3Endpoint,
+			"s3-bucket":     c.S3Bucket,
+			"s3-access-key": c.S3AccessKey,
+			"s3-secret-key": c.S3SecretKey,
+		}
+		for _, name := range slices.Sorted(maps.Keys(required)) {
+			if required[name] == "" {
+				fail("%s is required for s3 media storage", name)
+			}
+		}
+		if strings.Contains(c.S3Endpoint, "://") {
+			fail("s3-endpoint must not include a scheme, use s3-use-ssl")
+		}
+	default:
+		fail(`media-storage must be "local" or "s3"`)
+	}
+	if c.MediaMaxBytes <= 0 {
+		fail("media-max-bytes must be positive")
+	}
+	if c.MediaWorkers < 1 {
+		fail("media-workers must be at least 1")
+	}
+
+	if c.LogFormat != "text" && c.LogFormat != "json" {
+		fail(`log-format must be "text" or "json"`)
+	}
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(c.LogLevel)); err != nil {
+		fail("log-level: %v", err)
+	}
+	if c.TraceExporter != "" && c.TraceExporter != "otlp" && c.TraceExporter != "stdout" {
+		fail(`trace-exporter must be empty, "otlp" or "stdout"`)
+	}
+
+	return errors.Join(errs...)
+}
+
+// ValidateDatabase checks only the settings needed to connect to the database.
+func (c *Config) ValidateDatabase() error {
+	var errs []error
+	if c.DBURL == "" {
+		errs = append(errs, errors.New("db-url is required"))
+	} else if u, err := url.Parse(c.DBURL); err != nil || (u.Scheme != "postgres" && u.Scheme != "postgresql") {
+		errs = append(errs, errors.New("db-url must be a postgres:// connection string"))
+	}
+	if c.DBMaxOpenConns < 0 || c.DBMaxIdleConns < 0 {
+		errs = append(errs, errors.New("db pool sizes must not be negative"))
+	}
+	if c.DBMaxOpenConns > 0 && c.DBMaxIdleConns > c.DBMaxOpenConns {
+		errs = append(errs, errors.New("db-max-idle-conns must not exceed db-max-open-conns"))
+	}
+	return errors.Join(errs...)
+}
+
+func (c *Config) AllowedOrigins() []string {
+	return splitList(c.CORSAllowedOrigins)
+}
+
+func (c *Config) ProxyPrefixes() ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+	for _, cidr := range splitList(c.TrustedProxies) {
+		prefix, err := netip.ParsePrefix(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("trusted-proxies: %w", err)
+		}
+		prefixes = append(prefixes, prefix)
+	}
+	return prefixes, nil
+}
+
+// Print writes the effective config as YAML, usable as a config file, with
+// secrets redacted and the source of each value.
+func (c *Config) Print(w io.Writer) {
+	c.flags.VisitAll(func(f *flag.Flag) {
+		if !c.settings[f.Name] || f.Name == "config" || f.Name == "print-config" {
+			return
+		}
+		value := f.Value.String()
+		if slices.Contains(secrets, f.Name) && value != "" {
+			value = "[REDACTED]"
+		}
+		source := c.sources[f.Name]
+		if source == "" {
+			source = "default"
+		}
+		fmt.Fprintf(w, "%s: %s # %s\n", strings.ReplaceAll(f.Name, "-", "_"), strconv.Quote(value), source)
+	})
+}
+
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,218 @@
+package config
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testSecret = "0123456789abcdef0123456789abcdef"
+
+func env(values map[string]string) func(string) string {
+	return func(key string) string { return values[key] }
+}
+
+func validEnv() map[string]string {
+	return map[string]string{
+		"DB_URL":      "postgres://localhost:5432/chirpy",
+		"JWT_SECRET":  testSecret,
+		"POLKA_KEY":   "f271c81ff7084ee5b99a5091b42d486e",
+		"PUBLIC_ROOT": os.TempDir(),
+	}
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "chirpy.yaml")
+	err := os.WriteFile(file, []byte(`
+port: 9000
+request_timeout: 3s
+log_level: debug
+cors_allowed_origins:
+  - https://a.example
+  - https://b.example
+`), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	values := validEnv()
+	values["CONFIG"] = file
+	values["REQUEST_TIMEOUT"] = "4s"
+	values["LOG_LEVEL"] = "warn"
+
+	c, err := Load([]string{"-log-level", "error"}, env(values))
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	if c.Port != 9000 {
+		t.Errorf("Expected port from file 9000, got %d", c.Port)
+	}
+	if c.RequestTimeout != 4*time.Second {
+		t.Errorf("Expected request timeout from env 4s, got %s", c.RequestTimeout)
+	}
+	if c.LogLevel != "error" {
+		t.Errorf("Expected log level from flag error, got %s", c.LogLevel)
+	}
+	if got := c.AllowedOrigins(); len(got) != 2 || got[1] != "https://b.example" {
+		t.Errorf("Expected origins from file list, got %v", got)
+	}
+	if c.AccessTokenTTL != DefaultAccessTokenTTL {
+		t.Errorf("Expected default access token ttl, got %s", c.AccessTokenTTL)
+	}
+}
+
+func TestLoadValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		args    []string
+		wantErr []string
+	}{
+		{
+			name: "Valid",
+			env:  validEnv(),
+		},
+		{
+			name:    "Missing required",
+			env:     map[string]string{"PUBLIC_ROOT": os.TempDir()},
+			wantErr: []string{"db-url is required", "jwt-secret is required", "polka-key is required"},
+		},
+		{
+			name:    "Short secret",
+			env:     validEnv(),
+			args:    []string{"-jwt-secret", "short"},
+			wantErr: []string{"jwt-secret must be at least 32 bytes"},
+		},
+		{
+			name:    "Bad enums and ranges",
+			env:     validEnv(),
+			args:    []string{"-port", "0", "-log-format", "xml", "-rate-limit-store", "redis", "-request-timeout", "0s"},
+			wantErr: []string{"port must be", "log-format must be", "rate-limit-store must be", "request-timeout must be positive"},
+		},
+		{
+			name:    "Bad CIDR and origin",
+			env:     validEnv(),
+			args:    []string{"-trusted-proxies", "10.0.0.0/8,nope", "-cors-allowed-origins", "chirpy.dev"},
+			wantErr: []string{"trusted-proxies", `invalid origin "chirpy.dev"`},
+		},
+		{
+			name:    "Metrics on the API port",
+			env:     validEnv(),
+			args:    []string{"-metrics-addr", ":8080"},
+			wantErr: []string{"metrics-addr"},
+		},
+		{
+			name:    "Missing filter word list",
+			env:     validEnv(),
+			args:    []string{"-filter-words", "/nonexistent/words.txt", "-filter-reload-interval", "-1s"},
+			wantErr: []string{"filter-words", "filter-reload-interval must not be negative"},
+		},
+		{
+			name:    "Incomplete S3 storage",
+			env:     validEnv(),
+			args:    []string{"-media-storage", "s3", "-s3-endpoint", "https://localhost:9000", "-s3-bucket", "media", "-media-max-bytes", "0"},
+			wantErr: []string{"s3-access-key is required", "s3-secret-key is required", "s3-endpoint must not include a scheme", "media-max-bytes must be positive"},
+		},
+		{
+			name:    "Unknown media storage",
+			env:     validEnv(),
+			args:    []string{"-media-storage", "gcs", "-media-workers", "0"},
+			wantErr: []string{"media-storage must be", "media-workers must be at least 1"},
+		},
+		{
+			name:    "Unparseable env value",
+			env:     map[string]string{"REQUEST_TIMEOUT": "soon"},
+			wantErr: []string{"REQUEST_TIMEOUT"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Load(tt.args, env(tt.env))
+			if len(tt.wantErr) == 0 {
+				if err != nil {
+					t.Fatalf("Expected no error, got %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("Expected error, got nil")
+			}
+			for _, want := range tt.wantErr {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("Expected error to contain %q, got %v", want, err)
+				}
+			}
+		})
+	}
+}
+
+func TestLoadUnknownFileKey(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "chirpy.yaml")
+	if err := os.WriteFile(file, []byte("prot: 9000\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := Load([]string{"-config", file}, env(validEnv()))
+	if err == nil || !strings.Contains(err.Error(), `unknown setting "prot"`) {
+		t.Errorf("Expected unknown setting error, got %v", err)
+	}
+}
+
+func TestPrintRedactsSecrets(t *testing.T) {
+	c, err := Load([]string{"-print-config"}, env(validEnv()))
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	var buf bytes.Buffer
+	c.Print(&buf)
+	out := buf.String()
+
+	for _, secret := range []string{testSecret, "f271c81ff7084ee5b99a5091b42d486e", "localhost"} {
+		if strings.Contains(out, secret) {
+			t.Errorf("Expected %q to be redacted in:\n%s", secret, out)
+		}
+	}
+	if !strings.Contains(out, `jwt_secret: "[REDACTED]" # env`) {
+		t.Errorf("Expected redacted jwt_secret with source, got:\n%s", out)
+	}
+	if !strings.Contains(out, `port: "8080" # default`) {
+		t.Errorf("Expected default port, got:\n%s", out)
+	}
+}
+
+func TestParseWithCommandFlags(t *testing.T) {
+	values := validEnv()
+	values["USER"] = "root"
+
+	var user string
+	c, err := ParseWith([]string{"-user", "ada@example.com", "extra"}, env(values), func(fs *flag.FlagSet) {
+		fs.StringVar(&user, "user", "", "")
+	})
+	if err != nil {
+		t.Fatalf("ParseWith failed: %v", err)
+	}
+	if user != "ada@example.com" {
+		t.Errorf("Expected user from args, got %q", user)
+	}
+	if args := c.Args(); len(args) != 1 || args[0] != "extra" {
+		t.Errorf("Expected remaining args [extra], got %v", args)
+	}
+
+	user = ""
+	if _, err := ParseWith(nil, env(values), func(fs *flag.FlagSet) {
+		fs.StringVar(&user, "user", "", "")
+	}); err != nil {
+		t.Fatalf("ParseWith failed: %v", err)
+	}
+	if user != "" {
+		t.Errorf("Expected command flag to ignore the environment, got %q", user)
+	}
+}
@@ -7,29 +7,140 @@ package database
 
 import (
 	"context"
+	"database/sql"
+	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
+const canReplyTo = `-- name: CanReplyTo :one
+SELECT (
+    user_id = $1::uuid
+    OR reply_policy = 'everyone'
+    OR (reply_policy = 'mentioned' AND $1::uuid = ANY(mentions))
+    OR (reply_policy = 'followers' AND EXISTS (
+        SELECT 1 FROM follows
+        WHERE follower_id = $1::uuid AND followed_id = chirps.user_id AND status = 'accepted'
+    ))
+)::bool AS allowed
+FROM chirps
+WHERE id = $2::uuid
+`
+
+type CanReplyToParams struct {
+	UserID uuid.UUID
+	ID     uuid.UUID
+}
+
+func (q *Queries) CanReplyTo(ctx context.Context, arg CanReplyToParams) (bool, error) {
+	row := q.db.QueryRowContext(ctx, canReplyTo, arg.UserID, arg.ID)
+	var allowed bool
+	err := row.Scan(&allowed)
+	return allowed, err
+}
+
+const chirpVisibleTo = `-- name: ChirpVisibleTo :one
+SELECT chirp_visible_to(chirps, $1::uuid)::bool AS visible
+FROM chirps
+WHERE id = $2::uuid
+`
+
+type ChirpVisibleToParams struct {
+	ViewerID uuid.UUID
+	ID       uuid.UUID
+}
+
+func (q *Queries) ChirpVisibleTo(ctx context.Context, arg ChirpVisibleToParams) (bool, error) {
+	row := q.db.QueryRowContext(ctx, chirpVisibleTo, arg.ViewerID, arg.ID)
+	var visible bool
+	err := row.Scan(&visible)
+	return visible, err
+}
+
+const countChirpsByAuthor = `-- name: CountChirpsByAuthor :one
+SELECT COUNT(*) FROM chirps
+WHERE user_id = $1
+`
+
+func (q *Queries) CountChirpsByAuthor(ctx context.Context, userID uuid.UUID) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countChirpsByAuthor, userID)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
 const createChirp = `-- name: CreateChirp :one
-INSERT INTO chirps (id, created_at, updated_at, body, user_id)
+INSERT INTO chirps (id, created_at, updated_at, body, user_id, visibility, reply_policy, reply_to_id, mentions)
 VALUES (
     gen_random_uuid(),
     NOW(),
     NOW(),
     $1,
-    $2
+    $2,
+    $3,
+    $4,
+    $5,
+    $6
 )
-RETURNING id, user_id, body, created_at, updated_at
+RETURNING id, user_id, body, created_at, updated_at, hidden_at, hidden_reason, visibility, reply_policy, reply_to_id, mentions
 `
 
 type CreateChirpParams struct {
-	Body   string
-	UserID uuid.UUID
+	Body        string
+	UserID      uuid.UUID
+	Visibility  string
+	ReplyPolicy string
+	ReplyToID   uuid.NullUUID
+	Mentions    []uuid.UUID
 }
 
 func (q *Queries) CreateChirp(ctx context.Context, arg CreateChirpParams) (Chirp, error) {
-	row := q.db.QueryRowContext(ctx, createChirp, arg.Body, arg.UserID)
+	row := q.db.QueryRowContext(ctx, createChirp,
+		arg.Body,
+		arg.UserID,
+		arg.Visibility,
+		arg.ReplyPolicy,
+		arg.ReplyToID,
+		pq.Array(arg.Mentions),
+	)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.Body,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.HiddenAt,
+		&i.HiddenReason,
+		&i.Visibility,
+		&i.ReplyPolicy,
+		&i.ReplyToID,
+		pq.Array(&i.Mentions),
+	)
+	return i, err
+}
+
+const createChirpAt = `-- name: CreateChirpAt :one
+INSERT INTO chirps (id, created_at, updated_at, body, user_id)
+VALUES (
+    gen_random_uuid(),
+    $1,
+    $1,
+    $2,
+    $3
+)
+RETURNING id, user_id, body, created_at, updated_at, hidden_at, hidden_reason, visibility, reply_policy, reply_to_id, mentions
+`
+
+type CreateChirpAtParams struct {
+	CreatedAt time.Time
+	Body      string
+	UserID    uuid.UUID
+}
+
+func (q *Queries) CreateChirpAt(ctx context.Context, arg CreateChirpAtParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, createChirpAt, arg.CreatedAt, arg.Body, arg.UserID)
 	var i Chirp
 	err := row.Scan(
 		&i.ID,
@@ -37,6 +148,12 @@ func (q *Queries) CreateChirp(ctx context.Context, arg CreateChirpParams) (Chirp
 		&i.Body,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.HiddenAt,
+		&i.HiddenReason,
+		&i.Visibility,
+		&i.ReplyPolicy,
+		&i.ReplyToID,
+		pq.Array(&i.Mentions),
 	)
 	return i, err
 }
@@ -52,7 +169,7 @@ func (q *Queries) DeleteChirp(ctx context.Context, id uuid.UUID) error {
 }
 
 const getChirp = `-- name: GetChirp :one
-SELECT id, user_id, body, created_at, updated_at FROM chirps
+SELECT id, user_id, body, created_at, updated_at, hidden_at, hidden_reason, visibility, reply_policy, reply_to_id, mentions FROM chirps
 WHERE id=$1
 `
 
@@ -65,19 +182,36 @@ func (q *Queries) GetChirp(ctx context.Context, id uuid.UUID) (Chirp, error) {
 		&i.Body,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.HiddenAt,
+		&i.HiddenReason,
+		&i.Visibility,
+		&i.ReplyPolicy,
+		&i.ReplyToID,
+		pq.Array(&i.Mentions),
 	)
 	return i, err
 }
 
 const getChirps = `-- name: GetChirps :many
-SELECT id, user_id, body, created_at, updated_at FROM chirps
+SELECT id, user_id, body, created_at, updated_at, hidden_at, hidden_reason, visibility, reply_policy, reply_to_id, mentions FROM chirps
+WHERE hidden_at IS NULL
+    AND visibility <> 'unlisted'
+    AND user_id NOT IN (
+        SELECT muted_id FROM user_mutes WHERE user_mutes.user_id = $1::uuid
+    )
+    AND chirp_visible_to(chirps, $1::uuid)
 ORDER BY
-    CASE WHEN $1::text = 'asc' THEN created_at END ASC,
-    CASE WHEN $1::text = 'desc' THEN created_at END DESC
+    CASE WHEN $2::text = 'asc' THEN created_at END ASC,
+    CASE WHEN $2::text = 'desc' THEN created_at END DESC
 `
 
-func (q *Queries) GetChirps(ctx context.Context, sort string) ([]Chirp, error) {
-	rows, err := q.db.QueryContext(ctx, getChirps, sort)
+type GetChirpsParams struct {
+	ViewerID uuid.UUID
+	Sort     string
+}
+
+func (q *Queries) GetChirps(ctx context.Context, arg GetChirpsParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getChirps, arg.ViewerID, arg.Sort)
 	if err != nil {
 		return nil, err
 	}
@@ -91,6 +225,12 @@ func (q *Queries) GetChirps(ctx context.Context, sort string) ([]Chirp, error) {
 			&i.Body,
 			&i.CreatedAt,
 			&i.UpdatedAt,
+			&i.HiddenAt,
+			&i.HiddenReason,
+			&i.Visibility,
+			&i.ReplyPolicy,
+			&i.ReplyToID,
+			pq.Array(&i.Mentions),
 		); err != nil {
 			return nil, err
 		}
@@ -106,20 +246,29 @@ func (q *Queries) GetChirps(ctx context.Context, sort string) ([]Chirp, error) {
 }
 
 const getChirpsByAuthor = `-- name: GetChirpsByAuthor :many
-SELECT id, user_id, body, created_at, updated_at FROM chirps
-WHERE user_id = $1
+SELECT id, user_id, body, created_at, updated_at, hidden_at, hidden_reason, visibility, reply_policy, reply_to_id, mentions FROM chirps
+WHERE user_id = $1 AND (hidden_at IS NULL OR $2::bool)
+    AND (visibility <> 'unlisted' OR user_id = $3::uuid)
+    AND chirp_visible_to(chirps, $3::uuid)
 ORDER BY
-    CASE WHEN $2::text = 'asc' THEN created_at END ASC,
-    CASE WHEN $2::text = 'desc' THEN created_at END DESC
+    CASE WHEN $4::text = 'asc' THEN created_at END ASC,
+    CASE WHEN $4::text = 'desc' THEN created_at END DESC
 `
 
 type GetChirpsByAuthorParams struct {
-	UserID uuid.UUID
-	Sort   string
+	UserID        uuid.UUID
+	IncludeHidden bool
+	ViewerID      uuid.UUID
+	Sort          string
 }
 
 func (q *Queries) GetChirpsByAuthor(ctx context.Context, arg GetChirpsByAuthorParams) ([]Chirp, error) {
-	rows, err := q.db.QueryContext(ctx, getChirpsByAuthor, arg.UserID, arg.Sort)
+	rows, err := q.db.QueryContext(ctx, getChirpsByAuthor,
+		arg.UserID,
+		arg.IncludeHidden,
+		arg.ViewerID,
+		arg.Sort,
+	)
 	if err != nil {
 		return nil, err
 	}
@@ -133,6 +282,12 @@ func (q *Queries) GetChirpsByAuthor(ctx context.Context, arg GetChirpsByAuthorPa
 			&i.Body,
 			&i.CreatedAt,
 			&i.UpdatedAt,
+			&i.HiddenAt,
+			&i.HiddenReason,
+			&i.Visibility,
+			&i.ReplyPolicy,
+			&i.ReplyToID,
+			pq.Array(&i.Mentions),
 		); err != nil {
 			return nil, err
 		}
@@ -146,3 +301,97 @@ func (q *Queries) GetChirpsByAuthor(ctx context.Context, arg GetChirpsByAuthorPa
 	}
 	return items, nil
 }
+
+const hideChirp = `-- name: HideChirp :one
+UPDATE chirps
+SET hidden_at = NOW(), hidden_reason = $1
+WHERE id = $2
+RETURNING id, user_id, body, created_at, updated_at, hidden_at, hidden_reason, visibility, reply_policy, reply_to_id, mentions
+`
+
+type HideChirpParams struct {
+	HiddenReason sql.NullString
+	ID           uuid.UUID
+}
+
+func (q *Queries) HideChirp(ctx context.Context, arg HideChirpParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, hideChirp, arg.HiddenReason, arg.ID)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.Body,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.HiddenAt,
+		&i.HiddenReason,
+		&i.Visibility,
+		&i.ReplyPolicy,
+		&i.ReplyToID,
+		pq.Array(&i.Mentions),
+	)
+	return i, err
+}
+
+const likeChirp = `-- name: LikeChirp :execrows
+INSERT INTO chirp_likes (user_id, chirp_id, created_at)
+VALUES ($1, $2, NOW())
+ON CONFLICT (user_id, chirp_id) DO NOTHING
+`
+
+type LikeChirpParams struct {
+	UserID  uuid.UUID
+	ChirpID uuid.UUID
+}
+
+func (q *Queries) LikeChirp(ctx context.Context, arg LikeChirpParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, likeChirp, arg.UserID, arg.ChirpID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const unhideChirp = `-- name: UnhideChirp :one
+UPDATE chirps
+SET hidden_at = NULL, hidden_reason = NULL
+WHERE id = $1
+RETURNING id, user_id, body, created_at, updated_at, hidden_at, hidden_reason, visibility, reply_policy, reply_to_id, mentions
+`
+
+func (q *Queries) UnhideChirp(ctx context.Context, id uuid.UUID) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, unhideChirp, id)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.Body,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.HiddenAt,
+		&i.HiddenReason,
+		&i.Visibility,
+		&i.ReplyPolicy,
+		&i.ReplyToID,
+		pq.Array(&i.Mentions),
+	)
+	return i, err
+}
+
+const unlikeChirp = `-- name: UnlikeChirp :execrows
+DELETE FROM chirp_likes
+WHERE user_id = $1 AND chirp_id = $2
+`
+
+type UnlikeChirpParams struct {
+	UserID  uuid.UUID
+	ChirpID uuid.UUID
+}
+
+func (q *Queries) UnlikeChirp(ctx context.Context, arg UnlikeChirpParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, unlikeChirp, arg.UserID, arg.ChirpID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
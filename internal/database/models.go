@@ -6,16 +6,134 @@ package database
 
 import (
 	"database/sql"
+	"encoding/json"
 	"time"
 
 	"github.com/google/uuid"
 )
 
+type AuditEvent struct {
+	ID           uuid.UUID
+	ActorID      uuid.NullUUID
+	Action       string
+	TargetUserID uuid.NullUUID
+	Detail       json.RawMessage
+	CreatedAt    time.Time
+	Seq          int64
+	Ip           sql.NullString
+	UserAgent    sql.NullString
+	PrevHash     []byte
+	Hash         []byte
+}
+
 type Chirp struct {
+	ID           uuid.UUID
+	UserID       uuid.UUID
+	Body         string
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+	HiddenAt     sql.NullTime
+	HiddenReason sql.NullString
+	Visibility   string
+	ReplyPolicy  string
+	ReplyToID    uuid.NullUUID
+	Mentions     []uuid.UUID
+}
+
+type ChirpLike struct {
+	UserID    uuid.UUID
+	ChirpID   uuid.UUID
+	CreatedAt time.Time
+}
+
+type Conversation struct {
+	ID            uuid.UUID
+	DirectKey     sql.NullString
+	LastMessageAt sql.NullTime
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+}
+
+type ConversationMember struct {
+	ConversationID uuid.UUID
+	UserID         uuid.UUID
+	LastReadAt     sql.NullTime
+	CreatedAt      time.Time
+}
+
+type DirectMessage struct {
+	ID             uuid.UUID
+	ConversationID uuid.UUID
+	SenderID       uuid.UUID
+	Body           string
+	CreatedAt      time.Time
+}
+
+type FilterRule struct {
+	ID        uuid.UUID
+	Pattern   string
+	Action    string
+	CreatedBy uuid.NullUUID
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+type Follow struct {
+	FollowerID uuid.UUID
+	FollowedID uuid.UUID
+	Status     string
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+}
+
+type MediaThumbnail struct {
+	MediaID     uuid.UUID
+	Size        string
+	ContentType string
+	StorageKey  string
+	SizeBytes   int64
+	Width       int32
+	Height      int32
+	CreatedAt   time.Time
+}
+
+type MediaUpload struct {
+	ID          uuid.UUID
+	UserID      uuid.UUID
+	ChirpID     uuid.NullUUID
+	Position    int32
+	AltText     string
+	ContentType string
+	StorageKey  string
+	SizeBytes   int64
+	Width       int32
+	Height      int32
+	CreatedAt   time.Time
+	Blurhash    string
+	ProcessedAt sql.NullTime
+}
+
+type Notification struct {
 	ID        uuid.UUID
 	UserID    uuid.UUID
-	Body      string
+	ActorID   uuid.UUID
+	Type      string
+	ChirpID   uuid.NullUUID
+	ReadAt    sql.NullTime
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+type NotificationMute struct {
+	UserID    uuid.UUID
+	Type      string
 	CreatedAt time.Time
+}
+
+type RateLimitBucket struct {
+	Key       string
+	Tokens    float64
+	Allowed   bool
 	UpdatedAt time.Time
 }
 
@@ -28,11 +146,42 @@ type RefreshToken struct {
 	UpdatedAt time.Time
 }
 
-type User struct {
+type Report struct {
 	ID             uuid.UUID
+	ReporterID     uuid.NullUUID
+	ReportedUserID uuid.UUID
+	ChirpID        uuid.NullUUID
+	Category       string
+	Comment        string
+	Status         string
+	ResolvedBy     uuid.NullUUID
+	ResolvedAt     sql.NullTime
+	ResolutionNote sql.NullString
 	CreatedAt      time.Time
 	UpdatedAt      time.Time
-	Email          string
-	HashedPassword string
-	IsChirpyRed    bool
+}
+
+type User struct {
+	ID              uuid.UUID
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+	Email           string
+	HashedPassword  string
+	IsChirpyRed     bool
+	Role            string
+	SuspendedAt     sql.NullTime
+	SuspendedReason sql.NullString
+	IsProtected     bool
+}
+
+type UserBlock struct {
+	BlockerID uuid.UUID
+	BlockedID uuid.UUID
+	CreatedAt time.Time
+}
+
+type UserMute struct {
+	UserID    uuid.UUID
+	MutedID   uuid.UUID
+	CreatedAt time.Time
 }
@@ -11,25 +11,38 @@ import (
 	"github.com/google/uuid"
 )
 
+const countActiveRefreshTokens = `-- name: CountActiveRefreshTokens :one
+SELECT COUNT(*) FROM refresh_tokens
+WHERE user_id = $1 AND revoked_at IS NULL AND expires_at > NOW()
+`
+
+func (q *Queries) CountActiveRefreshTokens(ctx context.Context, userID uuid.UUID) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countActiveRefreshTokens, userID)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
 const createRefreshToken = `-- name: CreateRefreshToken :exec
 INSERT INTO refresh_tokens (token, created_at, updated_at, expires_at, revoked_at, user_id)
 VALUES (
     $1,
     NOW(),
     NOW(),
-    NOW() + INTERVAL '60 days',
+    NOW() + make_interval(secs => $3::float8),
     NULL,
     $2
 )
 `
 
 type CreateRefreshTokenParams struct {
-	Token  string
-	UserID uuid.UUID
+	Token      string
+	UserID     uuid.UUID
+	TtlSeconds float64
 }
 
 func (q *Queries) CreateRefreshToken(ctx context.Context, arg CreateRefreshTokenParams) error {
-	_, err := q.db.ExecContext(ctx, createRefreshToken, arg.Token, arg.UserID)
+	_, err := q.db.ExecContext(ctx, createRefreshToken, arg.Token, arg.UserID, arg.TtlSeconds)
 	return err
 }
 
@@ -62,3 +75,17 @@ func (q *Queries) RevokeRefreshToken(ctx context.Context, token string) error {
 	_, err := q.db.ExecContext(ctx, revokeRefreshToken, token)
 	return err
 }
+
+const revokeUserRefreshTokens = `-- name: RevokeUserRefreshTokens :execrows
+UPDATE refresh_tokens
+SET revoked_at = NOW(), updated_at = NOW()
+WHERE user_id = $1 AND revoked_at IS NULL AND expires_at > NOW()
+`
+
+func (q *Queries) RevokeUserRefreshTokens(ctx context.Context, userID uuid.UUID) (int64, error) {
+	result, err := q.db.ExecContext(ctx, revokeUserRefreshTokens, userID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
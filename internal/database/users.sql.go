@@ -7,10 +7,24 @@ package database
 
 import (
 	"context"
+	"database/sql"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
+const countUsersByID = `-- name: CountUsersByID :one
+SELECT COUNT(*) FROM users
+WHERE id = ANY($1::uuid[])
+`
+
+func (q *Queries) CountUsersByID(ctx context.Context, ids []uuid.UUID) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countUsersByID, pq.Array(ids))
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
 const createUser = `-- name: CreateUser :one
 INSERT INTO users (id, created_at, updated_at, email, hashed_password)
 VALUES (
@@ -20,7 +34,7 @@ VALUES (
     $1,
     $2
 )
-RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, role, suspended_at, suspended_reason, is_protected
 `
 
 type CreateUserParams struct {
@@ -38,10 +52,27 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, e
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.Role,
+		&i.SuspendedAt,
+		&i.SuspendedReason,
+		&i.IsProtected,
 	)
 	return i, err
 }
 
+const deleteUser = `-- name: DeleteUser :execrows
+DELETE FROM users
+WHERE id = $1
+`
+
+func (q *Queries) DeleteUser(ctx context.Context, id uuid.UUID) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteUser, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
 const deleteUsers = `-- name: DeleteUsers :exec
 DELETE FROM users
 `
@@ -51,8 +82,31 @@ func (q *Queries) DeleteUsers(ctx context.Context) error {
 	return err
 }
 
+const getUser = `-- name: GetUser :one
+SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red, role, suspended_at, suspended_reason, is_protected FROM users
+WHERE id = $1
+`
+
+func (q *Queries) GetUser(ctx context.Context, id uuid.UUID) (User, error) {
+	row := q.db.QueryRowContext(ctx, getUser, id)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.Role,
+		&i.SuspendedAt,
+		&i.SuspendedReason,
+		&i.IsProtected,
+	)
+	return i, err
+}
+
 const getUserByEmail = `-- name: GetUserByEmail :one
-SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red FROM users
+SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red, role, suspended_at, suspended_reason, is_protected FROM users
 WHERE email = $1
 `
 
@@ -66,6 +120,226 @@ func (q *Queries) GetUserByEmail(ctx context.Context, email string) (User, error
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.Role,
+		&i.SuspendedAt,
+		&i.SuspendedReason,
+		&i.IsProtected,
+	)
+	return i, err
+}
+
+const listUsers = `-- name: ListUsers :many
+SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red, role, suspended_at, suspended_reason, is_protected FROM users
+ORDER BY created_at ASC
+LIMIT $1 OFFSET $2
+`
+
+type ListUsersParams struct {
+	Limit  int32
+	Offset int32
+}
+
+func (q *Queries) ListUsers(ctx context.Context, arg ListUsersParams) ([]User, error) {
+	rows, err := q.db.QueryContext(ctx, listUsers, arg.Limit, arg.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []User
+	for rows.Next() {
+		var i User
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Email,
+			&i.HashedPassword,
+			&i.IsChirpyRed,
+			&i.Role,
+			&i.SuspendedAt,
+			&i.SuspendedReason,
+			&i.IsProtected,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const searchUsers = `-- name: SearchUsers :many
+SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red, role, suspended_at, suspended_reason, is_protected FROM users
+WHERE ($1::text = '' OR email ILIKE '%' || $1::text || '%')
+  AND ($2::text = '' OR role = $2::text)
+ORDER BY created_at ASC
+LIMIT $3 OFFSET $4
+`
+
+type SearchUsersParams struct {
+	Query      string
+	Role       string
+	PageLimit  int32
+	PageOffset int32
+}
+
+func (q *Queries) SearchUsers(ctx context.Context, arg SearchUsersParams) ([]User, error) {
+	rows, err := q.db.QueryContext(ctx, searchUsers,
+		arg.Query,
+		arg.Role,
+		arg.PageLimit,
+		arg.PageOffset,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []User
+	for rows.Next() {
+		var i User
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Email,
+			&i.HashedPassword,
+			&i.IsChirpyRed,
+			&i.Role,
+			&i.SuspendedAt,
+			&i.SuspendedReason,
+			&i.IsProtected,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const setUserChirpyRed = `-- name: SetUserChirpyRed :exec
+UPDATE users
+SET is_chirpy_red = $1, updated_at = NOW()
+WHERE id = $2
+`
+
+type SetUserChirpyRedParams struct {
+	IsChirpyRed bool
+	ID          uuid.UUID
+}
+
+func (q *Queries) SetUserChirpyRed(ctx context.Context, arg SetUserChirpyRedParams) error {
+	_, err := q.db.ExecContext(ctx, setUserChirpyRed, arg.IsChirpyRed, arg.ID)
+	return err
+}
+
+const setUserProtected = `-- name: SetUserProtected :one
+UPDATE users
+SET is_protected = $1, updated_at = NOW()
+WHERE id = $2
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, role, suspended_at, suspended_reason, is_protected
+`
+
+type SetUserProtectedParams struct {
+	IsProtected bool
+	ID          uuid.UUID
+}
+
+func (q *Queries) SetUserProtected(ctx context.Context, arg SetUserProtectedParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, setUserProtected, arg.IsProtected, arg.ID)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.Role,
+		&i.SuspendedAt,
+		&i.SuspendedReason,
+		&i.IsProtected,
+	)
+	return i, err
+}
+
+const setUserRole = `-- name: SetUserRole :exec
+UPDATE users
+SET role = $1, updated_at = NOW()
+WHERE id = $2
+`
+
+type SetUserRoleParams struct {
+	Role string
+	ID   uuid.UUID
+}
+
+func (q *Queries) SetUserRole(ctx context.Context, arg SetUserRoleParams) error {
+	_, err := q.db.ExecContext(ctx, setUserRole, arg.Role, arg.ID)
+	return err
+}
+
+const suspendUser = `-- name: SuspendUser :one
+UPDATE users
+SET suspended_at = NOW(), suspended_reason = $1, updated_at = NOW()
+WHERE id = $2
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, role, suspended_at, suspended_reason, is_protected
+`
+
+type SuspendUserParams struct {
+	SuspendedReason sql.NullString
+	ID              uuid.UUID
+}
+
+func (q *Queries) SuspendUser(ctx context.Context, arg SuspendUserParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, suspendUser, arg.SuspendedReason, arg.ID)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.Role,
+		&i.SuspendedAt,
+		&i.SuspendedReason,
+		&i.IsProtected,
+	)
+	return i, err
+}
+
+const unsuspendUser = `-- name: UnsuspendUser :one
+UPDATE users
+SET suspended_at = NULL, suspended_reason = NULL, updated_at = NOW()
+WHERE id = $1
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, role, suspended_at, suspended_reason, is_protected
+`
+
+func (q *Queries) UnsuspendUser(ctx context.Context, id uuid.UUID) (User, error) {
+	row := q.db.QueryRowContext(ctx, unsuspendUser, id)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.Role,
+		&i.SuspendedAt,
+		&i.SuspendedReason,
+		&i.IsProtected,
 	)
 	return i, err
 }
@@ -74,7 +348,7 @@ const updateUserEmailAndPassword = `-- name: UpdateUserEmailAndPassword :one
 UPDATE users
 SET email = $1, hashed_password = $2, updated_at = NOW()
 WHERE id = $3
-RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, role, suspended_at, suspended_reason, is_protected
 `
 
 type UpdateUserEmailAndPasswordParams struct {
@@ -93,17 +367,40 @@ func (q *Queries) UpdateUserEmailAndPassword(ctx context.Context, arg UpdateUser
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.Role,
+		&i.SuspendedAt,
+		&i.SuspendedReason,
+		&i.IsProtected,
 	)
 	return i, err
 }
 
-const upgradeUser = `-- name: UpgradeUser :exec
+const updateUserPassword = `-- name: UpdateUserPassword :exec
+UPDATE users
+SET hashed_password = $1, updated_at = NOW()
+WHERE id = $2
+`
+
+type UpdateUserPasswordParams struct {
+	HashedPassword string
+	ID             uuid.UUID
+}
+
+func (q *Queries) UpdateUserPassword(ctx context.Context, arg UpdateUserPasswordParams) error {
+	_, err := q.db.ExecContext(ctx, updateUserPassword, arg.HashedPassword, arg.ID)
+	return err
+}
+
+const upgradeUser = `-- name: UpgradeUser :execrows
 UPDATE users
 SET is_chirpy_red = true, updated_at = NOW()
 WHERE id = $1
 `
 
-func (q *Queries) UpgradeUser(ctx context.Context, id uuid.UUID) error {
-	_, err := q.db.ExecContext(ctx, upgradeUser, id)
-	return err
+func (q *Queries) UpgradeUser(ctx context.Context, id uuid.UUID) (int64, error) {
+	result, err := q.db.ExecContext(ctx, upgradeUser, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
 }
@@ -0,0 +1,140 @@
+package database_test
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"testing"
+
+	"github.com/google/uuid"
+	_ "github.com/lib/pq"
+
+	"github.com/gskll/chirpy2/internal/database"
+	"github.com/gskll/chirpy2/internal/migrations"
+)
+
+// openTestDB migrates the database at TEST_DB_URL and returns queries in a
+// transaction that is rolled back when the test ends.
+func openTestDB(t *testing.T) *database.Queries {
+	t.Helper()
+	dbURL := os.Getenv("TEST_DB_URL")
+	if dbURL == "" {
+		t.Skip("TEST_DB_URL is not set")
+	}
+	db, err := sql.Open("postgres", dbURL)
+	if err != nil {
+		t.Fatalf("sql.Open failed: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	ctx := context.Background()
+	migrator, err := migrations.New(db)
+	if err != nil {
+		t.Fatalf("migrations.New failed: %v", err)
+	}
+	if _, err := migrator.Up(ctx); err != nil {
+		t.Fatalf("Up failed: %v", err)
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatalf("BeginTx failed: %v", err)
+	}
+	t.Cleanup(func() { tx.Rollback() })
+	return database.New(db).WithTx(tx)
+}
+
+func TestChirpVisibleTo(t *testing.T) {
+	q := openTestDB(t)
+	ctx := context.Background()
+
+	newUser := func(name string) uuid.UUID {
+		u, err := q.CreateUser(ctx, database.CreateUserParams{Email: name + "-" + uuid.NewString() + "@example.com", HashedPassword: "x"})
+		if err != nil {
+			t.Fatalf("CreateUser failed: %v", err)
+		}
+		return u.ID
+	}
+	follow := func(follower, followed uuid.UUID, status string) {
+		_, err := q.FollowUser(ctx, database.FollowUserParams{FollowerID: follower, FollowedID: followed, Status: status})
+		if err != nil {
+			t.Fatalf("FollowUser failed: %v", err)
+		}
+	}
+	newChirp := func(author uuid.UUID, visibility string, mentions ...uuid.UUID) uuid.UUID {
+		c, err := q.CreateChirp(ctx, database.CreateChirpParams{
+			Body:        "hello",
+			UserID:      author,
+			Visibility:  visibility,
+			ReplyPolicy: "everyone",
+			Mentions:    append([]uuid.UUID{}, mentions...),
+		})
+		if err != nil {
+			t.Fatalf("CreateChirp failed: %v", err)
+		}
+		return c.ID
+	}
+
+	author := newUser("author")
+	protected := newUser("protected")
+	follower := newUser("follower")
+	pending := newUser("pending")
+	mentioned := newUser("mentioned")
+	blocked := newUser("blocked")
+	stranger := newUser("stranger")
+	anonymous := uuid.Nil
+
+	if _, err := q.SetUserProtected(ctx, database.SetUserProtectedParams{ID: protected, IsProtected: true}); err != nil {
+		t.Fatalf("SetUserProtected failed: %v", err)
+	}
+	follow(follower, author, "accepted")
+	follow(follower, protected, "accepted")
+	follow(pending, protected, "pending")
+	if err := q.BlockUser(ctx, database.BlockUserParams{BlockerID: author, BlockedID: blocked}); err != nil {
+		t.Fatalf("BlockUser failed: %v", err)
+	}
+
+	public := newChirp(author, "public", blocked)
+	unlisted := newChirp(author, "unlisted")
+	followers := newChirp(author, "followers", mentioned)
+	mentions := newChirp(author, "mentioned", mentioned)
+	protectedPublic := newChirp(protected, "public")
+	protectedMention := newChirp(protected, "public", mentioned)
+
+	tests := []struct {
+		name   string
+		chirp  uuid.UUID
+		viewer uuid.UUID
+		want   bool
+	}{
+		{"Public, anonymous", public, anonymous, true},
+		{"Public, stranger", public, stranger, true},
+		{"Public, blocked even if mentioned", public, blocked, false},
+		{"Unlisted, stranger", unlisted, stranger, true},
+		{"Followers, author", followers, author, true},
+		{"Followers, follower", followers, follower, true},
+		{"Followers, mentioned", followers, mentioned, true},
+		{"Followers, stranger", followers, stranger, false},
+		{"Followers, anonymous", followers, anonymous, false},
+		{"Mentioned, mentioned", mentions, mentioned, true},
+		{"Mentioned, follower", mentions, follower, false},
+		{"Mentioned, author", mentions, author, true},
+		{"Protected account, follower", protectedPublic, follower, true},
+		{"Protected account, pending follower", protectedPublic, pending, false},
+		{"Protected account, stranger", protectedPublic, stranger, false},
+		{"Protected account, anonymous", protectedPublic, anonymous, false},
+		{"Protected account, mentioned", protectedMention, mentioned, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := q.ChirpVisibleTo(ctx, database.ChirpVisibleToParams{ViewerID: tt.viewer, ID: tt.chirp})
+			if err != nil {
+				t.Fatalf("ChirpVisibleTo failed: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
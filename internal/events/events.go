@@ -0,0 +1,75 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/google/uuid"
+)
+
+const (
+	ChirpCreated   = "chirp.created"
+	ChirpDeleted   = "chirp.deleted"
+	ChirpMentioned = "chirp.mentioned"
+
+	NotificationCreated = "notification.created"
+
+	// MessageCreated is sent by UserID to the audience's UserIDs.
+	MessageCreated = "message.created"
+
+	// RelationshipsChanged tells a user's connections to reload who they
+	// blocked or muted.
+	RelationshipsChanged = "user.relationships_changed"
+	// UserSuspended and UserDeleted close the user's connections.
+	UserSuspended = "user.suspended"
+	UserDeleted   = "user.deleted"
+)
+
+// Event is a single message published on the broker. Data is kept as raw JSON
+// so events can be relayed between instances without knowing their payload.
+// UserID is the user the event concerns: the author for chirp.created and
+// chirp.deleted, the recipient for events addressed to a single user.
+type Event struct {
+	ID       uint64          `json:"id"`
+	Type     string          `json:"type"`
+	UserID   uuid.UUID       `json:"user_id"`
+	Audience Audience        `json:"audience"`
+	Data     json.RawMessage `json:"data"`
+}
+
+// Audience limits who may receive an event besides UserID. The zero value is
+// everyone, a restricted audience is only the users it lists.
+type Audience struct {
+	Restricted bool `json:"restricted,omitempty"`
+	// Followers are the accepted followers of UserID.
+	Followers bool        `json:"followers,omitempty"`
+	UserIDs   []uuid.UUID `json:"user_ids,omitempty"`
+}
+
+func (a Audience) Public() bool {
+	return !a.Restricted
+}
+
+// Broker fans events out to subscribers. MemoryBroker is the in-process
+// implementation; a Postgres LISTEN/NOTIFY backed broker can satisfy the same
+// interface for multi-instance deployments.
+type Broker interface {
+	Publish(ctx context.Context, eventType string, userID uuid.UUID, data any) (Event, error)
+	// PublishTo is Publish for events only audience may receive, subscribers
+	// are responsible for checking it.
+	PublishTo(ctx context.Context, eventType string, userID uuid.UUID, audience Audience, data any) (Event, error)
+	Subscribe(lastEventID uint64) *Subscription
+}
+
+// Subscription receives events published after it was created. Missed holds
+// any buffered events newer than the lastEventID passed to Subscribe.
+type Subscription struct {
+	Missed []Event
+	C      <-chan Event
+
+	cancel func()
+}
+
+func (s *Subscription) Close() {
+	s.cancel()
+}
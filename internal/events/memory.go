@@ -0,0 +1,93 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+const subscriberBuffer = 64
+
+type MemoryBroker struct {
+	mu          sync.Mutex
+	nextID      uint64
+	history     []Event
+	historySize int
+	subscribers map[chan Event]struct{}
+}
+
+// NewMemoryBroker keeps the last historySize events so subscribers can resume
+// from a Last-Event-ID.
+func NewMemoryBroker(historySize int) *MemoryBroker {
+	return &MemoryBroker{
+		historySize: historySize,
+		subscribers: make(map[chan Event]struct{}),
+	}
+}
+
+func (b *MemoryBroker) Publish(ctx context.Context, eventType string, userID uuid.UUID, data any) (Event, error) {
+	return b.PublishTo(ctx, eventType, userID, Audience{}, data)
+}
+
+func (b *MemoryBroker) PublishTo(ctx context.Context, eventType string, userID uuid.UUID, audience Audience, data any) (Event, error) {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return Event{}, err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.nextID++
+	event := Event{ID: b.nextID, Type: eventType, UserID: userID, Audience: audience, Data: raw}
+
+	b.history = append(b.history, event)
+	if len(b.history) > b.historySize {
+		b.history = b.history[len(b.history)-b.historySize:]
+	}
+
+	for ch := range b.subscribers {
+		select {
+		case ch <- event:
+		default:
+			// slow subscriber: drop it so publishers never block, the client
+			// can reconnect and resume with Last-Event-ID
+			delete(b.subscribers, ch)
+			close(ch)
+		}
+	}
+
+	return event, nil
+}
+
+func (b *MemoryBroker) Subscribe(lastEventID uint64) *Subscription {
+	ch := make(chan Event, subscriberBuffer)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var missed []Event
+	if lastEventID > 0 {
+		for _, event := range b.history {
+			if event.ID > lastEventID {
+				missed = append(missed, event)
+			}
+		}
+	}
+	b.subscribers[ch] = struct{}{}
+
+	return &Subscription{
+		Missed: missed,
+		C:      ch,
+		cancel: func() {
+			b.mu.Lock()
+			defer b.mu.Unlock()
+			if _, ok := b.subscribers[ch]; ok {
+				delete(b.subscribers, ch)
+				close(ch)
+			}
+		},
+	}
+}
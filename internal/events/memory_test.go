@@ -0,0 +1,83 @@
+package events
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestMemoryBrokerPublishSubscribe(t *testing.T) {
+	broker := NewMemoryBroker(10)
+	sub := broker.Subscribe(0)
+	defer sub.Close()
+
+	userID := uuid.New()
+	published, err := broker.Publish(context.Background(), ChirpCreated, userID, map[string]string{"body": "hi"})
+	if err != nil {
+		t.Fatalf("Publish() returned an error: %v", err)
+	}
+
+	got := <-sub.C
+	if got.ID != published.ID || got.Type != ChirpCreated || got.UserID != userID {
+		t.Errorf("Expected %+v, got %+v", published, got)
+	}
+	if string(got.Data) != `{"body":"hi"}` {
+		t.Errorf("Unexpected data: %s", got.Data)
+	}
+}
+
+func TestMemoryBrokerResume(t *testing.T) {
+	broker := NewMemoryBroker(3)
+	ctx := context.Background()
+	for i := 0; i < 5; i++ {
+		if _, err := broker.Publish(ctx, ChirpCreated, uuid.New(), i); err != nil {
+			t.Fatalf("Publish() returned an error: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name        string
+		lastEventID uint64
+		wantIDs     []uint64
+	}{
+		{"No Last-Event-ID", 0, nil},
+		{"Resume within history", 3, []uint64{4, 5}},
+		{"Resume beyond history", 1, []uint64{3, 4, 5}},
+		{"Up to date", 5, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sub := broker.Subscribe(tt.lastEventID)
+			defer sub.Close()
+
+			if len(sub.Missed) != len(tt.wantIDs) {
+				t.Fatalf("Expected %d missed events, got %d", len(tt.wantIDs), len(sub.Missed))
+			}
+			for i, event := range sub.Missed {
+				if event.ID != tt.wantIDs[i] {
+					t.Errorf("Expected event %d, got %d", tt.wantIDs[i], event.ID)
+				}
+			}
+		})
+	}
+}
+
+func TestMemoryBrokerDropsSlowSubscriber(t *testing.T) {
+	broker := NewMemoryBroker(1)
+	sub := broker.Subscribe(0)
+	defer sub.Close()
+
+	for i := 0; i < subscriberBuffer+1; i++ {
+		broker.Publish(context.Background(), ChirpCreated, uuid.New(), i)
+	}
+
+	count := 0
+	for range sub.C {
+		count++
+	}
+	if count != subscriberBuffer {
+		t.Errorf("Expected %d buffered events before close, got %d", subscriberBuffer, count)
+	}
+}
@@ -0,0 +1,302 @@
+// Package filter checks chirp bodies against word and phrase rules.
+//
+// Text and patterns are folded before they're compared: compatibility
+// normalisation, case folding, stripping diacritics, common look-alike
+// letters and leetspeak, so "ＫÉRFUFFLE", "k3rfuffle" and "KERFUFFLE" all
+// match "kerfuffle". Matching is on whole words, punctuation separates words
+// and is kept when a word is masked.
+package filter
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/unicode/norm"
+)
+
+type Action string
+
+const (
+	// Mask replaces the match with Mask and accepts the text.
+	Mask Action = "mask"
+	// Reject refuses the text.
+	Reject Action = "reject"
+	// Flag accepts the text unchanged and sends it for review.
+	Flag Action = "flag"
+)
+
+func ParseAction(s string) (Action, error) {
+	switch a := Action(s); a {
+	case Mask, Reject, Flag:
+		return a, nil
+	}
+	return "", fmt.Errorf("invalid action %q, must be mask, reject or flag", s)
+}
+
+// Masked replaces masked words.
+const Masked = "****"
+
+type Rule struct {
+	Pattern string `json:"pattern"`
+	Action  Action `json:"action"`
+}
+
+// DefaultRules are used when no word list is configured.
+var DefaultRules = []Rule{
+	{Pattern: "kerfuffle", Action: Mask},
+	{Pattern: "sharbert", Action: Mask},
+	{Pattern: "fornax", Action: Mask},
+}
+
+// Match is a rule that matched, Start and End are byte offsets of the matched
+// words in the original text.
+type Match struct {
+	Rule  Rule   `json:"rule"`
+	Text  string `json:"text"`
+	Start int    `json:"start"`
+	End   int    `json:"end"`
+}
+
+type Result struct {
+	// Text is the input with masked words replaced.
+	Text    string  `json:"text"`
+	Matches []Match `json:"matches"`
+}
+
+func (r Result) Rejected() bool {
+	return r.has(Reject)
+}
+
+func (r Result) Flagged() bool {
+	return r.has(Flag)
+}
+
+func (r Result) has(action Action) bool {
+	for _, m := range r.Matches {
+		if m.Rule.Action == action {
+			return true
+		}
+	}
+	return false
+}
+
+// Engine is immutable and safe for concurrent use.
+type Engine struct {
+	rules    map[string]Rule
+	maxWords int
+}
+
+// New builds an engine. Rules that fold to the same words replace earlier
+// ones, so later sources override earlier ones.
+func New(rules []Rule) (*Engine, error) {
+	e := &Engine{rules: make(map[string]Rule, len(rules))}
+	for _, rule := range rules {
+		if _, err := ParseAction(string(rule.Action)); err != nil {
+			return nil, fmt.Errorf("rule %q: %w", rule.Pattern, err)
+		}
+		words := patternWords(rule.Pattern)
+		if len(words) == 0 {
+			return nil, fmt.Errorf("rule %q: pattern has no words", rule.Pattern)
+		}
+		e.rules[strings.Join(words, " ")] = rule
+		e.maxWords = max(e.maxWords, len(words))
+	}
+	return e, nil
+}
+
+var defaultEngine, _ = New(DefaultRules)
+
+// Default is the engine for DefaultRules.
+func Default() *Engine {
+	return defaultEngine
+}
+
+// Rules returns the effective rules sorted by pattern.
+func (e *Engine) Rules() []Rule {
+	rules := make([]Rule, 0, len(e.rules))
+	for _, rule := range e.rules {
+		rules = append(rules, rule)
+	}
+	sort.Slice(rules, func(i, j int) bool { return rules[i].Pattern < rules[j].Pattern })
+	return rules
+}
+
+// Check finds every rule matching text, preferring the longest phrase at each
+// word, and masks the matches of mask rules.
+func (e *Engine) Check(text string) Result {
+	result := Result{Text: text, Matches: []Match{}}
+	if len(e.rules) == 0 {
+		return result
+	}
+
+	toks := tokenize(text)
+	for i := 0; i < len(toks); i++ {
+		match, n := e.matchAt(text, toks[i:])
+		if n == 0 {
+			continue
+		}
+		result.Matches = append(result.Matches, match)
+		i += n - 1
+	}
+
+	var b strings.Builder
+	last := 0
+	for _, m := range result.Matches {
+		if m.Rule.Action != Mask {
+			continue
+		}
+		b.WriteString(text[last:m.Start])
+		b.WriteString(Masked)
+		last = m.End
+	}
+	if last > 0 {
+		b.WriteString(text[last:])
+		result.Text = b.String()
+	}
+	return result
+}
+
+// matchAt returns the longest rule matching the words at the start of toks
+// and how many words it covers.
+func (e *Engine) matchAt(text string, toks []token) (Match, int) {
+	for n := min(e.maxWords, len(toks)); n > 0; n-- {
+		span := toks[:n]
+		for _, whole := range []bool{true, false} {
+			if whole && n > 1 {
+				continue
+			}
+			key, start, end := spanKey(span, whole)
+			if rule, ok := e.rules[key]; ok && key != "" {
+				return Match{Rule: rule, Text: text[start:end], Start: start, End: end}, n
+			}
+		}
+	}
+	return Match{}, 0
+}
+
+// spanKey folds the words of span. A single word is tried whole first so
+// symbols at its edges can stand in for letters, e.g. "$hit" or "@ss".
+func spanKey(span []token, whole bool) (string, int, int) {
+	if whole {
+		return span[0].whole, span[0].start, span[0].end
+	}
+	words := make([]string, len(span))
+	for i, t := range span {
+		words[i] = t.trimmed
+	}
+	return strings.Join(words, " "), span[0].trimStart, span[len(span)-1].trimEnd
+}
+
+// token is a word in the original text. trimmed drops symbols at the edges
+// of the word, which are usually punctuation.
+type token struct {
+	start, end         int
+	trimStart, trimEnd int
+	whole, trimmed     string
+}
+
+func tokenize(text string) []token {
+	var toks []token
+	for i := 0; i < len(text); {
+		r, size := utf8.DecodeRuneInString(text[i:])
+		if !isWordRune(r) {
+			i += size
+			continue
+		}
+		start := i
+		for i < len(text) {
+			r, size = utf8.DecodeRuneInString(text[i:])
+			if !isWordRune(r) {
+				break
+			}
+			i += size
+		}
+		toks = append(toks, newToken(text, start, i))
+	}
+	return toks
+}
+
+func newToken(text string, start, end int) token {
+	t := token{start: start, end: end, trimStart: start, trimEnd: end}
+	for t.trimStart < t.trimEnd {
+		r, size := utf8.DecodeRuneInString(text[t.trimStart:t.trimEnd])
+		if isLetterOrDigit(r) {
+			break
+		}
+		t.trimStart += size
+	}
+	for t.trimEnd > t.trimStart {
+		r, size := utf8.DecodeLastRuneInString(text[t.trimStart:t.trimEnd])
+		if isLetterOrDigit(r) {
+			break
+		}
+		t.trimEnd -= size
+	}
+	t.whole = Fold(text[start:end])
+	t.trimmed = Fold(text[t.trimStart:t.trimEnd])
+	return t
+}
+
+func patternWords(pattern string) []string {
+	var words []string
+	for _, t := range tokenize(pattern) {
+		if t.trimmed != "" {
+			words = append(words, t.trimmed)
+		}
+	}
+	return words
+}
+
+func isLetterOrDigit(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.IsMark(r)
+}
+
+func isWordRune(r rune) bool {
+	return isLetterOrDigit(r) || leet[r] != 0 || invisible[r]
+}
+
+// leet maps digits and symbols used in place of letters.
+var leet = map[rune]rune{
+	'0': 'o', '1': 'i', '3': 'e', '4': 'a', '5': 's', '7': 't', '8': 'b',
+	'@': 'a', '$': 's', '!': 'i', '|': 'i', '+': 't',
+}
+
+// confusables maps letters from other scripts that look like latin ones.
+var confusables = map[rune]rune{
+	'а': 'a', 'в': 'b', 'е': 'e', 'к': 'k', 'м': 'm', 'н': 'h', 'о': 'o',
+	'р': 'p', 'с': 'c', 'т': 't', 'у': 'y', 'х': 'x', 'ѕ': 's', 'і': 'i',
+	'ј': 'j', 'ԁ': 'd', 'ո': 'n', 'α': 'a', 'ο': 'o', 'ρ': 'p', 'ν': 'v',
+	'ι': 'i', 'κ': 'k', 'τ': 't', 'υ': 'u', 'χ': 'x', 'ε': 'e',
+}
+
+// invisible runes are dropped, they're used to split words without a
+// visible gap.
+var invisible = map[rune]bool{
+	'\u00ad': true, '\u200b': true, '\u200c': true, '\u200d': true, '\u2060': true, '\ufeff': true,
+}
+
+var folder = cases.Fold()
+
+// Fold reduces a word to the form rules are compared in.
+func Fold(s string) string {
+	s = folder.String(norm.NFKD.String(s))
+
+	var b strings.Builder
+	b.Grow(len(s))
+	for _, r := range s {
+		switch {
+		case invisible[r], unicode.Is(unicode.Mn, r):
+		case leet[r] != 0:
+			b.WriteRune(leet[r])
+		case confusables[r] != 0:
+			b.WriteRune(confusables[r])
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
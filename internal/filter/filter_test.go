@@ -0,0 +1,128 @@
+package filter
+
+import (
+	"testing"
+)
+
+func TestCheck(t *testing.T) {
+	engine, err := New([]Rule{
+		{Pattern: "kerfuffle", Action: Mask},
+		{Pattern: "sharbert", Action: Mask},
+		{Pattern: "fornax", Action: Mask},
+		{Pattern: "shit", Action: Mask},
+		{Pattern: "buy followers", Action: Reject},
+		{Pattern: "crypto giveaway", Action: Flag},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		body     string
+		want     string
+		rejected bool
+		flagged  bool
+	}{
+		{"Clean", "I had something interesting for breakfast", "I had something interesting for breakfast", false, false},
+		{"Single spaces", "I really need a kerfuffle to go to bed sooner", "I really need a **** to go to bed sooner", false, false},
+		{"Case", "Fornax SHARBERT", "**** ****", false, false},
+		{"Trailing punctuation", "What a Kerfuffle! Ugh, fornax, sharbert.", "What a ****! Ugh, ****, ****.", false, false},
+		{"Quotes and brackets", `("fornax")`, `("****")`, false, false},
+		{"Other whitespace", "kerfuffle\tfornax\nsharbert", "****\t****\n****", false, false},
+		{"Leetspeak", "k3rfuffl3 f0rn@x", "**** ****", false, false},
+		{"Symbols at the edge", "$hit happens", "**** happens", false, false},
+		{"Diacritics", "kérfüffle", "****", false, false},
+		{"Fullwidth", "ＦＯＲＮＡＸ", "****", false, false},
+		{"Cyrillic look-alikes", "fоrnах", "****", false, false},
+		{"Zero width space", "forn\u200bax", "****", false, false},
+		{"Whole words only", "fornaxes and kerfuffled", "fornaxes and kerfuffled", false, false},
+		{"Phrase reject", "Buy followers, cheap!", "Buy followers, cheap!", true, false},
+		{"Phrase across punctuation", "buy... FOLLOWERS", "buy... FOLLOWERS", true, false},
+		{"Flag keeps text", "join the crypto giveaway", "join the crypto giveaway", false, true},
+		{"Mixed actions", "fornax crypto giveaway", "**** crypto giveaway", false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := engine.Check(tt.body)
+			if result.Text != tt.want {
+				t.Errorf("Expected %q, got %q", tt.want, result.Text)
+			}
+			if result.Rejected() != tt.rejected {
+				t.Errorf("Expected rejected %v, got %v", tt.rejected, result.Rejected())
+			}
+			if result.Flagged() != tt.flagged {
+				t.Errorf("Expected flagged %v, got %v", tt.flagged, result.Flagged())
+			}
+		})
+	}
+}
+
+func TestCheckMatchOffsets(t *testing.T) {
+	engine, err := New([]Rule{{Pattern: "fornax", Action: Flag}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body := "¡Hola, Fornax!"
+	result := engine.Check(body)
+	if len(result.Matches) != 1 {
+		t.Fatalf("Expected 1 match, got %d", len(result.Matches))
+	}
+	m := result.Matches[0]
+	if body[m.Start:m.End] != "Fornax" || m.Text != "Fornax" {
+		t.Errorf("Expected match on %q, got %q at %d:%d", "Fornax", m.Text, m.Start, m.End)
+	}
+}
+
+func TestNewOverrides(t *testing.T) {
+	engine, err := New([]Rule{
+		{Pattern: "fornax", Action: Mask},
+		{Pattern: "FORNAX", Action: Reject},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rules := engine.Rules(); len(rules) != 1 || rules[0].Action != Reject {
+		t.Errorf("Expected the later rule to win, got %v", rules)
+	}
+}
+
+func TestNewInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		rule Rule
+	}{
+		{"Unknown action", Rule{Pattern: "fornax", Action: "delete"}},
+		{"No words", Rule{Pattern: " ... ", Action: Mask}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := New([]Rule{tt.rule}); err == nil {
+				t.Errorf("Expected an error for %v", tt.rule)
+			}
+		})
+	}
+}
+
+func TestFold(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"Straße", "strasse"},
+		{"ﬁne", "fine"},
+		{"n1c3", "nice"},
+		{"Ünïcödé", "unicode"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			if got := Fold(tt.in); got != tt.want {
+				t.Errorf("Fold(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
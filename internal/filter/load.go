@@ -0,0 +1,144 @@
+package filter
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/gskll/chirpy2/internal/database"
+)
+
+// ParseRules reads a word list, one "<action> <pattern>" per line, e.g.
+// "reject buy followers". Blank lines and lines starting with # are skipped.
+func ParseRules(r io.Reader) ([]Rule, error) {
+	var rules []Rule
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		action, pattern, _ := strings.Cut(line, " ")
+		a, err := ParseAction(action)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+		pattern = strings.TrimSpace(pattern)
+		if len(patternWords(pattern)) == 0 {
+			return nil, fmt.Errorf("line %d: missing pattern", n)
+		}
+		rules = append(rules, Rule{Pattern: pattern, Action: a})
+	}
+	return rules, scanner.Err()
+}
+
+func LoadFile(path string) ([]Rule, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	rules, err := ParseRules(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return rules, nil
+}
+
+// Loader builds the engine from the word list file, or DefaultRules without
+// one, and the rules admins edit in the database, which take precedence.
+type Loader struct {
+	path   string
+	db     *database.Queries
+	engine atomic.Pointer[Engine]
+}
+
+// DefaultLoader only uses DefaultRules.
+func DefaultLoader() *Loader {
+	l := &Loader{}
+	l.engine.Store(Default())
+	return l
+}
+
+// NewLoader starts with the file rules only, call Reload once the database
+// is available. db may be nil to only use the file.
+func NewLoader(path string, db *database.Queries) (*Loader, error) {
+	l := &Loader{path: path, db: db}
+	rules, err := l.fileRules()
+	if err != nil {
+		return nil, err
+	}
+	engine, err := New(rules)
+	if err != nil {
+		return nil, err
+	}
+	l.engine.Store(engine)
+	return l, nil
+}
+
+// Engine returns the current engine, keep using the same one for a whole
+// request.
+func (l *Loader) Engine() *Engine {
+	return l.engine.Load()
+}
+
+// Reload rereads the file and the database. The current engine is kept if
+// either fails.
+func (l *Loader) Reload(ctx context.Context) error {
+	rules, err := l.fileRules()
+	if err != nil {
+		return err
+	}
+	if l.db != nil {
+		dbRules, err := l.db.ListFilterRules(ctx)
+		if err != nil {
+			return fmt.Errorf("list filter rules: %w", err)
+		}
+		for _, dbRule := range dbRules {
+			rules = append(rules, Rule{Pattern: dbRule.Pattern, Action: Action(dbRule.Action)})
+		}
+	}
+
+	engine, err := New(rules)
+	if err != nil {
+		return err
+	}
+	l.engine.Store(engine)
+	return nil
+}
+
+// Watch reloads every interval until ctx is done, so edits made through
+// other instances are picked up.
+func (l *Loader) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := l.Reload(ctx); err != nil && ctx.Err() == nil {
+				slog.Error("reload filter rules", "error", err)
+			}
+		}
+	}
+}
+
+// FileRules are the rules that don't come from the database.
+func (l *Loader) FileRules() ([]Rule, error) {
+	return l.fileRules()
+}
+
+func (l *Loader) fileRules() ([]Rule, error) {
+	if l.path == "" {
+		return DefaultRules, nil
+	}
+	return LoadFile(l.path)
+}
@@ -0,0 +1,50 @@
+package filter
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseRules(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    []Rule
+		wantErr string
+	}{
+		{
+			name:  "Rules and comments",
+			input: "# words\nmask kerfuffle\n\nreject  buy followers \nflag crypto\n",
+			want: []Rule{
+				{Pattern: "kerfuffle", Action: Mask},
+				{Pattern: "buy followers", Action: Reject},
+				{Pattern: "crypto", Action: Flag},
+			},
+		},
+		{name: "Unknown action", input: "mask fornax\nban sharbert\n", wantErr: "line 2: invalid action"},
+		{name: "Missing pattern", input: "reject\n", wantErr: "line 1: missing pattern"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rules, err := ParseRules(strings.NewReader(tt.input))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Expected error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(rules) != len(tt.want) {
+				t.Fatalf("Expected %d rules, got %d", len(tt.want), len(rules))
+			}
+			for i := range rules {
+				if rules[i] != tt.want[i] {
+					t.Errorf("Expected %v, got %v", tt.want[i], rules[i])
+				}
+			}
+		})
+	}
+}
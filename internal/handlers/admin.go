@@ -1,20 +1,77 @@
 package handlers
 
 import (
+	"database/sql"
+	"encoding/json"
 	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/google/uuid"
+
+	"github.com/gskll/chirpy2/internal/audit"
 	"github.com/gskll/chirpy2/internal/config"
+	"github.com/gskll/chirpy2/internal/database"
+	"github.com/gskll/chirpy2/internal/events"
+	"github.com/gskll/chirpy2/internal/middleware"
+	"github.com/gskll/chirpy2/internal/user"
+)
+
+const (
+	adminDefaultLimit = 50
+	adminMaxLimit     = 200
 )
 
 type AdminRouter struct {
 	cfg *config.ApiConfig
 }
 
-func RegisterAdminHandlers(prefix string, cfg *config.ApiConfig, mux *http.ServeMux) {
+func RegisterAdminHandlers(prefix string, cfg *config.ApiConfig, mw *middleware.Middleware, mux *http.ServeMux) {
 	router := &AdminRouter{cfg: cfg}
-	mux.HandleFunc("GET "+prefix+"/metrics", router.GetMetrics)
-	mux.HandleFunc("POST "+prefix+"/reset", router.ResetMetrics)
+
+	// everything under /admin needs an admin's access token
+	admin := func(pattern string, handler http.HandlerFunc) {
+		mux.Handle(pattern, mw.Timeout(cfg.RequestTimeout, mw.MaxBytes(jsonBodyMaxBytes,
+			mw.RequireAuth(mw.RequireRole(user.RoleAdmin, handler)),
+		)))
+	}
+
+	admin("GET "+prefix+"/metrics", router.GetMetrics)
+	admin("POST "+prefix+"/reset", router.ResetMetrics)
+
+	admin("GET "+prefix+"/users", router.ListUsers)
+	admin("GET "+prefix+"/users/{userID}", router.GetUser)
+	admin("DELETE "+prefix+"/users/{userID}", router.DeleteUser)
+	admin("POST "+prefix+"/users/{userID}/suspend", router.SuspendUser)
+	admin("POST "+prefix+"/users/{userID}/unsuspend", router.UnsuspendUser)
+	admin("POST "+prefix+"/users/{userID}/revoke_sessions", router.RevokeUserSessions)
+	admin("PUT "+prefix+"/users/{userID}/chirpy_red", router.SetUserChirpyRed)
+
+	admin("GET "+prefix+"/filter/rules", router.ListFilterRules)
+	admin("PUT "+prefix+"/filter/rules", router.PutFilterRule)
+	admin("DELETE "+prefix+"/filter/rules/{ruleID}", router.DeleteFilterRule)
+	admin("POST "+prefix+"/filter/check", router.CheckFilter)
+
+	admin("GET "+prefix+"/audit", router.ListAuditEvents)
+	admin("GET "+prefix+"/audit/verify", router.VerifyAuditChain)
+}
+
+// adminUser is the admin view of a user, it includes moderation state the
+// user-facing API doesn't expose.
+type adminUser struct {
+	user.User
+	SuspendedAt     *time.Time `json:"suspended_at"`
+	SuspendedReason string     `json:"suspended_reason,omitempty"`
+}
+
+func newAdminUser(dbUser database.User) adminUser {
+	u := adminUser{User: user.NewUser(dbUser), SuspendedReason: dbUser.SuspendedReason.String}
+	if dbUser.SuspendedAt.Valid {
+		u.SuspendedAt = &dbUser.SuspendedAt.Time
+	}
+	return u
 }
 
 func (router *AdminRouter) GetMetrics(w http.ResponseWriter, req *http.Request) {
@@ -23,7 +80,7 @@ func (router *AdminRouter) GetMetrics(w http.ResponseWriter, req *http.Request)
 
 	body := fmt.Sprintf(
 		"<html><body><h1>Welcome, Chirpy Admin</h1><p>Chirpy has been visited %d times!</p></body></html>",
-		router.cfg.FileServerHits.Load(),
+		router.cfg.Metrics.Visits(),
 	)
 	w.Write([]byte(body))
 }
@@ -33,9 +90,298 @@ func (router *AdminRouter) ResetMetrics(w http.ResponseWriter, r *http.Request)
 		respondWithError(w, http.StatusForbidden, "reset forbidden on current platform")
 		return
 	}
+	actorID, _ := middleware.UserID(r.Context())
 
-	router.cfg.Db.DeleteUsers(r.Context())
-	router.cfg.FileServerHits.Store(0)
+	var mediaKeys []string
+	err := router.cfg.Tx(r.Context(), func(q *database.Queries) error {
+		var err error
+		if mediaKeys, err = q.ListMediaKeys(r.Context()); err != nil {
+			return err
+		}
+		if err := q.DeleteUsers(r.Context()); err != nil {
+			return err
+		}
+		return audit.Record(r.Context(), q, auditEvent(router.cfg, r, audit.Event{ActorID: actorID, Action: audit.AdminReset}))
+	})
+	if err != nil {
+		handleDatabaseRowError(w, err)
+		return
+	}
+	deleteBlobs(r.Context(), router.cfg, mediaKeys...)
+	router.cfg.Metrics.ResetVisits()
 	w.WriteHeader(http.StatusOK)
 	w.Write([]byte("App reset"))
 }
+
+func (router *AdminRouter) ListUsers(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	role := query.Get("role")
+	if role != "" && !user.ValidRole(role) {
+		respondWithError(w, http.StatusBadRequest, "Invalid role")
+		return
+	}
+	limit, err := queryInt(query.Get("limit"), adminDefaultLimit)
+	if err != nil || limit < 1 || limit > adminMaxLimit {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("limit must be between 1 and %d", adminMaxLimit))
+		return
+	}
+	offset, err := queryInt(query.Get("offset"), 0)
+	if err != nil || offset < 0 {
+		respondWithError(w, http.StatusBadRequest, "Invalid offset")
+		return
+	}
+
+	dbUsers, err := router.cfg.Db.SearchUsers(r.Context(), database.SearchUsersParams{
+		Query:      strings.TrimSpace(query.Get("q")),
+		Role:       role,
+		PageLimit:  int32(limit),
+		PageOffset: int32(offset),
+	})
+	if err != nil {
+		handleDatabaseRowError(w, err)
+		return
+	}
+
+	users := make([]adminUser, 0, len(dbUsers))
+	for _, dbUser := range dbUsers {
+		users = append(users, newAdminUser(dbUser))
+	}
+	respondWithJSON(w, http.StatusOK, users)
+}
+
+func (router *AdminRouter) GetUser(w http.ResponseWriter, r *http.Request) {
+	userUUID, ok := pathUserID(w, r)
+	if !ok {
+		return
+	}
+	dbUser, err := router.cfg.Db.GetUser(r.Context(), userUUID)
+	if err != nil {
+		handleDatabaseRowError(w, err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, newAdminUser(dbUser))
+}
+
+func (router *AdminRouter) DeleteUser(w http.ResponseWriter, r *http.Request) {
+	userUUID, ok := router.targetOtherUser(w, r)
+	if !ok {
+		return
+	}
+	actorID, _ := middleware.UserID(r.Context())
+
+	var mediaKeys []string
+	err := router.cfg.Tx(r.Context(), func(q *database.Queries) error {
+		dbUser, err := q.GetUser(r.Context(), userUUID)
+		if err != nil {
+			return err
+		}
+		if mediaKeys, err = q.ListUserMediaKeys(r.Context(), userUUID); err != nil {
+			return err
+		}
+		if _, err := q.DeleteUser(r.Context(), userUUID); err != nil {
+			return err
+		}
+		return audit.Record(r.Context(), q, auditEvent(router.cfg, r, audit.Event{
+			ActorID:      actorID,
+			Action:       audit.AdminUserDelete,
+			TargetUserID: userUUID,
+			Detail:       map[string]string{"email": dbUser.Email},
+		}))
+	})
+	if err != nil {
+		handleDatabaseRowError(w, err)
+		return
+	}
+	deleteBlobs(r.Context(), router.cfg, mediaKeys...)
+	closeConnections(r, router.cfg, userUUID, events.UserDeleted)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// SuspendUser blocks the user from logging in, revokes their refresh tokens
+// and closes their websocket connections. Access tokens they already hold
+// can still read until they expire, writes check for the suspension.
+func (router *AdminRouter) SuspendUser(w http.ResponseWriter, r *http.Request) {
+	userUUID, ok := router.targetOtherUser(w, r)
+	if !ok {
+		return
+	}
+	actorID, _ := middleware.UserID(r.Context())
+
+	type reqParams struct {
+		Reason string `json:"reason"`
+	}
+	params := reqParams{}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		respondWithDecodeError(w, err)
+		return
+	}
+	params.Reason = strings.TrimSpace(params.Reason)
+	if params.Reason == "" {
+		respondWithError(w, http.StatusBadRequest, "reason is required")
+		return
+	}
+
+	var dbUser database.User
+	err := router.cfg.Tx(r.Context(), func(q *database.Queries) error {
+		var err error
+		dbUser, err = q.SuspendUser(r.Context(), database.SuspendUserParams{
+			SuspendedReason: sql.NullString{String: params.Reason, Valid: true},
+			ID:              userUUID,
+		})
+		if err != nil {
+			return err
+		}
+		revoked, err := q.RevokeUserRefreshTokens(r.Context(), userUUID)
+		if err != nil {
+			return err
+		}
+		return audit.Record(r.Context(), q, auditEvent(router.cfg, r, audit.Event{
+			ActorID:      actorID,
+			Action:       audit.AdminUserSuspend,
+			TargetUserID: userUUID,
+			Detail:       map[string]any{"reason": params.Reason, "revoked_tokens": revoked},
+		}))
+	})
+	if err != nil {
+		handleDatabaseRowError(w, err)
+		return
+	}
+	closeConnections(r, router.cfg, userUUID, events.UserSuspended)
+	respondWithJSON(w, http.StatusOK, newAdminUser(dbUser))
+}
+
+func (router *AdminRouter) UnsuspendUser(w http.ResponseWriter, r *http.Request) {
+	userUUID, ok := pathUserID(w, r)
+	if !ok {
+		return
+	}
+	actorID, _ := middleware.UserID(r.Context())
+
+	var dbUser database.User
+	err := router.cfg.Tx(r.Context(), func(q *database.Queries) error {
+		var err error
+		dbUser, err = q.UnsuspendUser(r.Context(), userUUID)
+		if err != nil {
+			return err
+		}
+		return audit.Record(r.Context(), q, auditEvent(router.cfg, r, audit.Event{
+			ActorID:      actorID,
+			Action:       audit.AdminUserUnsuspend,
+			TargetUserID: userUUID,
+		}))
+	})
+	if err != nil {
+		handleDatabaseRowError(w, err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, newAdminUser(dbUser))
+}
+
+func (router *AdminRouter) RevokeUserSessions(w http.ResponseWriter, r *http.Request) {
+	userUUID, ok := pathUserID(w, r)
+	if !ok {
+		return
+	}
+	actorID, _ := middleware.UserID(r.Context())
+
+	var revoked int64
+	err := router.cfg.Tx(r.Context(), func(q *database.Queries) error {
+		if _, err := q.GetUser(r.Context(), userUUID); err != nil {
+			return err
+		}
+		var err error
+		revoked, err = q.RevokeUserRefreshTokens(r.Context(), userUUID)
+		if err != nil {
+			return err
+		}
+		return audit.Record(r.Context(), q, auditEvent(router.cfg, r, audit.Event{
+			ActorID:      actorID,
+			Action:       audit.AdminUserRevokeSessions,
+			TargetUserID: userUUID,
+			Detail:       map[string]int64{"revoked_tokens": revoked},
+		}))
+	})
+	if err != nil {
+		handleDatabaseRowError(w, err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, map[string]int64{"revoked": revoked})
+}
+
+func (router *AdminRouter) SetUserChirpyRed(w http.ResponseWriter, r *http.Request) {
+	userUUID, ok := pathUserID(w, r)
+	if !ok {
+		return
+	}
+	actorID, _ := middleware.UserID(r.Context())
+
+	type reqParams struct {
+		IsChirpyRed *bool `json:"is_chirpy_red"`
+	}
+	params := reqParams{}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		respondWithDecodeError(w, err)
+		return
+	}
+	if params.IsChirpyRed == nil {
+		respondWithError(w, http.StatusBadRequest, "is_chirpy_red is required")
+		return
+	}
+
+	var dbUser database.User
+	err := router.cfg.Tx(r.Context(), func(q *database.Queries) error {
+		err := q.SetUserChirpyRed(r.Context(), database.SetUserChirpyRedParams{
+			IsChirpyRed: *params.IsChirpyRed,
+			ID:          userUUID,
+		})
+		if err != nil {
+			return err
+		}
+		dbUser, err = q.GetUser(r.Context(), userUUID)
+		if err != nil {
+			return err
+		}
+		return audit.Record(r.Context(), q, auditEvent(router.cfg, r, audit.Event{
+			ActorID:      actorID,
+			Action:       audit.AdminUserChirpyRed,
+			TargetUserID: userUUID,
+			Detail:       map[string]bool{"is_chirpy_red": *params.IsChirpyRed},
+		}))
+	})
+	if err != nil {
+		handleDatabaseRowError(w, err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, newAdminUser(dbUser))
+}
+
+// targetOtherUser parses the path user and stops admins from suspending or
+// deleting themselves, which would lock them out.
+func (router *AdminRouter) targetOtherUser(w http.ResponseWriter, r *http.Request) (uuid.UUID, bool) {
+	userUUID, ok := pathUserID(w, r)
+	if !ok {
+		return uuid.Nil, false
+	}
+	if actorID, _ := middleware.UserID(r.Context()); actorID == userUUID {
+		respondWithError(w, http.StatusBadRequest, "cannot apply to your own account")
+		return uuid.Nil, false
+	}
+	return userUUID, true
+}
+
+func pathUserID(w http.ResponseWriter, r *http.Request) (uuid.UUID, bool) {
+	userUUID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid user id")
+		return uuid.Nil, false
+	}
+	return userUUID, true
+}
+
+func queryInt(value string, fallback int) (int, error) {
+	if value == "" {
+		return fallback, nil
+	}
+	return strconv.Atoi(value)
+}
@@ -1,60 +1,154 @@
 package handlers
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"errors"
 	"net/http"
+	"strings"
 
 	"github.com/google/uuid"
 
-	"github.com/gskll/chirpy2/internal/auth"
+	"github.com/gskll/chirpy2/internal/audit"
 	"github.com/gskll/chirpy2/internal/chirp"
 	"github.com/gskll/chirpy2/internal/config"
 	"github.com/gskll/chirpy2/internal/database"
+	"github.com/gskll/chirpy2/internal/events"
+	"github.com/gskll/chirpy2/internal/filter"
+	"github.com/gskll/chirpy2/internal/health"
+	"github.com/gskll/chirpy2/internal/middleware"
+	"github.com/gskll/chirpy2/internal/moderation"
+	"github.com/gskll/chirpy2/internal/notification"
+	"github.com/gskll/chirpy2/internal/user"
 )
 
 type APIRouter struct {
 	cfg *config.ApiConfig
 }
 
-func RegisterAPIHandlers(prefix string, cfg *config.ApiConfig, mux *http.ServeMux) {
+// jsonBodyMaxBytes limits routes that only accept small JSON documents.
+const jsonBodyMaxBytes = 8 << 10
+
+func RegisterAPIHandlers(prefix string, cfg *config.ApiConfig, mw *middleware.Middleware, mux *http.ServeMux) {
 	router := APIRouter{cfg: cfg}
 
-	mux.HandleFunc("GET "+prefix+"/healthz", router.HealthCheck)
+	// request/response routes get the default body limit and a deadline,
+	// long-lived streams are registered on the mux directly
+	public := func(pattern string, handler http.HandlerFunc) {
+		mux.Handle(pattern, mw.Timeout(cfg.RequestTimeout, mw.MaxBytes(cfg.MaxBodyBytes, handler)))
+	}
+	// mutations also check the account isn't suspended, its access tokens
+	// stay valid until they expire
+	authed := func(pattern string, handler http.HandlerFunc) {
+		if !strings.HasPrefix(pattern, "GET ") {
+			handler = mw.RequireActive(handler).ServeHTTP
+		}
+		public(pattern, mw.RequireAuth(handler).ServeHTTP)
+	}
+	small := func(handler http.HandlerFunc) http.HandlerFunc {
+		return mw.MaxBytes(jsonBodyMaxBytes, handler).ServeHTTP
+	}
+	moderator := func(pattern string, handler http.HandlerFunc) {
+		authed(pattern, mw.RequireRole(user.RoleModerator, handler).ServeHTTP)
+	}
+	// anonymous reads, a token lets authors see their own hidden chirps,
+	// followers see protected accounts and the viewer's blocks and mutes apply
+	optional := func(pattern string, handler http.HandlerFunc) {
+		public(pattern, mw.OptionalAuth(handler).ServeHTTP)
+	}
+
+	// uploads are registered directly, they need more than the default body limit
+	upload := func(pattern string, handler http.HandlerFunc) {
+		mux.Handle(pattern, mw.Timeout(
+			cfg.RequestTimeout,
+			mw.MaxBytes(cfg.MaxMediaBytes+multipartSlack, mw.RequireAuth(mw.RequireActive(handler))),
+		))
+	}
+
+	public("GET "+prefix+"/healthz", router.HealthCheck)
+	public("GET "+prefix+"/readyz", router.ReadyCheck)
 
-	mux.HandleFunc("POST "+prefix+"/users", router.CreateUser)
-	mux.HandleFunc("POST "+prefix+"/login", router.LoginUser)
-	mux.HandleFunc("POST "+prefix+"/refresh", router.RefreshToken)
-	mux.HandleFunc("POST "+prefix+"/revoke", router.RevokeRefreshToken)
-	mux.HandleFunc("PUT "+prefix+"/users", router.UpdateUserDetails)
+	public("POST "+prefix+"/users", small(router.CreateUser))
+	public("POST "+prefix+"/login", small(router.LoginUser))
+	public("POST "+prefix+"/refresh", router.RefreshToken)
+	public("POST "+prefix+"/revoke", router.RevokeRefreshToken)
+	authed("PUT "+prefix+"/users", small(router.UpdateUserDetails))
+	authed("PUT "+prefix+"/users/protected", small(router.SetProtected))
+	authed("GET "+prefix+"/security_activity", router.GetSecurityActivity)
 
-	mux.HandleFunc("POST "+prefix+"/chirps", router.CreateChirp)
-	mux.HandleFunc("GET "+prefix+"/chirps", router.GetChirps)
-	mux.HandleFunc("GET "+prefix+"/chirps/{chirpID}", router.GetChirp)
-	mux.HandleFunc("DELETE "+prefix+"/chirps/{chirpID}", router.DeleteChirp)
+	authed("POST "+prefix+"/chirps", small(router.CreateChirp))
+	optional("GET "+prefix+"/chirps", router.GetChirps)
+	optional("GET "+prefix+"/chirps/{chirpID}", router.GetChirp)
+	authed("DELETE "+prefix+"/chirps/{chirpID}", router.DeleteChirp)
+	authed("PUT "+prefix+"/chirps/{chirpID}/like", router.LikeChirp)
+	authed("DELETE "+prefix+"/chirps/{chirpID}/like", router.UnlikeChirp)
+	upload("POST "+prefix+"/media", router.UploadMedia)
+	optional("GET "+prefix+"/media/{mediaID}", router.GetMedia)
+	optional("GET "+prefix+"/media/{mediaID}/thumbnails/{size}", router.GetMediaThumbnail)
 
-	mux.HandleFunc("POST "+prefix+"/polka/webhooks", router.UpgradeUser)
+	authed("PUT "+prefix+"/follows/{userID}", router.FollowUser)
+	authed("DELETE "+prefix+"/follows/{userID}", router.UnfollowUser)
+	authed("GET "+prefix+"/follow_requests", router.ListFollowRequests)
+	authed("POST "+prefix+"/follow_requests/{userID}/approve", router.ApproveFollowRequest)
+	authed("POST "+prefix+"/follow_requests/{userID}/deny", router.DenyFollowRequest)
+	authed("GET "+prefix+"/blocks", router.ListBlocks)
+	authed("PUT "+prefix+"/blocks/{userID}", router.BlockUser)
+	authed("DELETE "+prefix+"/blocks/{userID}", router.UnblockUser)
+	authed("GET "+prefix+"/mutes", router.ListMutes)
+	authed("PUT "+prefix+"/mutes/{userID}", router.MuteUser)
+	authed("DELETE "+prefix+"/mutes/{userID}", router.UnmuteUser)
+
+	authed("GET "+prefix+"/conversations", router.ListConversations)
+	authed("POST "+prefix+"/conversations", small(router.CreateConversation))
+	authed("GET "+prefix+"/conversations/{conversationID}/messages", router.ListMessages)
+	authed("POST "+prefix+"/conversations/{conversationID}/messages", small(router.SendMessage))
+	authed("POST "+prefix+"/conversations/{conversationID}/read", router.MarkConversationRead)
+
+	authed("POST "+prefix+"/reports", small(router.CreateReport))
+	moderator("GET "+prefix+"/moderation/reports", router.ListReports)
+	moderator("GET "+prefix+"/moderation/reports/{reportID}", router.GetReport)
+	moderator("POST "+prefix+"/moderation/reports/{reportID}/resolve", small(router.ResolveReport))
+	moderator("POST "+prefix+"/moderation/chirps/{chirpID}/hide", small(router.HideChirp))
+	moderator("POST "+prefix+"/moderation/chirps/{chirpID}/unhide", router.UnhideChirp)
+	moderator("POST "+prefix+"/moderation/users/{userID}/suspend", small(router.SuspendAuthor))
+
+	authed("GET "+prefix+"/notifications", router.GetNotifications)
+	authed("GET "+prefix+"/notifications/unread_count", router.GetUnreadNotificationCount)
+	authed("POST "+prefix+"/notifications/{notificationID}/read", router.MarkNotificationRead)
+	authed("POST "+prefix+"/notifications/read", router.MarkAllNotificationsRead)
+	authed("GET "+prefix+"/notifications/mutes", router.GetNotificationMutes)
+	authed("PUT "+prefix+"/notifications/mutes/{type}", router.MuteNotificationType)
+	authed("DELETE "+prefix+"/notifications/mutes/{type}", router.UnmuteNotificationType)
+
+	mux.HandleFunc("GET "+prefix+"/stream/chirps", router.StreamChirps)
+	mux.HandleFunc("GET "+prefix+"/ws", router.ServeWebSocket)
+
+	public("POST "+prefix+"/polka/webhooks", small(router.UpgradeUser))
 }
 
+// HealthCheck is the liveness probe, it only reports the process is serving.
 func (router *APIRouter) HealthCheck(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
 	w.WriteHeader(http.StatusOK)
 	w.Write([]byte("OK"))
 }
 
-func (router *APIRouter) DeleteChirp(w http.ResponseWriter, r *http.Request) {
-	token, err := auth.GetBearerToken(r.Header)
-	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, err.Error())
-		return
-	}
+// ReadyCheck is the readiness probe, it fails while a dependency is down or
+// the server is shutting down.
+func (router *APIRouter) ReadyCheck(w http.ResponseWriter, r *http.Request) {
+	report := router.cfg.Health.Run(r.Context())
 
-	userId, err := auth.ValidateJWT(token, router.cfg.JWTSecret)
-	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, err.Error())
-		return
+	w.Header().Set("Cache-Control", "no-store")
+	code := http.StatusOK
+	if report.Status != health.StatusOK {
+		code = http.StatusServiceUnavailable
 	}
+	respondWithJSON(w, code, report)
+}
+
+func (router *APIRouter) DeleteChirp(w http.ResponseWriter, r *http.Request) {
+	userId, _ := middleware.UserID(r.Context())
 
 	chirpID := r.PathValue("chirpID")
 	chirpUUID, err := uuid.Parse(chirpID)
@@ -73,65 +167,249 @@ func (router *APIRouter) DeleteChirp(w http.ResponseWriter, r *http.Request) {
 		respondWithError(w, http.StatusForbidden, "forbidden")
 		return
 	}
+	// the uploads go with the chirp, their blobs are removed afterwards
+	mediaKeys, err := router.cfg.Db.ListChirpMediaKeys(r.Context(), chirpUUID)
+	if err != nil {
+		handleDatabaseRowError(w, err)
+		return
+	}
 
-	err = router.cfg.Db.DeleteChirp(r.Context(), chirpUUID)
+	err = router.cfg.Tx(r.Context(), func(q *database.Queries) error {
+		if err := q.DeleteChirp(r.Context(), chirpUUID); err != nil {
+			return err
+		}
+		return audit.Record(r.Context(), q, auditEvent(router.cfg, r, audit.Event{
+			ActorID:      userId,
+			Action:       audit.ChirpDelete,
+			TargetUserID: chirp.UserId,
+			Detail:       map[string]uuid.UUID{"chirp_id": chirp.ID},
+		}))
+	})
 	if err != nil {
 		handleDatabaseRowError(w, err)
 		return
 	}
+	router.cfg.Metrics.ChirpsDeleted.Inc()
+	deleteBlobs(r.Context(), router.cfg, mediaKeys...)
+
+	deleted := struct {
+		ID     uuid.UUID `json:"id"`
+		UserId uuid.UUID `json:"user_id"`
+	}{ID: chirp.ID, UserId: chirp.UserId}
+	if _, err := router.cfg.Events.PublishTo(r.Context(), events.ChirpDeleted, chirp.UserId, router.chirpAudience(r.Context(), dbChirp), deleted); err != nil {
+		middleware.Log(r.Context()).Error("publish event", "type", events.ChirpDeleted, "error", err)
+	}
 
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// CreateChirp also replies to the chirp in reply_to_id, when its reply
+// policy allows, and notifies the mentioned users.
 func (router *APIRouter) CreateChirp(w http.ResponseWriter, r *http.Request) {
-	token, err := auth.GetBearerToken(r.Header)
-	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, err.Error())
-		return
+	userId, _ := middleware.UserID(r.Context())
+
+	type reqParams struct {
+		Body        string           `json:"body"`
+		Visibility  string           `json:"visibility"`
+		ReplyPolicy string           `json:"reply_policy"`
+		ReplyToID   *uuid.UUID       `json:"reply_to_id"`
+		Mentions    []uuid.UUID      `json:"mentions"`
+		Media       []chirp.MediaRef `json:"media"`
 	}
 
-	userId, err := auth.ValidateJWT(token, router.cfg.JWTSecret)
-	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, err.Error())
+	params := reqParams{}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		respondWithDecodeError(w, err)
 		return
 	}
 
-	type reqParams struct {
-		Body string `json:"body"`
+	body, bodyErr := chirp.Validate(params.Body)
+	audience, audienceErr := chirp.ValidateAudience(chirp.Audience{
+		Visibility:  params.Visibility,
+		ReplyPolicy: params.ReplyPolicy,
+		Mentions:    params.Mentions,
+	}, userId)
+	mediaRefs, mediaErr := chirp.ValidateMedia(params.Media)
+	if err := chirp.JoinErrors(bodyErr, audienceErr, mediaErr); err != nil {
+		respondWithValidationError(w, err)
+		return
+	}
+	if len(audience.Mentions) > 0 {
+		n, err := router.cfg.Db.CountUsersByID(r.Context(), audience.Mentions)
+		if err != nil {
+			handleDatabaseRowError(w, err)
+			return
+		}
+		if int(n) != len(audience.Mentions) {
+			respondWithValidationError(w, chirp.ValidationErrors{{
+				Field:   "mentions",
+				Code:    chirp.CodeNotFound,
+				Message: "Mentioned user not found",
+			}})
+			return
+		}
+	}
+	var parent *database.Chirp
+	if params.ReplyToID != nil {
+		dbParent, ok := router.replyParent(w, r, *params.ReplyToID)
+		if !ok {
+			return
+		}
+		parent = &dbParent
 	}
 
-	params := reqParams{}
-	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
-		respondWithError(w, http.StatusInternalServerError, err.Error())
+	filtered := router.cfg.Filter.Engine().Check(body)
+	if filtered.Rejected() {
+		router.cfg.Metrics.ChirpsFiltered.WithLabelValues(string(filter.Reject)).Inc()
+		respondWithError(w, http.StatusBadRequest, "Chirp contains blocked content")
 		return
 	}
 
-	err = chirp.ValidateLength(params.Body)
+	createParams := database.CreateChirpParams{
+		Body:        filtered.Text,
+		UserID:      userId,
+		Visibility:  audience.Visibility,
+		ReplyPolicy: audience.ReplyPolicy,
+		Mentions:    audience.Mentions,
+	}
+	if parent != nil {
+		createParams.ReplyToID = uuid.NullUUID{UUID: parent.ID, Valid: true}
+	}
+	var dbChirp database.Chirp
+	err := router.cfg.Tx(r.Context(), func(q *database.Queries) error {
+		var err error
+		dbChirp, err = q.CreateChirp(r.Context(), createParams)
+		if err != nil {
+			return err
+		}
+		for i, ref := range mediaRefs {
+			n, err := q.AttachMediaUpload(r.Context(), database.AttachMediaUploadParams{
+				ChirpID:  uuid.NullUUID{UUID: dbChirp.ID, Valid: true},
+				Position: int32(i),
+				AltText:  ref.AltText,
+				ID:       ref.ID,
+				UserID:   userId,
+			})
+			if err != nil {
+				return err
+			}
+			if n == 0 {
+				return errMediaUnavailable
+			}
+		}
+		if !filtered.Flagged() {
+			return nil
+		}
+		_, err = q.CreateReport(r.Context(), database.CreateReportParams{
+			ReportedUserID: userId,
+			ChirpID:        uuid.NullUUID{UUID: dbChirp.ID, Valid: true},
+			Category:       moderation.Filter,
+			Comment:        "matched " + strings.Join(filterPatterns(filtered, filter.Flag), ", "),
+		})
+		return err
+	})
+	if errors.Is(err, errMediaUnavailable) {
+		respondWithValidationError(w, chirp.ValidationErrors{{
+			Field:   "media",
+			Code:    chirp.CodeNotFound,
+			Message: "Image not found or already attached",
+		}})
+		return
+	}
 	if err != nil {
-		respondWithError(w, http.StatusBadRequest, err.Error())
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	for _, action := range []filter.Action{filter.Mask, filter.Flag} {
+		if len(filterPatterns(filtered, action)) > 0 {
+			router.cfg.Metrics.ChirpsFiltered.WithLabelValues(string(action)).Inc()
+		}
+	}
+	chirps := []chirp.Chirp{chirp.NewChirp(dbChirp)}
+	if err := router.loadMedia(r.Context(), chirps); err != nil {
+		handleDatabaseRowError(w, err)
 		return
 	}
-	cleaned := chirp.Clean(params.Body)
+	chirp := chirps[0]
+	router.cfg.Metrics.ChirpsCreated.Inc()
+
+	if _, err := router.cfg.Events.PublishTo(r.Context(), events.ChirpCreated, chirp.UserId, router.chirpAudience(r.Context(), dbChirp), chirp); err != nil {
+		middleware.Log(r.Context()).Error("publish event", "type", events.ChirpCreated, "error", err)
+	}
+	if parent != nil {
+		router.notifyReply(r.Context(), *parent, dbChirp)
+	}
+	for _, mentioned := range dbChirp.Mentions {
+		if !router.notify(r.Context(), mentioned, userId, notification.Mention, uuid.NullUUID{UUID: dbChirp.ID, Valid: true}) {
+			continue
+		}
+		if _, err := router.cfg.Events.Publish(r.Context(), events.ChirpMentioned, mentioned, chirp); err != nil {
+			middleware.Log(r.Context()).Error("publish event", "type", events.ChirpMentioned, "error", err)
+		}
+	}
+
+	respondWithJSON(w, http.StatusCreated, chirp)
+}
 
-	dbChirp, err := router.cfg.Db.CreateChirp(
+// replyParent loads the chirp being replied to, which must be visible to the
+// caller and allow them to reply.
+func (router *APIRouter) replyParent(w http.ResponseWriter, r *http.Request, parentID uuid.UUID) (database.Chirp, bool) {
+	userId, _ := middleware.UserID(r.Context())
+
+	parent, err := router.cfg.Db.GetChirp(r.Context(), parentID)
+	if err != nil {
+		handleDatabaseRowError(w, err)
+		return database.Chirp{}, false
+	}
+	visible, err := router.canSeeChirp(r, parent.ID)
+	if err != nil {
+		handleDatabaseRowError(w, err)
+		return database.Chirp{}, false
+	}
+	if !visible || chirp.Hidden(parent) {
+		respondWithError(w, http.StatusNotFound, "chirp not found")
+		return database.Chirp{}, false
+	}
+	allowed, err := router.cfg.Db.CanReplyTo(
 		r.Context(),
-		database.CreateChirpParams{Body: cleaned, UserID: userId},
+		database.CanReplyToParams{UserID: userId, ID: parent.ID},
 	)
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, err.Error())
-		return
+		handleDatabaseRowError(w, err)
+		return database.Chirp{}, false
 	}
-	chirp := chirp.NewChirp(dbChirp)
+	if !allowed {
+		respondWithError(w, http.StatusForbidden, "You can't reply to this chirp")
+		return database.Chirp{}, false
+	}
+	return parent, true
+}
 
-	respondWithJSON(w, http.StatusCreated, chirp)
+// notifyReply notifies the parent's author, unless the reply's visibility
+// keeps it from them.
+func (router *APIRouter) notifyReply(ctx context.Context, parent, reply database.Chirp) {
+	visible, err := router.cfg.Db.ChirpVisibleTo(
+		ctx,
+		database.ChirpVisibleToParams{ViewerID: parent.UserID, ID: reply.ID},
+	)
+	if err != nil {
+		middleware.Log(ctx).Error("check reply visibility", "error", err)
+		return
+	}
+	if visible {
+		router.notify(ctx, parent.UserID, reply.UserID, notification.Reply, uuid.NullUUID{UUID: parent.ID, Valid: true})
+	}
 }
 
 func (router *APIRouter) GetChirps(w http.ResponseWriter, r *http.Request) {
 	var (
-		dbChirps []database.Chirp
-		err      error
+		dbChirps      []database.Chirp
+		includeHidden bool
+		err           error
 	)
 
+	// anonymous viewers get uuid.Nil, which has no blocks or mutes
+	viewerId, _ := middleware.UserID(r.Context())
 	sort := r.URL.Query().Get("sort")
 	if sort != "desc" && sort != "asc" {
 		sort = "asc"
@@ -144,12 +422,23 @@ func (router *APIRouter) GetChirps(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
+		// authors see their own hidden chirps, with the moderation notice.
+		// Muted authors are still listed when asked for by id
+		includeHidden = viewerId == authorUUID
 		dbChirps, err = router.cfg.Db.GetChirpsByAuthor(
 			r.Context(),
-			database.GetChirpsByAuthorParams{UserID: authorUUID, Sort: sort},
+			database.GetChirpsByAuthorParams{
+				UserID:        authorUUID,
+				IncludeHidden: includeHidden,
+				ViewerID:      viewerId,
+				Sort:          sort,
+			},
 		)
 	} else {
-		dbChirps, err = router.cfg.Db.GetChirps(r.Context(), sort)
+		dbChirps, err = router.cfg.Db.GetChirps(
+			r.Context(),
+			database.GetChirpsParams{ViewerID: viewerId, Sort: sort},
+		)
 	}
 	if err != nil {
 		handleDatabaseRowError(w, err)
@@ -158,8 +447,15 @@ func (router *APIRouter) GetChirps(w http.ResponseWriter, r *http.Request) {
 
 	chirps := make([]chirp.Chirp, 0, len(dbChirps))
 	for _, dbChirp := range dbChirps {
-		chirp := chirp.NewChirp(dbChirp)
-		chirps = append(chirps, chirp)
+		if includeHidden {
+			chirps = append(chirps, chirp.NewModeratedChirp(dbChirp))
+			continue
+		}
+		chirps = append(chirps, chirp.NewChirp(dbChirp))
+	}
+	if err := router.loadMedia(r.Context(), chirps); err != nil {
+		handleDatabaseRowError(w, err)
+		return
 	}
 
 	respondWithJSON(w, http.StatusOK, chirps)
@@ -177,9 +473,90 @@ func (router *APIRouter) GetChirp(w http.ResponseWriter, r *http.Request) {
 		handleDatabaseRowError(w, err)
 		return
 	}
-	chirp := chirp.NewChirp(dbChirp)
+	visible, err := router.canSeeChirp(r, dbChirp.ID)
+	if err != nil {
+		handleDatabaseRowError(w, err)
+		return
+	}
+	if !visible {
+		respondWithError(w, http.StatusNotFound, "chirp not found")
+		return
+	}
+	chirps := []chirp.Chirp{chirp.NewChirp(dbChirp)}
+	if chirp.Hidden(dbChirp) {
+		if !router.canSeeHidden(r, dbChirp) {
+			respondWithError(w, http.StatusNotFound, "chirp not found")
+			return
+		}
+		chirps[0] = chirp.NewModeratedChirp(dbChirp)
+	}
+	if err := router.loadMedia(r.Context(), chirps); err != nil {
+		handleDatabaseRowError(w, err)
+		return
+	}
 
-	respondWithJSON(w, http.StatusOK, chirp)
+	respondWithJSON(w, http.StatusOK, chirps[0])
+}
+
+// LikeChirp likes a chirp the caller can see, notifying its author. Liking
+// a chirp again is a no-op.
+func (router *APIRouter) LikeChirp(w http.ResponseWriter, r *http.Request) {
+	userId, _ := middleware.UserID(r.Context())
+
+	chirpUUID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid chirp id")
+		return
+	}
+	dbChirp, err := router.cfg.Db.GetChirp(r.Context(), chirpUUID)
+	if err != nil {
+		handleDatabaseRowError(w, err)
+		return
+	}
+	visible, err := router.canSeeChirp(r, dbChirp.ID)
+	if err != nil {
+		handleDatabaseRowError(w, err)
+		return
+	}
+	if !visible || chirp.Hidden(dbChirp) {
+		respondWithError(w, http.StatusNotFound, "chirp not found")
+		return
+	}
+
+	n, err := router.cfg.Db.LikeChirp(
+		r.Context(),
+		database.LikeChirpParams{UserID: userId, ChirpID: dbChirp.ID},
+	)
+	if err != nil {
+		handleDatabaseRowError(w, err)
+		return
+	}
+	if n > 0 {
+		router.notify(r.Context(), dbChirp.UserID, userId, notification.Like, uuid.NullUUID{UUID: dbChirp.ID, Valid: true})
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// UnlikeChirp works whether or not the caller can still see the chirp.
+func (router *APIRouter) UnlikeChirp(w http.ResponseWriter, r *http.Request) {
+	userId, _ := middleware.UserID(r.Context())
+
+	chirpUUID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid chirp id")
+		return
+	}
+	_, err = router.cfg.Db.UnlikeChirp(
+		r.Context(),
+		database.UnlikeChirpParams{UserID: userId, ChirpID: chirpUUID},
+	)
+	if err != nil {
+		handleDatabaseRowError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
 }
 
 func handleDatabaseRowError(w http.ResponseWriter, err error) {
@@ -188,6 +565,10 @@ func handleDatabaseRowError(w http.ResponseWriter, err error) {
 			respondWithError(w, http.StatusNotFound, err.Error())
 			return
 		}
+		if errors.Is(err, context.DeadlineExceeded) {
+			respondWithError(w, http.StatusServiceUnavailable, "request timed out")
+			return
+		}
 		respondWithError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
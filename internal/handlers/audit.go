@@ -0,0 +1,173 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/gskll/chirpy2/internal/audit"
+	"github.com/gskll/chirpy2/internal/config"
+	"github.com/gskll/chirpy2/internal/database"
+	"github.com/gskll/chirpy2/internal/middleware"
+)
+
+const (
+	securityActivityDefaultLimit = 20
+	securityActivityMaxLimit     = 100
+)
+
+// auditEvent fills in where the request came from.
+func auditEvent(cfg *config.ApiConfig, r *http.Request, event audit.Event) audit.Event {
+	event.IP = middleware.ClientIP(r, cfg.TrustedProxies).String()
+	event.UserAgent = r.UserAgent()
+	return event
+}
+
+// recordAudit is for events that aren't tied to a write, e.g. failed logins,
+// the request carries on if the event can't be recorded.
+func recordAudit(cfg *config.ApiConfig, r *http.Request, event audit.Event) {
+	if err := audit.Record(r.Context(), cfg.Db, auditEvent(cfg, r, event)); err != nil {
+		middleware.Log(r.Context()).Error("record audit event", "action", event.Action, "error", err)
+	}
+}
+
+func (router *AdminRouter) ListAuditEvents(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	var params database.ListAuditEventsParams
+	for name, dst := range map[string]*uuid.NullUUID{
+		"actor_id":       &params.ActorID,
+		"target_user_id": &params.TargetUserID,
+	} {
+		if query.Get(name) == "" {
+			continue
+		}
+		id, err := uuid.Parse(query.Get(name))
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid "+name)
+			return
+		}
+		*dst = uuid.NullUUID{UUID: id, Valid: true}
+	}
+	params.Action = query.Get("action")
+
+	limit, err := queryInt(query.Get("limit"), adminDefaultLimit)
+	if err != nil || limit < 1 || limit > adminMaxLimit {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("limit must be between 1 and %d", adminMaxLimit))
+		return
+	}
+	params.PageLimit = int32(limit)
+	params.BeforeSeq, err = queryBeforeSeq(query.Get("before_seq"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid before_seq")
+		return
+	}
+
+	dbEvents, err := router.cfg.Db.ListAuditEvents(r.Context(), params)
+	if err != nil {
+		handleDatabaseRowError(w, err)
+		return
+	}
+
+	entries := make([]audit.Entry, 0, len(dbEvents))
+	for _, dbEvent := range dbEvents {
+		entries = append(entries, audit.NewEntry(dbEvent))
+	}
+	respondWithJSON(w, http.StatusOK, entries)
+}
+
+// VerifyAuditChain walks the whole log, it's meant for occasional checks
+// rather than polling.
+func (router *AdminRouter) VerifyAuditChain(w http.ResponseWriter, r *http.Request) {
+	result, err := audit.Verify(r.Context(), router.cfg.Db)
+	if err != nil {
+		handleDatabaseRowError(w, err)
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	respondWithJSON(w, http.StatusOK, result)
+}
+
+// securityEvent is the user's view of an audit event. Staff accounts aren't
+// identified, only that someone other than the user acted.
+type securityEvent struct {
+	Seq       int64           `json:"seq"`
+	Action    string          `json:"action"`
+	Actor     string          `json:"actor"`
+	IP        string          `json:"ip,omitempty"`
+	UserAgent string          `json:"user_agent,omitempty"`
+	Detail    json.RawMessage `json:"detail"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+func newSecurityEvent(userId uuid.UUID, dbEvent database.AuditEvent) securityEvent {
+	entry := audit.NewEntry(dbEvent)
+	event := securityEvent{
+		Seq:       entry.Seq,
+		Action:    entry.Action,
+		Actor:     "system",
+		IP:        entry.IP,
+		UserAgent: entry.UserAgent,
+		Detail:    entry.Detail,
+		CreatedAt: entry.CreatedAt,
+	}
+	switch {
+	case entry.ActorID == nil:
+	case *entry.ActorID == userId:
+		event.Actor = "self"
+	default:
+		event.Actor = "staff"
+		event.IP, event.UserAgent = "", ""
+	}
+	return event
+}
+
+// GetSecurityActivity lists recent events on the user's account, newest
+// first. Pass the last seq as before_seq to page back.
+func (router *APIRouter) GetSecurityActivity(w http.ResponseWriter, r *http.Request) {
+	userId, _ := middleware.UserID(r.Context())
+	query := r.URL.Query()
+
+	limit, err := queryInt(query.Get("limit"), securityActivityDefaultLimit)
+	if err != nil || limit < 1 || limit > securityActivityMaxLimit {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("limit must be between 1 and %d", securityActivityMaxLimit))
+		return
+	}
+	beforeSeq, err := queryBeforeSeq(query.Get("before_seq"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid before_seq")
+		return
+	}
+
+	dbEvents, err := router.cfg.Db.ListUserSecurityActivity(r.Context(), database.ListUserSecurityActivityParams{
+		UserID:    userId,
+		BeforeSeq: beforeSeq,
+		PageLimit: int32(limit),
+	})
+	if err != nil {
+		handleDatabaseRowError(w, err)
+		return
+	}
+
+	events := make([]securityEvent, 0, len(dbEvents))
+	for _, dbEvent := range dbEvents {
+		events = append(events, newSecurityEvent(userId, dbEvent))
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	respondWithJSON(w, http.StatusOK, events)
+}
+
+func queryBeforeSeq(value string) (int64, error) {
+	if value == "" {
+		return 0, nil
+	}
+	seq, err := strconv.ParseInt(value, 10, 64)
+	if err == nil && seq < 1 {
+		err = fmt.Errorf("before_seq must be positive")
+	}
+	return seq, err
+}
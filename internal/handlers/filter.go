@@ -0,0 +1,178 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/gskll/chirpy2/internal/audit"
+	"github.com/gskll/chirpy2/internal/database"
+	"github.com/gskll/chirpy2/internal/filter"
+	"github.com/gskll/chirpy2/internal/middleware"
+)
+
+// filterRule is a rule as admins see it. Rules from the word list file have
+// no id and can only be changed by editing the file, or overridden by a
+// database rule with the same pattern.
+type filterRule struct {
+	ID        *uuid.UUID    `json:"id,omitempty"`
+	Pattern   string        `json:"pattern"`
+	Action    filter.Action `json:"action"`
+	Source    string        `json:"source"`
+	CreatedBy *uuid.UUID    `json:"created_by,omitempty"`
+	UpdatedAt *time.Time    `json:"updated_at,omitempty"`
+}
+
+func newFilterRule(dbRule database.FilterRule) filterRule {
+	rule := filterRule{
+		ID:        &dbRule.ID,
+		Pattern:   dbRule.Pattern,
+		Action:    filter.Action(dbRule.Action),
+		Source:    "database",
+		UpdatedAt: &dbRule.UpdatedAt,
+	}
+	if dbRule.CreatedBy.Valid {
+		rule.CreatedBy = &dbRule.CreatedBy.UUID
+	}
+	return rule
+}
+
+func (router *AdminRouter) ListFilterRules(w http.ResponseWriter, r *http.Request) {
+	fileRules, err := router.cfg.Filter.FileRules()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	dbRules, err := router.cfg.Db.ListFilterRules(r.Context())
+	if err != nil {
+		handleDatabaseRowError(w, err)
+		return
+	}
+
+	rules := make([]filterRule, 0, len(fileRules)+len(dbRules))
+	for _, rule := range fileRules {
+		rules = append(rules, filterRule{Pattern: rule.Pattern, Action: rule.Action, Source: "file"})
+	}
+	for _, dbRule := range dbRules {
+		rules = append(rules, newFilterRule(dbRule))
+	}
+	respondWithJSON(w, http.StatusOK, rules)
+}
+
+// PutFilterRule adds a rule or changes the action of the rule with the same
+// pattern. Patterns are stored lowercased with single spaces between words.
+func (router *AdminRouter) PutFilterRule(w http.ResponseWriter, r *http.Request) {
+	actorID, _ := middleware.UserID(r.Context())
+
+	type reqParams struct {
+		Pattern string `json:"pattern"`
+		Action  string `json:"action"`
+	}
+	params := reqParams{}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		respondWithDecodeError(w, err)
+		return
+	}
+	action, err := filter.ParseAction(params.Action)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	pattern := strings.Join(strings.Fields(strings.ToLower(params.Pattern)), " ")
+	if _, err := filter.New([]filter.Rule{{Pattern: pattern, Action: action}}); err != nil {
+		respondWithError(w, http.StatusBadRequest, "pattern must contain a word")
+		return
+	}
+
+	var dbRule database.FilterRule
+	err = router.cfg.Tx(r.Context(), func(q *database.Queries) error {
+		var err error
+		dbRule, err = q.UpsertFilterRule(r.Context(), database.UpsertFilterRuleParams{
+			Pattern:   pattern,
+			Action:    string(action),
+			CreatedBy: uuid.NullUUID{UUID: actorID, Valid: true},
+		})
+		if err != nil {
+			return err
+		}
+		return audit.Record(r.Context(), q, auditEvent(router.cfg, r, audit.Event{
+			ActorID: actorID,
+			Action:  audit.AdminFilterRuleSet,
+			Detail:  map[string]string{"pattern": pattern, "action": string(action)},
+		}))
+	})
+	if err != nil {
+		handleDatabaseRowError(w, err)
+		return
+	}
+	router.reloadFilter(r)
+	respondWithJSON(w, http.StatusOK, newFilterRule(dbRule))
+}
+
+func (router *AdminRouter) DeleteFilterRule(w http.ResponseWriter, r *http.Request) {
+	ruleUUID, err := uuid.Parse(r.PathValue("ruleID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid rule id")
+		return
+	}
+	actorID, _ := middleware.UserID(r.Context())
+
+	err = router.cfg.Tx(r.Context(), func(q *database.Queries) error {
+		dbRule, err := q.DeleteFilterRule(r.Context(), ruleUUID)
+		if err != nil {
+			return err
+		}
+		return audit.Record(r.Context(), q, auditEvent(router.cfg, r, audit.Event{
+			ActorID: actorID,
+			Action:  audit.AdminFilterRuleDelete,
+			Detail:  map[string]string{"pattern": dbRule.Pattern, "action": dbRule.Action},
+		}))
+	})
+	if err != nil {
+		handleDatabaseRowError(w, err)
+		return
+	}
+	router.reloadFilter(r)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// CheckFilter runs text through the current rules without creating a chirp.
+func (router *AdminRouter) CheckFilter(w http.ResponseWriter, r *http.Request) {
+	type reqParams struct {
+		Body string `json:"body"`
+	}
+	params := reqParams{}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		respondWithDecodeError(w, err)
+		return
+	}
+
+	result := router.cfg.Filter.Engine().Check(params.Body)
+	respondWithJSON(w, http.StatusOK, struct {
+		filter.Result
+		Rejected bool `json:"rejected"`
+		Flagged  bool `json:"flagged"`
+	}{Result: result, Rejected: result.Rejected(), Flagged: result.Flagged()})
+}
+
+// reloadFilter applies an edit on this instance straight away, other
+// instances pick it up on their next periodic reload.
+func (router *AdminRouter) reloadFilter(r *http.Request) {
+	if err := router.cfg.Filter.Reload(r.Context()); err != nil {
+		middleware.Log(r.Context()).Error("reload filter rules", "error", err)
+	}
+}
+
+// filterPatterns lists the patterns of the matched rules with action.
+func filterPatterns(result filter.Result, action filter.Action) []string {
+	var patterns []string
+	for _, m := range result.Matches {
+		if m.Rule.Action == action {
+			patterns = append(patterns, m.Rule.Pattern)
+		}
+	}
+	return patterns
+}
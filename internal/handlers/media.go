@@ -0,0 +1,349 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"github.com/gskll/chirpy2/internal/chirp"
+	"github.com/gskll/chirpy2/internal/config"
+	"github.com/gskll/chirpy2/internal/database"
+	"github.com/gskll/chirpy2/internal/media"
+	"github.com/gskll/chirpy2/internal/middleware"
+	"github.com/gskll/chirpy2/internal/storage"
+)
+
+// multipartSlack allows for the multipart boundaries and headers around an
+// upload on top of the image size limit.
+const multipartSlack = 64 << 10
+
+// mediaCacheControl lets clients and shared caches keep images anyone can see
+// for a few minutes, after which they revalidate with the ETag so deletions,
+// takedowns and narrowed audiences apply. Other images are revalidated on
+// every use, the visibility check runs before the 304.
+const (
+	mediaCacheControl        = "public, max-age=300"
+	privateMediaCacheControl = "private, no-cache"
+)
+
+var (
+	errUploadTooLarge = errors.New("upload too large")
+	errMissingFile    = errors.New(`missing "file" field`)
+	errEmptyUpload    = errors.New("empty upload")
+
+	errMediaUnavailable = errors.New("media not found or already attached")
+)
+
+// UploadMedia takes an image as the raw request body or as the "file" field
+// of a multipart form. The stored image is re-encoded, see media.Process, and
+// can be attached to one chirp.
+func (router *APIRouter) UploadMedia(w http.ResponseWriter, r *http.Request) {
+	userId, _ := middleware.UserID(r.Context())
+
+	data, err := readUpload(r, router.cfg.MaxMediaBytes)
+	if errors.Is(err, errUploadTooLarge) {
+		respondWithError(
+			w,
+			http.StatusRequestEntityTooLarge,
+			fmt.Sprintf("image too large, max %d bytes", router.cfg.MaxMediaBytes),
+		)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	img, err := media.Process(r.Context(), data)
+	if r.Context().Err() != nil {
+		// still waiting for a decode slot at the deadline
+		respondWithError(w, http.StatusServiceUnavailable, "too many uploads being processed, try again")
+		return
+	}
+	if errors.Is(err, media.ErrUnsupportedType) {
+		respondWithError(w, http.StatusUnsupportedMediaType, err.Error())
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	mediaID := uuid.New()
+	key := "media/" + mediaID.String() + "/original" + img.Ext()
+	err = router.cfg.Storage.Put(r.Context(), key, bytes.NewReader(img.Data), int64(len(img.Data)), img.ContentType)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	dbMedia, err := router.cfg.Db.CreateMediaUpload(r.Context(), database.CreateMediaUploadParams{
+		ID:          mediaID,
+		UserID:      userId,
+		ContentType: img.ContentType,
+		StorageKey:  key,
+		SizeBytes:   int64(len(img.Data)),
+		Width:       int32(img.Width),
+		Height:      int32(img.Height),
+	})
+	if err != nil {
+		deleteBlobs(r.Context(), router.cfg, key)
+		handleDatabaseRowError(w, err)
+		return
+	}
+	if !router.cfg.Thumbnails.Enqueue(dbMedia.ID) {
+		// picked up when the server next starts
+		middleware.Log(r.Context()).Warn("thumbnail queue full", "media_id", dbMedia.ID)
+	}
+
+	respondWithJSON(w, http.StatusCreated, chirp.NewMedia(dbMedia))
+}
+
+// GetMedia serves an upload to whoever can see the chirp it's attached to,
+// or only to the uploader until it's attached.
+func (router *APIRouter) GetMedia(w http.ResponseWriter, r *http.Request) {
+	dbMedia, public, ok := router.visibleMedia(w, r)
+	if !ok {
+		return
+	}
+	router.serveBlob(w, r, blob{
+		key:         dbMedia.StorageKey,
+		contentType: dbMedia.ContentType,
+		size:        dbMedia.SizeBytes,
+		etag:        `"` + dbMedia.ID.String() + `-original"`,
+		public:      public,
+	})
+}
+
+// GetMediaThumbnail serves a thumbnail to whoever can see the upload.
+func (router *APIRouter) GetMediaThumbnail(w http.ResponseWriter, r *http.Request) {
+	dbMedia, public, ok := router.visibleMedia(w, r)
+	if !ok {
+		return
+	}
+	size := r.PathValue("size")
+	dbThumbnail, err := router.cfg.Db.GetMediaThumbnail(
+		r.Context(),
+		database.GetMediaThumbnailParams{MediaID: dbMedia.ID, Size: size},
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusNotFound, "thumbnail not found")
+		return
+	}
+	if err != nil {
+		handleDatabaseRowError(w, err)
+		return
+	}
+	router.serveBlob(w, r, blob{
+		key:         dbThumbnail.StorageKey,
+		contentType: dbThumbnail.ContentType,
+		size:        dbThumbnail.SizeBytes,
+		etag:        `"` + dbMedia.ID.String() + "-" + size + `"`,
+		public:      public,
+	})
+}
+
+// visibleMedia loads the upload in the path, public when anyone can see it.
+func (router *APIRouter) visibleMedia(w http.ResponseWriter, r *http.Request) (database.MediaUpload, bool, bool) {
+	mediaUUID, err := uuid.Parse(r.PathValue("mediaID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid media id")
+		return database.MediaUpload{}, false, false
+	}
+	dbMedia, err := router.cfg.Db.GetMediaUpload(r.Context(), mediaUUID)
+	if err != nil {
+		handleDatabaseRowError(w, err)
+		return database.MediaUpload{}, false, false
+	}
+	visible, public, err := router.canSeeMedia(r, dbMedia)
+	if err != nil {
+		handleDatabaseRowError(w, err)
+		return database.MediaUpload{}, false, false
+	}
+	if !visible {
+		respondWithError(w, http.StatusNotFound, "media not found")
+		return database.MediaUpload{}, false, false
+	}
+	return dbMedia, public, true
+}
+
+func (router *APIRouter) canSeeMedia(r *http.Request, dbMedia database.MediaUpload) (visible, public bool, err error) {
+	viewerId, _ := middleware.UserID(r.Context())
+	if !dbMedia.ChirpID.Valid {
+		return viewerId == dbMedia.UserID, false, nil
+	}
+
+	dbChirp, err := router.cfg.Db.GetChirp(r.Context(), dbMedia.ChirpID.UUID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, false, nil
+	}
+	if err != nil {
+		return false, false, err
+	}
+	hidden := chirp.Hidden(dbChirp)
+	if hidden && !router.canSeeHidden(r, dbChirp) {
+		return false, false, nil
+	}
+	visible, err = router.canSeeChirp(r, dbChirp.ID)
+	if err != nil || !visible {
+		return false, false, err
+	}
+	return true, !hidden && router.chirpAudience(r.Context(), dbChirp).Public(), nil
+}
+
+type blob struct {
+	key         string
+	contentType string
+	size        int64
+	etag        string
+	// public lets shared caches store the blob, not just the viewer's
+	public bool
+}
+
+// serveBlob streams a blob from the media storage with caching headers,
+// answering conditional requests with 304.
+func (router *APIRouter) serveBlob(w http.ResponseWriter, r *http.Request, b blob) {
+	cacheControl := privateMediaCacheControl
+	if b.public {
+		cacheControl = mediaCacheControl
+	}
+	if etagMatches(r.Header.Get("If-None-Match"), b.etag) {
+		w.Header().Set("Cache-Control", cacheControl)
+		w.Header().Set("ETag", b.etag)
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	data, err := router.cfg.Storage.Open(r.Context(), b.key)
+	if errors.Is(err, storage.ErrNotFound) {
+		respondWithError(w, http.StatusNotFound, "media not found")
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer data.Close()
+
+	w.Header().Set("Cache-Control", cacheControl)
+	w.Header().Set("ETag", b.etag)
+	w.Header().Set("Content-Type", b.contentType)
+	w.Header().Set("Content-Length", strconv.FormatInt(b.size, 10))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(http.StatusOK)
+	if _, err := io.Copy(w, data); err != nil {
+		middleware.Log(r.Context()).Error("serve media", "key", b.key, "error", err)
+	}
+}
+
+// etagMatches reports whether an If-None-Match header lists etag.
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// loadMedia fills in the images of chirps, with their thumbnails.
+func (router *APIRouter) loadMedia(ctx context.Context, chirps []chirp.Chirp) error {
+	if len(chirps) == 0 {
+		return nil
+	}
+	ids := make([]uuid.UUID, len(chirps))
+	index := make(map[uuid.UUID]int, len(chirps))
+	for i, c := range chirps {
+		ids[i] = c.ID
+		index[c.ID] = i
+	}
+
+	dbMedia, err := router.cfg.Db.ListChirpMedia(ctx, ids)
+	if err != nil || len(dbMedia) == 0 {
+		return err
+	}
+	mediaIds := make([]uuid.UUID, len(dbMedia))
+	for i, m := range dbMedia {
+		mediaIds[i] = m.ID
+	}
+	dbThumbnails, err := router.cfg.Db.ListMediaThumbnails(ctx, mediaIds)
+	if err != nil {
+		return err
+	}
+	thumbnails := make(map[uuid.UUID][]chirp.Thumbnail)
+	for _, t := range dbThumbnails {
+		thumbnails[t.MediaID] = append(thumbnails[t.MediaID], chirp.NewThumbnail(t))
+	}
+
+	for _, m := range dbMedia {
+		attached := chirp.NewMedia(m)
+		if t, ok := thumbnails[m.ID]; ok {
+			attached.Thumbnails = t
+		}
+		i := index[m.ChirpID.UUID]
+		chirps[i].Media = append(chirps[i].Media, attached)
+	}
+	return nil
+}
+
+// deleteBlobs is best effort, a blob left behind only takes up space. Deleting
+// uploads cascades from their chirp or user, so their keys are listed before
+// the delete and the blobs removed after it commits.
+func deleteBlobs(ctx context.Context, cfg *config.ApiConfig, keys ...string) {
+	if err := storage.DeleteAll(ctx, cfg.Storage, keys...); err != nil {
+		middleware.Log(ctx).Error("delete media blobs", "error", err)
+	}
+}
+
+// readUpload reads at most maxBytes of the raw body or the "file" field of a
+// multipart form.
+func readUpload(r *http.Request, maxBytes int64) ([]byte, error) {
+	var body io.Reader = r.Body
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType == "multipart/form-data" {
+		mr, err := r.MultipartReader()
+		if err != nil {
+			return nil, err
+		}
+		for {
+			part, err := mr.NextPart()
+			if errors.Is(err, io.EOF) {
+				return nil, errMissingFile
+			}
+			if err != nil {
+				return nil, uploadError(err)
+			}
+			if part.FormName() == "file" {
+				body = part
+				break
+			}
+		}
+	}
+
+	data, err := io.ReadAll(io.LimitReader(body, maxBytes+1))
+	if err != nil {
+		return nil, uploadError(err)
+	}
+	if int64(len(data)) > maxBytes {
+		return nil, errUploadTooLarge
+	}
+	if len(data) == 0 {
+		return nil, errEmptyUpload
+	}
+	return data, nil
+}
+
+func uploadError(err error) error {
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		return errUploadTooLarge
+	}
+	return err
+}
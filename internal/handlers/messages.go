@@ -0,0 +1,303 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/gskll/chirpy2/internal/chirp"
+	"github.com/gskll/chirpy2/internal/database"
+	"github.com/gskll/chirpy2/internal/events"
+	"github.com/gskll/chirpy2/internal/message"
+	"github.com/gskll/chirpy2/internal/middleware"
+)
+
+const (
+	defaultMessagesLimit = 50
+	maxMessagesLimit     = 100
+)
+
+// ListConversations lists the caller's conversations, most recent message
+// first.
+func (router *APIRouter) ListConversations(w http.ResponseWriter, r *http.Request) {
+	userId, _ := middleware.UserID(r.Context())
+
+	limit, before, ok := pageParams(w, r, defaultMessagesLimit, maxMessagesLimit)
+	if !ok {
+		return
+	}
+	rows, err := router.cfg.Db.ListConversations(
+		r.Context(),
+		database.ListConversationsParams{UserID: userId, Before: before, MaxResults: limit},
+	)
+	if err != nil {
+		handleDatabaseRowError(w, err)
+		return
+	}
+
+	conversations := make([]message.Conversation, 0, len(rows))
+	for _, row := range rows {
+		conversations = append(conversations, message.NewConversationFromRow(row))
+	}
+	respondWithJSON(w, http.StatusOK, conversations)
+}
+
+// CreateConversation starts a conversation with the users in user_ids. There
+// is only one 1:1 conversation between two users, starting it again returns
+// the existing one.
+func (router *APIRouter) CreateConversation(w http.ResponseWriter, r *http.Request) {
+	userId, _ := middleware.UserID(r.Context())
+
+	type reqParams struct {
+		UserIDs []uuid.UUID `json:"user_ids"`
+	}
+	params := reqParams{}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		respondWithDecodeError(w, err)
+		return
+	}
+
+	others, err := message.Members(userId, params.UserIDs)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	n, err := router.cfg.Db.CountUsersByID(r.Context(), others)
+	if err != nil {
+		handleDatabaseRowError(w, err)
+		return
+	}
+	if int(n) != len(others) {
+		respondWithError(w, http.StatusNotFound, "User not found")
+		return
+	}
+	for _, other := range others {
+		blocked, err := router.blocked(r, other)
+		if err != nil {
+			handleDatabaseRowError(w, err)
+			return
+		}
+		if blocked {
+			respondWithError(w, http.StatusForbidden, "You can't message this user")
+			return
+		}
+	}
+
+	directKey := sql.NullString{}
+	if len(others) == 1 {
+		directKey = sql.NullString{String: message.DirectKey(userId, others[0]), Valid: true}
+	}
+	status := http.StatusCreated
+	var dbConversation database.Conversation
+	err = router.cfg.Tx(r.Context(), func(q *database.Queries) error {
+		var err error
+		dbConversation, err = q.CreateConversation(r.Context(), directKey)
+		if errors.Is(err, sql.ErrNoRows) {
+			// the two users already have a conversation
+			status = http.StatusOK
+			dbConversation, err = q.GetConversationByDirectKey(r.Context(), directKey)
+			return err
+		}
+		if err != nil {
+			return err
+		}
+		for _, member := range append([]uuid.UUID{userId}, others...) {
+			err := q.AddConversationMember(
+				r.Context(),
+				database.AddConversationMemberParams{ConversationID: dbConversation.ID, UserID: member},
+			)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		handleDatabaseRowError(w, err)
+		return
+	}
+	members, err := router.cfg.Db.ListConversationMembers(r.Context(), dbConversation.ID)
+	if err != nil {
+		handleDatabaseRowError(w, err)
+		return
+	}
+
+	respondWithJSON(w, status, message.NewConversation(dbConversation, members))
+}
+
+// ListMessages lists a conversation's messages, newest first, leaving out
+// senders the caller blocked or was blocked by.
+func (router *APIRouter) ListMessages(w http.ResponseWriter, r *http.Request) {
+	userId, _ := middleware.UserID(r.Context())
+
+	dbConversation, ok := router.conversation(w, r)
+	if !ok {
+		return
+	}
+	limit, before, ok := pageParams(w, r, defaultMessagesLimit, maxMessagesLimit)
+	if !ok {
+		return
+	}
+	dbMessages, err := router.cfg.Db.ListDirectMessages(r.Context(), database.ListDirectMessagesParams{
+		ConversationID: dbConversation.ID,
+		ViewerID:       userId,
+		Before:         before,
+		MaxResults:     limit,
+	})
+	if err != nil {
+		handleDatabaseRowError(w, err)
+		return
+	}
+
+	messages := make([]message.Message, 0, len(dbMessages))
+	for _, dbMessage := range dbMessages {
+		messages = append(messages, message.NewMessage(dbMessage))
+	}
+	respondWithJSON(w, http.StatusOK, messages)
+}
+
+// SendMessage is delivered to every other member, except those the sender
+// blocked or was blocked by. A 1:1 conversation with a block can't be used.
+func (router *APIRouter) SendMessage(w http.ResponseWriter, r *http.Request) {
+	userId, _ := middleware.UserID(r.Context())
+
+	dbConversation, ok := router.conversation(w, r)
+	if !ok {
+		return
+	}
+	type reqParams struct {
+		Body string `json:"body"`
+	}
+	params := reqParams{}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		respondWithDecodeError(w, err)
+		return
+	}
+	body, err := chirp.ValidateText(params.Body, "Message")
+	if err != nil {
+		respondWithValidationError(w, err)
+		return
+	}
+
+	recipients, err := router.cfg.Db.ListConversationRecipients(
+		r.Context(),
+		database.ListConversationRecipientsParams{ConversationID: dbConversation.ID, SenderID: userId},
+	)
+	if err != nil {
+		handleDatabaseRowError(w, err)
+		return
+	}
+	if dbConversation.DirectKey.Valid && len(recipients) == 0 {
+		respondWithError(w, http.StatusForbidden, "You can't message this user")
+		return
+	}
+
+	var dbMessage database.DirectMessage
+	err = router.cfg.Tx(r.Context(), func(q *database.Queries) error {
+		var err error
+		dbMessage, err = q.CreateDirectMessage(r.Context(), database.CreateDirectMessageParams{
+			ConversationID: dbConversation.ID,
+			SenderID:       userId,
+			Body:           body,
+		})
+		if err != nil {
+			return err
+		}
+		err = q.TouchConversation(r.Context(), database.TouchConversationParams{
+			ID:            dbConversation.ID,
+			LastMessageAt: sql.NullTime{Time: dbMessage.CreatedAt, Valid: true},
+		})
+		if err != nil {
+			return err
+		}
+		return q.MarkConversationRead(
+			r.Context(),
+			database.MarkConversationReadParams{ConversationID: dbConversation.ID, UserID: userId},
+		)
+	})
+	if err != nil {
+		handleDatabaseRowError(w, err)
+		return
+	}
+	msg := message.NewMessage(dbMessage)
+
+	audience := events.Audience{Restricted: true, UserIDs: recipients}
+	if _, err := router.cfg.Events.PublishTo(r.Context(), events.MessageCreated, userId, audience, msg); err != nil {
+		middleware.Log(r.Context()).Error("publish event", "type", events.MessageCreated, "error", err)
+	}
+
+	respondWithJSON(w, http.StatusCreated, msg)
+}
+
+// MarkConversationRead marks every message in the conversation read for the
+// caller.
+func (router *APIRouter) MarkConversationRead(w http.ResponseWriter, r *http.Request) {
+	userId, _ := middleware.UserID(r.Context())
+
+	dbConversation, ok := router.conversation(w, r)
+	if !ok {
+		return
+	}
+	err := router.cfg.Db.MarkConversationRead(
+		r.Context(),
+		database.MarkConversationReadParams{ConversationID: dbConversation.ID, UserID: userId},
+	)
+	if err != nil {
+		handleDatabaseRowError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// conversation loads the conversation in the path, which the caller must be
+// a member of.
+func (router *APIRouter) conversation(w http.ResponseWriter, r *http.Request) (database.Conversation, bool) {
+	userId, _ := middleware.UserID(r.Context())
+
+	conversationUUID, err := uuid.Parse(r.PathValue("conversationID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid conversation id")
+		return database.Conversation{}, false
+	}
+	dbConversation, err := router.cfg.Db.GetConversationForMember(
+		r.Context(),
+		database.GetConversationForMemberParams{ID: conversationUUID, UserID: userId},
+	)
+	if err != nil {
+		handleDatabaseRowError(w, err)
+		return database.Conversation{}, false
+	}
+	return dbConversation, true
+}
+
+// pageParams reads the limit and before query params of paginated listings.
+func pageParams(w http.ResponseWriter, r *http.Request, defaultLimit, maxLimit int) (int32, sql.NullTime, bool) {
+	query := r.URL.Query()
+
+	limit := defaultLimit
+	if value := query.Get("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 || n > maxLimit {
+			respondWithError(w, http.StatusBadRequest, "Invalid limit")
+			return 0, sql.NullTime{}, false
+		}
+		limit = n
+	}
+
+	before := sql.NullTime{}
+	if value := query.Get("before"); value != "" {
+		t, err := time.Parse(time.RFC3339Nano, value)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid before timestamp")
+			return 0, sql.NullTime{}, false
+		}
+		before = sql.NullTime{Time: t, Valid: true}
+	}
+	return int32(limit), before, true
+}
@@ -0,0 +1,486 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"github.com/gskll/chirpy2/internal/audit"
+	"github.com/gskll/chirpy2/internal/chirp"
+	"github.com/gskll/chirpy2/internal/database"
+	"github.com/gskll/chirpy2/internal/events"
+	"github.com/gskll/chirpy2/internal/middleware"
+	"github.com/gskll/chirpy2/internal/moderation"
+	"github.com/gskll/chirpy2/internal/user"
+)
+
+const (
+	queueDefaultLimit = 50
+	queueMaxLimit     = 200
+)
+
+// CreateReport reports a chirp, or a user when no chirp_id is given. Reports
+// on a chirp are always against its author.
+func (router *APIRouter) CreateReport(w http.ResponseWriter, r *http.Request) {
+	reporterId, _ := middleware.UserID(r.Context())
+
+	type reqParams struct {
+		ChirpId  *uuid.UUID `json:"chirp_id"`
+		UserId   *uuid.UUID `json:"user_id"`
+		Category string     `json:"category"`
+		Comment  string     `json:"comment"`
+	}
+	params := reqParams{}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		respondWithDecodeError(w, err)
+		return
+	}
+	if !moderation.ValidCategory(params.Category) {
+		respondWithError(w, http.StatusBadRequest, "Invalid category")
+		return
+	}
+	params.Comment = strings.TrimSpace(params.Comment)
+	if len(params.Comment) > moderation.MaxCommentLength {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("comment is too long, max %d bytes", moderation.MaxCommentLength))
+		return
+	}
+
+	create := database.CreateReportParams{
+		ReporterID: uuid.NullUUID{UUID: reporterId, Valid: true},
+		Category:   params.Category,
+		Comment:    params.Comment,
+	}
+	switch {
+	case params.ChirpId != nil:
+		dbChirp, err := router.cfg.Db.GetChirp(r.Context(), *params.ChirpId)
+		if err == nil && chirp.Hidden(dbChirp) && !router.canSeeHidden(r, dbChirp) {
+			err = sql.ErrNoRows
+		}
+		if err != nil {
+			handleDatabaseRowError(w, err)
+			return
+		}
+		// like GetChirp, chirps the reporter can't read don't exist to them
+		visible, err := router.canSeeChirp(r, dbChirp.ID)
+		if err != nil {
+			handleDatabaseRowError(w, err)
+			return
+		}
+		if !visible {
+			respondWithError(w, http.StatusNotFound, "chirp not found")
+			return
+		}
+		if params.UserId != nil && *params.UserId != dbChirp.UserID {
+			respondWithError(w, http.StatusBadRequest, "user_id isn't the chirp's author")
+			return
+		}
+		create.ChirpID = uuid.NullUUID{UUID: dbChirp.ID, Valid: true}
+		create.ReportedUserID = dbChirp.UserID
+	case params.UserId != nil:
+		if _, err := router.cfg.Db.GetUser(r.Context(), *params.UserId); err != nil {
+			handleDatabaseRowError(w, err)
+			return
+		}
+		create.ReportedUserID = *params.UserId
+	default:
+		respondWithError(w, http.StatusBadRequest, "chirp_id or user_id is required")
+		return
+	}
+	if create.ReportedUserID == reporterId {
+		respondWithError(w, http.StatusBadRequest, "cannot report yourself")
+		return
+	}
+
+	dbReport, err := router.cfg.Db.CreateReport(r.Context(), create)
+	if isUniqueViolation(err) {
+		respondWithError(w, http.StatusConflict, "already reported")
+		return
+	}
+	if err != nil {
+		handleDatabaseRowError(w, err)
+		return
+	}
+	respondWithJSON(w, http.StatusCreated, moderation.NewReport(dbReport))
+}
+
+// ListReports is the moderator queue, oldest first so reports are worked
+// through in the order they came in.
+func (router *APIRouter) ListReports(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	params := database.ListReportsParams{
+		Status:   query.Get("status"),
+		Category: query.Get("category"),
+	}
+	if params.Status == "" {
+		params.Status = moderation.StatusOpen
+	}
+	if !moderation.ValidStatus(params.Status) {
+		respondWithError(w, http.StatusBadRequest, "Invalid status")
+		return
+	}
+	if params.Category != "" && !moderation.ValidCategory(params.Category) && params.Category != moderation.Filter {
+		respondWithError(w, http.StatusBadRequest, "Invalid category")
+		return
+	}
+	if reported := query.Get("reported_user_id"); reported != "" {
+		id, err := uuid.Parse(reported)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid reported_user_id")
+			return
+		}
+		params.ReportedUserID = uuid.NullUUID{UUID: id, Valid: true}
+	}
+	limit, err := queryInt(query.Get("limit"), queueDefaultLimit)
+	if err != nil || limit < 1 || limit > queueMaxLimit {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("limit must be between 1 and %d", queueMaxLimit))
+		return
+	}
+	offset, err := queryInt(query.Get("offset"), 0)
+	if err != nil || offset < 0 {
+		respondWithError(w, http.StatusBadRequest, "Invalid offset")
+		return
+	}
+	params.PageLimit, params.PageOffset = int32(limit), int32(offset)
+
+	rows, err := router.cfg.Db.ListReports(r.Context(), params)
+	if err != nil {
+		handleDatabaseRowError(w, err)
+		return
+	}
+
+	items := make([]moderation.QueueItem, 0, len(rows))
+	for _, row := range rows {
+		items = append(items, moderation.NewQueueItem(row))
+	}
+	respondWithJSON(w, http.StatusOK, items)
+}
+
+func (router *APIRouter) GetReport(w http.ResponseWriter, r *http.Request) {
+	reportUUID, ok := pathReportID(w, r)
+	if !ok {
+		return
+	}
+	dbReport, err := router.cfg.Db.GetReport(r.Context(), reportUUID)
+	if err != nil {
+		handleDatabaseRowError(w, err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, moderation.NewReport(dbReport))
+}
+
+// ResolveReport closes a single report. Hiding a chirp or suspending its
+// author resolves their open reports as actioned already.
+func (router *APIRouter) ResolveReport(w http.ResponseWriter, r *http.Request) {
+	reportUUID, ok := pathReportID(w, r)
+	if !ok {
+		return
+	}
+	actorId, _ := middleware.UserID(r.Context())
+
+	type reqParams struct {
+		Status string `json:"status"`
+		Note   string `json:"note"`
+	}
+	params := reqParams{}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		respondWithDecodeError(w, err)
+		return
+	}
+	if !moderation.ValidResolution(params.Status) {
+		respondWithError(w, http.StatusBadRequest, "status must be actioned or dismissed")
+		return
+	}
+	params.Note = strings.TrimSpace(params.Note)
+
+	var dbReport database.Report
+	err := router.cfg.Tx(r.Context(), func(q *database.Queries) error {
+		var err error
+		dbReport, err = q.GetReport(r.Context(), reportUUID)
+		if err != nil {
+			return err
+		}
+		if dbReport.Status != moderation.StatusOpen {
+			return errReportResolved
+		}
+		dbReport, err = q.ResolveReport(r.Context(), database.ResolveReportParams{
+			Status:         params.Status,
+			ResolvedBy:     uuid.NullUUID{UUID: actorId, Valid: true},
+			ResolutionNote: sql.NullString{String: params.Note, Valid: params.Note != ""},
+			ID:             reportUUID,
+		})
+		if errors.Is(err, sql.ErrNoRows) {
+			return errReportResolved
+		}
+		if err != nil {
+			return err
+		}
+		return audit.Record(r.Context(), q, auditEvent(router.cfg, r, audit.Event{
+			ActorID:      actorId,
+			Action:       audit.ModerationReportResolve,
+			TargetUserID: dbReport.ReportedUserID,
+			Detail:       map[string]any{"report_id": dbReport.ID, "status": params.Status, "note": params.Note},
+		}))
+	})
+	if errors.Is(err, errReportResolved) {
+		respondWithError(w, http.StatusConflict, err.Error())
+		return
+	}
+	if err != nil {
+		handleDatabaseRowError(w, err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, moderation.NewReport(dbReport))
+}
+
+var errReportResolved = errors.New("report already resolved")
+
+// HideChirp removes the chirp from every listing except its author's, who
+// sees it with a moderation notice. Open reports on it are actioned.
+func (router *APIRouter) HideChirp(w http.ResponseWriter, r *http.Request) {
+	chirpUUID, ok := pathChirpID(w, r)
+	if !ok {
+		return
+	}
+	actorId, _ := middleware.UserID(r.Context())
+
+	type reqParams struct {
+		Reason string `json:"reason"`
+	}
+	params := reqParams{}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		respondWithDecodeError(w, err)
+		return
+	}
+	params.Reason = strings.TrimSpace(params.Reason)
+	if params.Reason == "" {
+		respondWithError(w, http.StatusBadRequest, "reason is required")
+		return
+	}
+
+	var dbChirp database.Chirp
+	err := router.cfg.Tx(r.Context(), func(q *database.Queries) error {
+		var err error
+		dbChirp, err = q.GetChirp(r.Context(), chirpUUID)
+		if err != nil {
+			return err
+		}
+		if chirp.Hidden(dbChirp) {
+			return errChirpHidden
+		}
+		dbChirp, err = q.HideChirp(r.Context(), database.HideChirpParams{
+			HiddenReason: sql.NullString{String: params.Reason, Valid: true},
+			ID:           chirpUUID,
+		})
+		if err != nil {
+			return err
+		}
+		resolved, err := q.ResolveChirpReports(r.Context(), database.ResolveChirpReportsParams{
+			ResolvedBy:     uuid.NullUUID{UUID: actorId, Valid: true},
+			ResolutionNote: sql.NullString{String: "chirp hidden: " + params.Reason, Valid: true},
+			ChirpID:        uuid.NullUUID{UUID: chirpUUID, Valid: true},
+		})
+		if err != nil {
+			return err
+		}
+		return audit.Record(r.Context(), q, auditEvent(router.cfg, r, audit.Event{
+			ActorID:      actorId,
+			Action:       audit.ModerationChirpHide,
+			TargetUserID: dbChirp.UserID,
+			Detail:       map[string]any{"chirp_id": chirpUUID, "reason": params.Reason, "resolved_reports": resolved},
+		}))
+	})
+	if errors.Is(err, errChirpHidden) {
+		respondWithError(w, http.StatusConflict, err.Error())
+		return
+	}
+	if err != nil {
+		handleDatabaseRowError(w, err)
+		return
+	}
+
+	// clients drop hidden chirps from their timelines the same way as
+	// deleted ones
+	removed := struct {
+		ID     uuid.UUID `json:"id"`
+		UserId uuid.UUID `json:"user_id"`
+	}{ID: dbChirp.ID, UserId: dbChirp.UserID}
+	if _, err := router.cfg.Events.PublishTo(r.Context(), events.ChirpDeleted, dbChirp.UserID, router.chirpAudience(r.Context(), dbChirp), removed); err != nil {
+		middleware.Log(r.Context()).Error("publish event", "type", events.ChirpDeleted, "error", err)
+	}
+
+	respondWithJSON(w, http.StatusOK, chirp.NewModeratedChirp(dbChirp))
+}
+
+var errChirpHidden = errors.New("chirp already hidden")
+
+func (router *APIRouter) UnhideChirp(w http.ResponseWriter, r *http.Request) {
+	chirpUUID, ok := pathChirpID(w, r)
+	if !ok {
+		return
+	}
+	actorId, _ := middleware.UserID(r.Context())
+
+	var dbChirp database.Chirp
+	err := router.cfg.Tx(r.Context(), func(q *database.Queries) error {
+		var err error
+		dbChirp, err = q.GetChirp(r.Context(), chirpUUID)
+		if err != nil {
+			return err
+		}
+		if !chirp.Hidden(dbChirp) {
+			return errChirpNotHidden
+		}
+		dbChirp, err = q.UnhideChirp(r.Context(), chirpUUID)
+		if err != nil {
+			return err
+		}
+		return audit.Record(r.Context(), q, auditEvent(router.cfg, r, audit.Event{
+			ActorID:      actorId,
+			Action:       audit.ModerationChirpUnhide,
+			TargetUserID: dbChirp.UserID,
+			Detail:       map[string]uuid.UUID{"chirp_id": chirpUUID},
+		}))
+	})
+	if errors.Is(err, errChirpNotHidden) {
+		respondWithError(w, http.StatusConflict, err.Error())
+		return
+	}
+	if err != nil {
+		handleDatabaseRowError(w, err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, chirp.NewModeratedChirp(dbChirp))
+}
+
+var errChirpNotHidden = errors.New("chirp isn't hidden")
+
+// SuspendAuthor suspends a regular user and actions every open report
+// against them. Staff accounts can only be suspended through the admin API.
+func (router *APIRouter) SuspendAuthor(w http.ResponseWriter, r *http.Request) {
+	userUUID, ok := pathUserID(w, r)
+	if !ok {
+		return
+	}
+	actorId, _ := middleware.UserID(r.Context())
+	if userUUID == actorId {
+		respondWithError(w, http.StatusBadRequest, "cannot apply to your own account")
+		return
+	}
+
+	type reqParams struct {
+		Reason string `json:"reason"`
+	}
+	params := reqParams{}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		respondWithDecodeError(w, err)
+		return
+	}
+	params.Reason = strings.TrimSpace(params.Reason)
+	if params.Reason == "" {
+		respondWithError(w, http.StatusBadRequest, "reason is required")
+		return
+	}
+
+	var dbUser database.User
+	err := router.cfg.Tx(r.Context(), func(q *database.Queries) error {
+		var err error
+		dbUser, err = q.GetUser(r.Context(), userUUID)
+		if err != nil {
+			return err
+		}
+		if user.HasRole(dbUser.Role, user.RoleModerator) {
+			return errSuspendStaff
+		}
+		if dbUser.SuspendedAt.Valid {
+			return errAlreadySuspended
+		}
+		dbUser, err = q.SuspendUser(r.Context(), database.SuspendUserParams{
+			SuspendedReason: sql.NullString{String: params.Reason, Valid: true},
+			ID:              userUUID,
+		})
+		if err != nil {
+			return err
+		}
+		revoked, err := q.RevokeUserRefreshTokens(r.Context(), userUUID)
+		if err != nil {
+			return err
+		}
+		resolved, err := q.ResolveUserReports(r.Context(), database.ResolveUserReportsParams{
+			ResolvedBy:     uuid.NullUUID{UUID: actorId, Valid: true},
+			ResolutionNote: sql.NullString{String: "author suspended: " + params.Reason, Valid: true},
+			ReportedUserID: userUUID,
+		})
+		if err != nil {
+			return err
+		}
+		return audit.Record(r.Context(), q, auditEvent(router.cfg, r, audit.Event{
+			ActorID:      actorId,
+			Action:       audit.ModerationUserSuspend,
+			TargetUserID: userUUID,
+			Detail: map[string]any{
+				"reason":           params.Reason,
+				"revoked_tokens":   revoked,
+				"resolved_reports": resolved,
+			},
+		}))
+	})
+	switch {
+	case errors.Is(err, errSuspendStaff):
+		respondWithError(w, http.StatusForbidden, err.Error())
+		return
+	case errors.Is(err, errAlreadySuspended):
+		respondWithError(w, http.StatusConflict, err.Error())
+		return
+	case err != nil:
+		handleDatabaseRowError(w, err)
+		return
+	}
+	closeConnections(r, router.cfg, userUUID, events.UserSuspended)
+	respondWithJSON(w, http.StatusOK, user.NewUser(dbUser))
+}
+
+var (
+	errSuspendStaff     = errors.New("staff accounts can only be suspended by an admin")
+	errAlreadySuspended = errors.New("user already suspended")
+)
+
+// canSeeHidden is chirp.CanSeeHidden for the viewer, the author is let
+// through without loading them.
+func (router *APIRouter) canSeeHidden(r *http.Request, dbChirp database.Chirp) bool {
+	viewerId, ok := middleware.UserID(r.Context())
+	if !ok {
+		return false
+	}
+	if viewerId == dbChirp.UserID {
+		return true
+	}
+	viewer, err := router.cfg.Db.GetUser(r.Context(), viewerId)
+	if err != nil {
+		return false
+	}
+	return chirp.CanSeeHidden(dbChirp, viewer)
+}
+
+func pathChirpID(w http.ResponseWriter, r *http.Request) (uuid.UUID, bool) {
+	chirpUUID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid chirp id")
+		return uuid.Nil, false
+	}
+	return chirpUUID, true
+}
+
+func pathReportID(w http.ResponseWriter, r *http.Request) (uuid.UUID, bool) {
+	reportUUID, err := uuid.Parse(r.PathValue("reportID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid report id")
+		return uuid.Nil, false
+	}
+	return reportUUID, true
+}
@@ -0,0 +1,200 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/gskll/chirpy2/internal/database"
+	"github.com/gskll/chirpy2/internal/events"
+	"github.com/gskll/chirpy2/internal/middleware"
+	"github.com/gskll/chirpy2/internal/notification"
+)
+
+const (
+	defaultNotificationsLimit = 20
+	maxNotificationsLimit     = 100
+)
+
+// notify records an interaction for the recipient's inbox and pushes it to
+// their real-time stream. Muted types, actors blocked either way or muted
+// by the recipient, and self-interactions are skipped. It reports whether a
+// notification was created.
+func (router *APIRouter) notify(ctx context.Context, recipient, actor uuid.UUID, notificationType string, chirpID uuid.NullUUID) bool {
+	if recipient == actor {
+		return false
+	}
+
+	dbNotification, err := router.cfg.Db.CreateNotification(ctx, database.CreateNotificationParams{
+		UserID:  recipient,
+		ActorID: actor,
+		Type:    notificationType,
+		ChirpID: chirpID,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return false
+	}
+	if err != nil {
+		middleware.Log(ctx).Error("create notification", "type", notificationType, "error", err)
+		return false
+	}
+
+	n := notification.NewNotification(dbNotification)
+	if _, err := router.cfg.Events.Publish(ctx, events.NotificationCreated, recipient, n); err != nil {
+		middleware.Log(ctx).Error("publish event", "type", events.NotificationCreated, "error", err)
+	}
+	return true
+}
+
+func (router *APIRouter) GetNotifications(w http.ResponseWriter, r *http.Request) {
+	userId, _ := middleware.UserID(r.Context())
+
+	query := r.URL.Query()
+	params := database.GetNotificationGroupsParams{
+		UserID:     userId,
+		UnreadOnly: query.Get("unread") == "true",
+		MaxResults: defaultNotificationsLimit,
+	}
+
+	if limit := query.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 || n > maxNotificationsLimit {
+			respondWithError(w, http.StatusBadRequest, "Invalid limit")
+			return
+		}
+		params.MaxResults = int32(n)
+	}
+
+	if before := query.Get("before"); before != "" {
+		t, err := time.Parse(time.RFC3339Nano, before)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid before timestamp")
+			return
+		}
+		params.Before = sql.NullTime{Time: t, Valid: true}
+	}
+
+	rows, err := router.cfg.Db.GetNotificationGroups(r.Context(), params)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	groups := make([]notification.Group, 0, len(rows))
+	for _, row := range rows {
+		groups = append(groups, notification.NewGroup(row))
+	}
+
+	respondWithJSON(w, http.StatusOK, groups)
+}
+
+func (router *APIRouter) GetUnreadNotificationCount(w http.ResponseWriter, r *http.Request) {
+	userId, _ := middleware.UserID(r.Context())
+
+	count, err := router.cfg.Db.CountUnreadNotifications(r.Context(), userId)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]int64{"unread": count})
+}
+
+func (router *APIRouter) MarkNotificationRead(w http.ResponseWriter, r *http.Request) {
+	userId, _ := middleware.UserID(r.Context())
+
+	notificationUUID, err := uuid.Parse(r.PathValue("notificationID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid notification id")
+		return
+	}
+
+	_, err = router.cfg.Db.GetNotification(
+		r.Context(),
+		database.GetNotificationParams{ID: notificationUUID, UserID: userId},
+	)
+	if err != nil {
+		handleDatabaseRowError(w, err)
+		return
+	}
+
+	// marks the whole group the notification belongs to
+	err = router.cfg.Db.MarkNotificationGroupRead(r.Context(), notificationUUID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (router *APIRouter) MarkAllNotificationsRead(w http.ResponseWriter, r *http.Request) {
+	userId, _ := middleware.UserID(r.Context())
+
+	err := router.cfg.Db.MarkAllNotificationsRead(r.Context(), userId)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (router *APIRouter) GetNotificationMutes(w http.ResponseWriter, r *http.Request) {
+	userId, _ := middleware.UserID(r.Context())
+
+	mutes, err := router.cfg.Db.GetNotificationMutes(r.Context(), userId)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	muted := make([]string, 0, len(mutes))
+	for _, mute := range mutes {
+		muted = append(muted, mute.Type)
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string][]string{"muted": muted})
+}
+
+func (router *APIRouter) MuteNotificationType(w http.ResponseWriter, r *http.Request) {
+	router.setNotificationMute(w, r, true)
+}
+
+func (router *APIRouter) UnmuteNotificationType(w http.ResponseWriter, r *http.Request) {
+	router.setNotificationMute(w, r, false)
+}
+
+func (router *APIRouter) setNotificationMute(w http.ResponseWriter, r *http.Request, muted bool) {
+	userId, _ := middleware.UserID(r.Context())
+
+	notificationType := r.PathValue("type")
+	if !notification.ValidType(notificationType) {
+		respondWithError(w, http.StatusBadRequest, "Invalid notification type")
+		return
+	}
+
+	var err error
+	if muted {
+		err = router.cfg.Db.MuteNotificationType(
+			r.Context(),
+			database.MuteNotificationTypeParams{UserID: userId, Type: notificationType},
+		)
+	} else {
+		err = router.cfg.Db.UnmuteNotificationType(
+			r.Context(),
+			database.UnmuteNotificationTypeParams{UserID: userId, Type: notificationType},
+		)
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
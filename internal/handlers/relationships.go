@@ -0,0 +1,379 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/gskll/chirpy2/internal/chirp"
+	"github.com/gskll/chirpy2/internal/database"
+	"github.com/gskll/chirpy2/internal/events"
+	"github.com/gskll/chirpy2/internal/middleware"
+	"github.com/gskll/chirpy2/internal/notification"
+)
+
+// relationship is a user the caller blocked, muted or was asked to be
+// followed by.
+type relationship struct {
+	UserID    uuid.UUID `json:"user_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+const (
+	followPending  = "pending"
+	followAccepted = "accepted"
+)
+
+// follow is the caller's follow of another user, a request waiting for
+// approval while Status is pending.
+type follow struct {
+	UserID    uuid.UUID `json:"user_id"`
+	Status    string    `json:"status"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (router *APIRouter) ListBlocks(w http.ResponseWriter, r *http.Request) {
+	userId, _ := middleware.UserID(r.Context())
+
+	blocks, err := router.cfg.Db.ListBlockedUsers(r.Context(), userId)
+	if err != nil {
+		handleDatabaseRowError(w, err)
+		return
+	}
+
+	blocked := make([]relationship, 0, len(blocks))
+	for _, block := range blocks {
+		blocked = append(blocked, relationship{UserID: block.BlockedID, CreatedAt: block.CreatedAt})
+	}
+	respondWithJSON(w, http.StatusOK, blocked)
+}
+
+// BlockUser works both ways: neither user sees the other's chirps, can
+// follow the other or is notified of the other's interactions. Follows
+// between them are removed.
+func (router *APIRouter) BlockUser(w http.ResponseWriter, r *http.Request) {
+	userId, target, ok := router.relationshipTarget(w, r)
+	if !ok {
+		return
+	}
+
+	err := router.cfg.Tx(r.Context(), func(q *database.Queries) error {
+		err := q.BlockUser(
+			r.Context(),
+			database.BlockUserParams{BlockerID: userId, BlockedID: target.ID},
+		)
+		if err != nil {
+			return err
+		}
+		return q.DeleteFollowsBetween(
+			r.Context(),
+			database.DeleteFollowsBetweenParams{UserID: userId, OtherID: target.ID},
+		)
+	})
+	if err != nil {
+		handleDatabaseRowError(w, err)
+		return
+	}
+	router.relationshipsChanged(r.Context(), userId, target.ID)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (router *APIRouter) UnblockUser(w http.ResponseWriter, r *http.Request) {
+	userId, target, ok := router.relationshipTarget(w, r)
+	if !ok {
+		return
+	}
+
+	n, err := router.cfg.Db.UnblockUser(
+		r.Context(),
+		database.UnblockUserParams{BlockerID: userId, BlockedID: target.ID},
+	)
+	if err != nil {
+		handleDatabaseRowError(w, err)
+		return
+	}
+	if n > 0 {
+		router.relationshipsChanged(r.Context(), userId, target.ID)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (router *APIRouter) ListMutes(w http.ResponseWriter, r *http.Request) {
+	userId, _ := middleware.UserID(r.Context())
+
+	mutes, err := router.cfg.Db.ListMutedUsers(r.Context(), userId)
+	if err != nil {
+		handleDatabaseRowError(w, err)
+		return
+	}
+
+	muted := make([]relationship, 0, len(mutes))
+	for _, mute := range mutes {
+		muted = append(muted, relationship{UserID: mute.MutedID, CreatedAt: mute.CreatedAt})
+	}
+	respondWithJSON(w, http.StatusOK, muted)
+}
+
+// MuteUser only affects the caller: the muted user's chirps are left out of
+// their listings and timeline and their interactions don't notify. The muted
+// user isn't told and can still see the caller's chirps.
+func (router *APIRouter) MuteUser(w http.ResponseWriter, r *http.Request) {
+	userId, target, ok := router.relationshipTarget(w, r)
+	if !ok {
+		return
+	}
+
+	err := router.cfg.Db.MuteUser(
+		r.Context(),
+		database.MuteUserParams{UserID: userId, MutedID: target.ID},
+	)
+	if err != nil {
+		handleDatabaseRowError(w, err)
+		return
+	}
+	router.relationshipsChanged(r.Context(), userId)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (router *APIRouter) UnmuteUser(w http.ResponseWriter, r *http.Request) {
+	userId, target, ok := router.relationshipTarget(w, r)
+	if !ok {
+		return
+	}
+
+	n, err := router.cfg.Db.UnmuteUser(
+		r.Context(),
+		database.UnmuteUserParams{UserID: userId, MutedID: target.ID},
+	)
+	if err != nil {
+		handleDatabaseRowError(w, err)
+		return
+	}
+	if n > 0 {
+		router.relationshipsChanged(r.Context(), userId)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// FollowUser follows a public account straight away, a protected one only
+// once its owner approves the request.
+func (router *APIRouter) FollowUser(w http.ResponseWriter, r *http.Request) {
+	userId, target, ok := router.relationshipTarget(w, r)
+	if !ok {
+		return
+	}
+	blocked, err := router.blocked(r, target.ID)
+	if err != nil {
+		handleDatabaseRowError(w, err)
+		return
+	}
+	if blocked {
+		respondWithError(w, http.StatusForbidden, "You can't follow this user")
+		return
+	}
+
+	status := followAccepted
+	if target.IsProtected {
+		status = followPending
+	}
+	created := true
+	dbFollow, err := router.cfg.Db.FollowUser(
+		r.Context(),
+		database.FollowUserParams{FollowerID: userId, FollowedID: target.ID, Status: status},
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		// already following or requested
+		created = false
+		dbFollow, err = router.cfg.Db.GetFollow(
+			r.Context(),
+			database.GetFollowParams{FollowerID: userId, FollowedID: target.ID},
+		)
+	}
+	if err != nil {
+		handleDatabaseRowError(w, err)
+		return
+	}
+
+	if created {
+		if dbFollow.Status == followAccepted {
+			router.notify(r.Context(), target.ID, userId, notification.Follow, uuid.NullUUID{})
+			router.relationshipsChanged(r.Context(), userId)
+		} else {
+			router.notify(r.Context(), target.ID, userId, notification.FollowRequest, uuid.NullUUID{})
+		}
+	}
+
+	respondWithJSON(w, http.StatusOK, follow{
+		UserID:    dbFollow.FollowedID,
+		Status:    dbFollow.Status,
+		CreatedAt: dbFollow.CreatedAt,
+	})
+}
+
+// UnfollowUser also cancels a pending request.
+func (router *APIRouter) UnfollowUser(w http.ResponseWriter, r *http.Request) {
+	userId, target, ok := router.relationshipTarget(w, r)
+	if !ok {
+		return
+	}
+
+	n, err := router.cfg.Db.UnfollowUser(
+		r.Context(),
+		database.UnfollowUserParams{FollowerID: userId, FollowedID: target.ID},
+	)
+	if err != nil {
+		handleDatabaseRowError(w, err)
+		return
+	}
+	if n > 0 {
+		router.relationshipsChanged(r.Context(), userId)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListFollowRequests lists the requests waiting for the caller's approval.
+func (router *APIRouter) ListFollowRequests(w http.ResponseWriter, r *http.Request) {
+	userId, _ := middleware.UserID(r.Context())
+
+	dbRequests, err := router.cfg.Db.ListFollowRequests(r.Context(), userId)
+	if err != nil {
+		handleDatabaseRowError(w, err)
+		return
+	}
+
+	requests := make([]relationship, 0, len(dbRequests))
+	for _, request := range dbRequests {
+		requests = append(requests, relationship{UserID: request.FollowerID, CreatedAt: request.CreatedAt})
+	}
+	respondWithJSON(w, http.StatusOK, requests)
+}
+
+func (router *APIRouter) ApproveFollowRequest(w http.ResponseWriter, r *http.Request) {
+	router.resolveFollowRequest(w, r, true)
+}
+
+func (router *APIRouter) DenyFollowRequest(w http.ResponseWriter, r *http.Request) {
+	router.resolveFollowRequest(w, r, false)
+}
+
+func (router *APIRouter) resolveFollowRequest(w http.ResponseWriter, r *http.Request, approve bool) {
+	userId, _ := middleware.UserID(r.Context())
+	followerUUID, ok := pathUserID(w, r)
+	if !ok {
+		return
+	}
+
+	var (
+		n   int64
+		err error
+	)
+	if approve {
+		n, err = router.cfg.Db.ApproveFollowRequest(
+			r.Context(),
+			database.ApproveFollowRequestParams{FollowerID: followerUUID, FollowedID: userId},
+		)
+	} else {
+		n, err = router.cfg.Db.DenyFollowRequest(
+			r.Context(),
+			database.DenyFollowRequestParams{FollowerID: followerUUID, FollowedID: userId},
+		)
+	}
+	if err != nil {
+		handleDatabaseRowError(w, err)
+		return
+	}
+	if n == 0 {
+		respondWithError(w, http.StatusNotFound, "Follow request not found")
+		return
+	}
+	if approve {
+		router.relationshipsChanged(r.Context(), followerUUID)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// relationshipTarget returns the caller and the user in the path, who must
+// exist and be someone else.
+func (router *APIRouter) relationshipTarget(w http.ResponseWriter, r *http.Request) (uuid.UUID, database.User, bool) {
+	userId, _ := middleware.UserID(r.Context())
+	targetUUID, ok := pathUserID(w, r)
+	if !ok {
+		return uuid.Nil, database.User{}, false
+	}
+	if targetUUID == userId {
+		respondWithError(w, http.StatusBadRequest, "You can't follow, block or mute yourself")
+		return uuid.Nil, database.User{}, false
+	}
+	target, err := router.cfg.Db.GetUser(r.Context(), targetUUID)
+	if err != nil {
+		handleDatabaseRowError(w, err)
+		return uuid.Nil, database.User{}, false
+	}
+	return userId, target, true
+}
+
+// relationshipsChanged tells the users' websocket connections to reload who
+// they follow and who to leave out of their timeline.
+func (router *APIRouter) relationshipsChanged(ctx context.Context, userIDs ...uuid.UUID) {
+	for _, userID := range userIDs {
+		if _, err := router.cfg.Events.Publish(ctx, events.RelationshipsChanged, userID, nil); err != nil {
+			middleware.Log(ctx).Error("publish event", "type", events.RelationshipsChanged, "error", err)
+		}
+	}
+}
+
+// canSeeChirp reports whether the viewer may read the chirp: neither blocked
+// the other, and its visibility and the author's protected account allow it.
+func (router *APIRouter) canSeeChirp(r *http.Request, chirpID uuid.UUID) (bool, error) {
+	// anonymous viewers get uuid.Nil, which follows nobody
+	viewerId, _ := middleware.UserID(r.Context())
+	return router.cfg.Db.ChirpVisibleTo(
+		r.Context(),
+		database.ChirpVisibleToParams{ViewerID: viewerId, ID: chirpID},
+	)
+}
+
+// chirpAudience is who may receive events about the chirp. It falls back to
+// followers only when the author can't be loaded.
+func (router *APIRouter) chirpAudience(ctx context.Context, dbChirp database.Chirp) events.Audience {
+	audience := events.Audience{UserIDs: dbChirp.Mentions}
+	switch dbChirp.Visibility {
+	case chirp.Unlisted, chirp.Mentioned:
+		audience.Restricted = true
+	case chirp.Followers:
+		audience.Restricted = true
+		audience.Followers = true
+	default:
+		author, err := router.cfg.Db.GetUser(ctx, dbChirp.UserID)
+		if err != nil {
+			middleware.Log(ctx).Error("get chirp author", "error", err)
+			author.IsProtected = true
+		}
+		audience.Restricted = author.IsProtected
+		audience.Followers = author.IsProtected
+	}
+	return audience
+}
+
+// blocked reports whether the viewer and userID blocked each other, either
+// way. Anonymous viewers are never blocked.
+func (router *APIRouter) blocked(r *http.Request, userID uuid.UUID) (bool, error) {
+	viewerId, ok := middleware.UserID(r.Context())
+	if !ok || viewerId == userID {
+		return false, nil
+	}
+	return router.cfg.Db.IsBlocked(
+		r.Context(),
+		database.IsBlockedParams{UserID: viewerId, OtherID: userID},
+	)
+}
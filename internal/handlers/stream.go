@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/gskll/chirpy2/internal/events"
+)
+
+const streamKeepAliveInterval = 15 * time.Second
+
+func (router *APIRouter) StreamChirps(w http.ResponseWriter, r *http.Request) {
+	var authorUUID uuid.UUID
+	authorId := r.URL.Query().Get("author_id")
+	if authorId != "" {
+		var err error
+		authorUUID, err = uuid.Parse(authorId)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid author id")
+			return
+		}
+	}
+
+	var lastEventID uint64
+	if header := r.Header.Get("Last-Event-ID"); header != "" {
+		var err error
+		lastEventID, err = strconv.ParseUint(header, 10, 64)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid Last-Event-ID")
+			return
+		}
+	}
+
+	rc := http.NewResponseController(w)
+	// the stream outlives the server's read and write timeouts
+	rc.SetReadDeadline(time.Time{})
+	rc.SetWriteDeadline(time.Time{})
+
+	sub := router.cfg.Events.Subscribe(lastEventID)
+	defer sub.Close()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	send := func(event events.Event) error {
+		if event.Type != events.ChirpCreated && event.Type != events.ChirpDeleted {
+			return nil
+		}
+		if authorUUID != uuid.Nil && event.UserID != authorUUID {
+			return nil
+		}
+		// the stream is anonymous, so only public chirps
+		if !event.Audience.Public() {
+			return nil
+		}
+		_, err := fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, event.Data)
+		return err
+	}
+
+	for _, event := range sub.Missed {
+		if err := send(event); err != nil {
+			return
+		}
+	}
+	if err := rc.Flush(); err != nil {
+		return
+	}
+
+	keepAlive := time.NewTicker(streamKeepAliveInterval)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-router.cfg.Shutdown:
+			// clients reconnect, event ids are per instance so another
+			// instance can't resume from their Last-Event-ID
+			return
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(w, ": keepalive\n\n"); err != nil {
+				return
+			}
+		case event, ok := <-sub.C:
+			if !ok {
+				// dropped for falling behind, the client reconnects with Last-Event-ID
+				return
+			}
+			if err := send(event); err != nil {
+				return
+			}
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
@@ -1,12 +1,19 @@
 package handlers
 
 import (
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"time"
 
+	"github.com/google/uuid"
+
+	"github.com/gskll/chirpy2/internal/audit"
 	"github.com/gskll/chirpy2/internal/auth"
 	"github.com/gskll/chirpy2/internal/database"
+	"github.com/gskll/chirpy2/internal/middleware"
+	"github.com/gskll/chirpy2/internal/notification"
 	"github.com/gskll/chirpy2/internal/user"
 )
 
@@ -26,7 +33,7 @@ func (router *APIRouter) RefreshToken(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	token, err := auth.MakeJWT(dbToken.UserID, router.cfg.JWTSecret, time.Hour)
+	token, err := auth.MakeJWT(dbToken.UserID, router.cfg.JWTSecret, router.cfg.AccessTokenTTL)
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, err.Error())
 		return
@@ -41,7 +48,27 @@ func (router *APIRouter) RevokeRefreshToken(w http.ResponseWriter, r *http.Reque
 		respondWithError(w, http.StatusUnauthorized, err.Error())
 		return
 	}
-	err = router.cfg.Db.RevokeRefreshToken(r.Context(), rToken)
+	dbToken, err := router.cfg.Db.GetRefreshToken(r.Context(), rToken)
+	if errors.Is(err, sql.ErrNoRows) {
+		// unknown tokens are already as good as revoked
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if err != nil {
+		handleDatabaseRowError(w, err)
+		return
+	}
+	err = router.cfg.Tx(r.Context(), func(q *database.Queries) error {
+		if err := q.RevokeRefreshToken(r.Context(), rToken); err != nil {
+			return err
+		}
+		return audit.Record(r.Context(), q, auditEvent(router.cfg, r, audit.Event{
+			ActorID:      dbToken.UserID,
+			Action:       audit.AuthTokenRevoke,
+			TargetUserID: dbToken.UserID,
+			Detail:       map[string]bool{"already_revoked": dbToken.RevokedAt.Valid},
+		}))
+	})
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, err.Error())
 		return
@@ -51,17 +78,7 @@ func (router *APIRouter) RevokeRefreshToken(w http.ResponseWriter, r *http.Reque
 }
 
 func (router *APIRouter) UpdateUserDetails(w http.ResponseWriter, r *http.Request) {
-	token, err := auth.GetBearerToken(r.Header)
-	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, err.Error())
-		return
-	}
-
-	userId, err := auth.ValidateJWT(token, router.cfg.JWTSecret)
-	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, err.Error())
-		return
-	}
+	userId, _ := middleware.UserID(r.Context())
 
 	params := struct {
 		Email    string `json:"email"`
@@ -69,7 +86,7 @@ func (router *APIRouter) UpdateUserDetails(w http.ResponseWriter, r *http.Reques
 	}{}
 
 	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
-		respondWithError(w, http.StatusInternalServerError, err.Error())
+		respondWithDecodeError(w, err)
 		return
 	}
 
@@ -78,10 +95,43 @@ func (router *APIRouter) UpdateUserDetails(w http.ResponseWriter, r *http.Reques
 		respondWithError(w, http.StatusBadRequest, err.Error())
 		return
 	}
-	updatedDbUser, err := router.cfg.Db.UpdateUserEmailAndPassword(
-		r.Context(),
-		database.UpdateUserEmailAndPasswordParams{Email: params.Email, HashedPassword: hashedPassword, ID: userId},
-	)
+	oldDbUser, err := router.cfg.Db.GetUser(r.Context(), userId)
+	if err != nil {
+		handleDatabaseRowError(w, err)
+		return
+	}
+	passwordChanged := auth.CheckPasswordHash(params.Password, oldDbUser.HashedPassword) != nil
+
+	var updatedDbUser database.User
+	err = router.cfg.Tx(r.Context(), func(q *database.Queries) error {
+		var err error
+		updatedDbUser, err = q.UpdateUserEmailAndPassword(
+			r.Context(),
+			database.UpdateUserEmailAndPasswordParams{Email: params.Email, HashedPassword: hashedPassword, ID: userId},
+		)
+		if err != nil {
+			return err
+		}
+		if updatedDbUser.Email != oldDbUser.Email {
+			err = audit.Record(r.Context(), q, auditEvent(router.cfg, r, audit.Event{
+				ActorID:      userId,
+				Action:       audit.UserEmailChange,
+				TargetUserID: userId,
+				Detail:       map[string]string{"old_email": oldDbUser.Email, "new_email": updatedDbUser.Email},
+			}))
+			if err != nil {
+				return err
+			}
+		}
+		if passwordChanged {
+			return audit.Record(r.Context(), q, auditEvent(router.cfg, r, audit.Event{
+				ActorID:      userId,
+				Action:       audit.UserPasswordChange,
+				TargetUserID: userId,
+			}))
+		}
+		return nil
+	})
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, err.Error())
 		return
@@ -92,6 +142,52 @@ func (router *APIRouter) UpdateUserDetails(w http.ResponseWriter, r *http.Reques
 	respondWithJSON(w, http.StatusOK, user)
 }
 
+// SetProtected turns the protected account setting on or off. Turning it
+// off approves every pending follow request.
+func (router *APIRouter) SetProtected(w http.ResponseWriter, r *http.Request) {
+	userId, _ := middleware.UserID(r.Context())
+
+	params := struct {
+		Protected *bool `json:"protected"`
+	}{}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		respondWithDecodeError(w, err)
+		return
+	}
+	if params.Protected == nil {
+		respondWithError(w, http.StatusBadRequest, "protected is required")
+		return
+	}
+
+	var (
+		dbUser   database.User
+		approved []uuid.UUID
+	)
+	err := router.cfg.Tx(r.Context(), func(q *database.Queries) error {
+		var err error
+		dbUser, err = q.SetUserProtected(
+			r.Context(),
+			database.SetUserProtectedParams{IsProtected: *params.Protected, ID: userId},
+		)
+		if err != nil || dbUser.IsProtected {
+			return err
+		}
+		approved, err = q.ApproveAllFollowRequests(r.Context(), userId)
+		return err
+	})
+	if err != nil {
+		handleDatabaseRowError(w, err)
+		return
+	}
+	// the pending requests are now follows, like a follow of a public account
+	for _, followerID := range approved {
+		router.notify(r.Context(), userId, followerID, notification.Follow, uuid.NullUUID{})
+	}
+	router.relationshipsChanged(r.Context(), approved...)
+
+	respondWithJSON(w, http.StatusOK, user.NewUser(dbUser))
+}
+
 func (router *APIRouter) CreateUser(w http.ResponseWriter, r *http.Request) {
 	type reqParams struct {
 		Email    string `json:"email"`
@@ -100,7 +196,7 @@ func (router *APIRouter) CreateUser(w http.ResponseWriter, r *http.Request) {
 
 	params := reqParams{}
 	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
-		respondWithError(w, http.StatusInternalServerError, err.Error())
+		respondWithDecodeError(w, err)
 		return
 	}
 
@@ -131,23 +227,47 @@ func (router *APIRouter) LoginUser(w http.ResponseWriter, r *http.Request) {
 
 	params := reqParams{}
 	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
-		respondWithError(w, http.StatusInternalServerError, err.Error())
+		respondWithDecodeError(w, err)
 		return
 	}
 
 	dbUser, err := router.cfg.Db.GetUserByEmail(r.Context(), params.Email)
 	if err != nil {
+		router.cfg.Metrics.Logins.WithLabelValues("failure").Inc()
+		if errors.Is(err, sql.ErrNoRows) {
+			recordAudit(router.cfg, r, audit.Event{
+				Action: audit.AuthLoginFailed,
+				Detail: map[string]string{"email": params.Email, "reason": "unknown_email"},
+			})
+		}
 		handleDatabaseRowError(w, err)
 		return
 	}
 
 	err = auth.CheckPasswordHash(params.Password, dbUser.HashedPassword)
 	if err != nil {
+		router.cfg.Metrics.Logins.WithLabelValues("failure").Inc()
+		recordAudit(router.cfg, r, audit.Event{
+			Action:       audit.AuthLoginFailed,
+			TargetUserID: dbUser.ID,
+			Detail:       map[string]string{"reason": "bad_password"},
+		})
 		respondWithError(w, http.StatusUnauthorized, "incorrect email or password")
 		return
 	}
 
-	token, err := auth.MakeJWT(dbUser.ID, router.cfg.JWTSecret, time.Hour)
+	if dbUser.SuspendedAt.Valid {
+		router.cfg.Metrics.Logins.WithLabelValues("suspended").Inc()
+		recordAudit(router.cfg, r, audit.Event{
+			Action:       audit.AuthLoginFailed,
+			TargetUserID: dbUser.ID,
+			Detail:       map[string]string{"reason": "suspended"},
+		})
+		respondWithError(w, http.StatusForbidden, "account suspended")
+		return
+	}
+
+	token, err := auth.MakeJWT(dbUser.ID, router.cfg.JWTSecret, router.cfg.AccessTokenTTL)
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, err.Error())
 		return
@@ -158,15 +278,30 @@ func (router *APIRouter) LoginUser(w http.ResponseWriter, r *http.Request) {
 		respondWithError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
-	err = router.cfg.Db.CreateRefreshToken(
-		r.Context(),
-		database.CreateRefreshTokenParams{Token: refreshToken, UserID: dbUser.ID},
-	)
+	err = router.cfg.Tx(r.Context(), func(q *database.Queries) error {
+		err := q.CreateRefreshToken(
+			r.Context(),
+			database.CreateRefreshTokenParams{
+				Token:      refreshToken,
+				UserID:     dbUser.ID,
+				TtlSeconds: router.cfg.RefreshTokenTTL.Seconds(),
+			},
+		)
+		if err != nil {
+			return err
+		}
+		return audit.Record(r.Context(), q, auditEvent(router.cfg, r, audit.Event{
+			ActorID:      dbUser.ID,
+			Action:       audit.AuthLogin,
+			TargetUserID: dbUser.ID,
+		}))
+	})
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
 
+	router.cfg.Metrics.Logins.WithLabelValues("success").Inc()
 	user := user.NewUserWithTokens(dbUser, token, refreshToken)
 
 	respondWithJSON(w, http.StatusOK, user)
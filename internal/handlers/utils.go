@@ -2,12 +2,17 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+
+	"github.com/lib/pq"
+
+	"github.com/gskll/chirpy2/internal/chirp"
 )
 
 func respondWithJSON(w http.ResponseWriter, code int, payload any) {
 	w.Header().Set("Content-Type", "application/json")
-	w.Header().Set("Access-Control-Allow-Origin", "*")
 	w.WriteHeader(code)
 
 	if err := json.NewEncoder(w).Encode(payload); err != nil {
@@ -18,3 +23,35 @@ func respondWithJSON(w http.ResponseWriter, code int, payload any) {
 func respondWithError(w http.ResponseWriter, code int, msg string) {
 	respondWithJSON(w, code, map[string]string{"error": msg})
 }
+
+func respondWithDecodeError(w http.ResponseWriter, err error) {
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		respondWithError(
+			w,
+			http.StatusRequestEntityTooLarge,
+			fmt.Sprintf("request body too large, max %d bytes", maxBytesErr.Limit),
+		)
+		return
+	}
+	respondWithError(w, http.StatusInternalServerError, err.Error())
+}
+
+// respondWithValidationError lists every problem under "errors", "error" is
+// the first one for clients that only show a single message.
+func respondWithValidationError(w http.ResponseWriter, err error) {
+	var errs chirp.ValidationErrors
+	if !errors.As(err, &errs) || len(errs) == 0 {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	respondWithJSON(w, http.StatusBadRequest, struct {
+		Error  string                 `json:"error"`
+		Errors chirp.ValidationErrors `json:"errors"`
+	}{Error: errs[0].Message, Errors: errs})
+}
+
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
@@ -1,12 +1,16 @@
 package handlers
 
 import (
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"net/http"
 
 	"github.com/google/uuid"
 
+	"github.com/gskll/chirpy2/internal/audit"
 	"github.com/gskll/chirpy2/internal/auth"
+	"github.com/gskll/chirpy2/internal/database"
 )
 
 const UserUpgradedEvent = "user.upgraded"
@@ -30,20 +34,42 @@ func (router *APIRouter) UpgradeUser(w http.ResponseWriter, r *http.Request) {
 	}{}
 
 	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
-		respondWithError(w, http.StatusInternalServerError, err.Error())
+		respondWithDecodeError(w, err)
 		return
 	}
 
 	if params.Event != UserUpgradedEvent {
+		router.cfg.Metrics.WebhooksProcessed.WithLabelValues("other", "ignored").Inc()
 		w.WriteHeader(http.StatusNoContent)
 		return
 	}
 
-	err = router.cfg.Db.UpgradeUser(r.Context(), params.Data.UserId)
+	err = router.cfg.Tx(r.Context(), func(q *database.Queries) error {
+		upgraded, err := q.UpgradeUser(r.Context(), params.Data.UserId)
+		if err != nil {
+			return err
+		}
+		if upgraded == 0 {
+			// nothing happened, so nothing to audit
+			return sql.ErrNoRows
+		}
+		return audit.Record(r.Context(), q, auditEvent(router.cfg, r, audit.Event{
+			Action:       audit.UserUpgrade,
+			TargetUserID: params.Data.UserId,
+			Detail:       map[string]string{"source": "polka", "event": params.Event},
+		}))
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		router.cfg.Metrics.WebhooksProcessed.WithLabelValues(params.Event, "not_found").Inc()
+		respondWithError(w, http.StatusNotFound, "user not found")
+		return
+	}
 	if err != nil {
+		router.cfg.Metrics.WebhooksProcessed.WithLabelValues(params.Event, "failure").Inc()
 		handleDatabaseRowError(w, err)
 		return
 	}
+	router.cfg.Metrics.WebhooksProcessed.WithLabelValues(params.Event, "success").Inc()
 
 	w.WriteHeader(http.StatusNoContent)
 }
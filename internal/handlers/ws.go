@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+
+	"github.com/gskll/chirpy2/internal/auth"
+	"github.com/gskll/chirpy2/internal/config"
+	"github.com/gskll/chirpy2/internal/events"
+	"github.com/gskll/chirpy2/internal/middleware"
+	"github.com/gskll/chirpy2/internal/realtime"
+)
+
+var upgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+	// checked by ServeWebSocket against the CORS origins
+	CheckOrigin: func(r *http.Request) bool { return true },
+}
+
+func (router *APIRouter) ServeWebSocket(w http.ResponseWriter, r *http.Request) {
+	if !router.allowedOrigin(r) {
+		respondWithError(w, http.StatusForbidden, "origin not allowed")
+		return
+	}
+
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		// browsers cannot set headers on websocket requests
+		token = r.URL.Query().Get("access_token")
+		if token == "" {
+			respondWithError(w, http.StatusUnauthorized, err.Error())
+			return
+		}
+	}
+
+	userId, expiresAt, err := router.validateAccessToken(token)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+	dbUser, err := router.cfg.Db.GetUser(r.Context(), userId)
+	if err != nil {
+		handleDatabaseRowError(w, err)
+		return
+	}
+	if dbUser.SuspendedAt.Valid {
+		respondWithError(w, http.StatusForbidden, "account suspended")
+		return
+	}
+
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		middleware.Log(r.Context()).Warn("websocket upgrade", "error", err)
+		return
+	}
+
+	// reloaded for as long as the connection lasts, each load gets its own
+	// deadline
+	relations := func() (realtime.Relations, error) {
+		ctx, cancel := context.WithTimeout(r.Context(), router.cfg.RequestTimeout)
+		defer cancel()
+		hidden, err := router.cfg.Db.ListHiddenUsers(ctx, userId)
+		if err != nil {
+			return realtime.Relations{}, err
+		}
+		following, err := router.cfg.Db.ListFollowedUsers(ctx, userId)
+		if err != nil {
+			return realtime.Relations{}, err
+		}
+		return realtime.Relations{Hidden: hidden, Following: following}, nil
+	}
+	client := realtime.NewClient(conn, middleware.Log(r.Context()), router.cfg.Events, router.validateAccessToken, relations, userId, expiresAt)
+	client.Serve(router.cfg.Shutdown)
+}
+
+// closeConnections ends a suspended or deleted user's websocket connections,
+// they would otherwise last until the token expires. eventType is
+// events.UserSuspended or events.UserDeleted.
+func closeConnections(r *http.Request, cfg *config.ApiConfig, userID uuid.UUID, eventType string) {
+	if _, err := cfg.Events.PublishTo(r.Context(), eventType, userID, events.Audience{Restricted: true}, nil); err != nil {
+		middleware.Log(r.Context()).Error("publish event", "type", eventType, "error", err)
+	}
+}
+
+func (router *APIRouter) validateAccessToken(token string) (uuid.UUID, time.Time, error) {
+	userId, claims, err := auth.ParseJWT(token, router.cfg.JWTSecret)
+	if err != nil {
+		return uuid.UUID{}, time.Time{}, err
+	}
+	if claims.ExpiresAt == nil {
+		return uuid.UUID{}, time.Time{}, errors.New("token has no expiry")
+	}
+	return userId, claims.ExpiresAt.Time, nil
+}
+
+// allowedOrigin applies the CORS origins to websockets, which browsers open
+// without a preflight. Requests without an Origin don't come from a browser.
+func (router *APIRouter) allowedOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" || slices.Contains(router.cfg.AllowedOrigins, "*") || slices.Contains(router.cfg.AllowedOrigins, origin) {
+		return true
+	}
+	u, err := url.Parse(origin)
+	return err == nil && u.Host == r.Host
+}
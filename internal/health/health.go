@@ -0,0 +1,121 @@
+package health
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	StatusOK          = "ok"
+	StatusUnavailable = "unavailable"
+	StatusDraining    = "draining"
+)
+
+// Check reports whether a dependency is usable, it must respect ctx.
+type Check func(ctx context.Context) error
+
+type Result struct {
+	Status    string  `json:"status"`
+	LatencyMs float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+}
+
+type Report struct {
+	Status string            `json:"status"`
+	Checks map[string]Result `json:"checks"`
+}
+
+// Checker runs the readiness checks. Each check gets its own timeout and
+// they run concurrently, so one slow dependency can't hide the others.
+type Checker struct {
+	timeout  time.Duration
+	names    []string
+	checks   []Check
+	draining atomic.Bool
+}
+
+func NewChecker(timeout time.Duration) *Checker {
+	return &Checker{timeout: timeout}
+}
+
+func (c *Checker) Add(name string, check Check) {
+	c.names = append(c.names, name)
+	c.checks = append(c.checks, check)
+}
+
+// Drain marks the instance as not ready so load balancers stop routing to it
+// before the listener closes.
+func (c *Checker) Drain() {
+	c.draining.Store(true)
+}
+
+func (c *Checker) Run(ctx context.Context) Report {
+	results := make([]Result, len(c.checks))
+
+	var wg sync.WaitGroup
+	for i, check := range c.checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = c.run(ctx, check)
+		}()
+	}
+	wg.Wait()
+
+	report := Report{Status: StatusOK, Checks: make(map[string]Result, len(results))}
+	for i, result := range results {
+		report.Checks[c.names[i]] = result
+		if result.Status != StatusOK {
+			report.Status = StatusUnavailable
+		}
+	}
+	if c.draining.Load() {
+		report.Status = StatusDraining
+	}
+	return report
+}
+
+func (c *Checker) run(ctx context.Context, check Check) Result {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := check(ctx)
+	result := Result{
+		Status:    StatusOK,
+		LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+	}
+	if err != nil {
+		result.Status = StatusUnavailable
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// Ping checks the database accepts connections.
+func Ping(db *sql.DB) Check {
+	return db.PingContext
+}
+
+// SchemaVersion checks the goose migrations have been applied up to want. A
+// newer schema passes, during a rolling deploy the old instances keep serving
+// after the new ones migrate.
+func SchemaVersion(db *sql.DB, want int64) Check {
+	return func(ctx context.Context) error {
+		var got int64
+		err := db.QueryRowContext(ctx,
+			"SELECT COALESCE(MAX(version_id), 0) FROM goose_db_version WHERE is_applied",
+		).Scan(&got)
+		if err != nil {
+			return err
+		}
+		if got < want {
+			return fmt.Errorf("schema at version %d, want at least %d", got, want)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,57 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCheckerRun(t *testing.T) {
+	ok := func(ctx context.Context) error { return nil }
+	failing := func(ctx context.Context) error { return errors.New("connection refused") }
+	slow := func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+
+	tests := []struct {
+		name       string
+		checks     map[string]Check
+		drain      bool
+		wantStatus string
+		wantFailed []string
+	}{
+		{"All passing", map[string]Check{"database": ok, "migrations": ok}, false, StatusOK, nil},
+		{"One failing", map[string]Check{"database": failing, "migrations": ok}, false, StatusUnavailable, []string{"database"}},
+		{"Timed out", map[string]Check{"database": slow}, false, StatusUnavailable, []string{"database"}},
+		{"Draining", map[string]Check{"database": ok}, true, StatusDraining, nil},
+		{"No checks", nil, false, StatusOK, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewChecker(10 * time.Millisecond)
+			for name, check := range tt.checks {
+				c.Add(name, check)
+			}
+			if tt.drain {
+				c.Drain()
+			}
+
+			report := c.Run(context.Background())
+			if report.Status != tt.wantStatus {
+				t.Errorf("Expected status %q, got %q", tt.wantStatus, report.Status)
+			}
+			if len(report.Checks) != len(tt.checks) {
+				t.Errorf("Expected %d checks, got %d", len(tt.checks), len(report.Checks))
+			}
+			for _, name := range tt.wantFailed {
+				result := report.Checks[name]
+				if result.Status != StatusUnavailable || result.Error == "" {
+					t.Errorf("Expected %s to fail with an error, got %+v", name, result)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,118 @@
+package media
+
+import (
+	"image"
+	"math"
+	"strings"
+)
+
+const (
+	blurhashXComponents = 4
+	blurhashYComponents = 3
+)
+
+const base83Chars = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz#$%*+,-.:;=?@[]^_{|}~"
+
+// Blurhash encodes img as a blurhash, https://blurha.sh, a short string
+// clients decode into a blurred placeholder. It's slow on large images, pass
+// a thumbnail.
+func Blurhash(img image.Image) string {
+	return blurhash(img, blurhashXComponents, blurhashYComponents)
+}
+
+func blurhash(img image.Image, xComponents, yComponents int) string {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+
+	// the image in linear RGB, the cosine transform is done on light levels
+	linear := make([][3]float64, w*h)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			r, g, bl, _ := img.At(b.Min.X+x, b.Min.Y+y).RGBA()
+			linear[y*w+x] = [3]float64{
+				srgbToLinear(int(r >> 8)),
+				srgbToLinear(int(g >> 8)),
+				srgbToLinear(int(bl >> 8)),
+			}
+		}
+	}
+
+	factors := make([][3]float64, 0, xComponents*yComponents)
+	for j := 0; j < yComponents; j++ {
+		for i := 0; i < xComponents; i++ {
+			normalisation := 2.0
+			if i == 0 && j == 0 {
+				normalisation = 1
+			}
+			var f [3]float64
+			for y := 0; y < h; y++ {
+				for x := 0; x < w; x++ {
+					basis := math.Cos(math.Pi*float64(i*x)/float64(w)) * math.Cos(math.Pi*float64(j*y)/float64(h))
+					pixel := linear[y*w+x]
+					f[0] += basis * pixel[0]
+					f[1] += basis * pixel[1]
+					f[2] += basis * pixel[2]
+				}
+			}
+			scale := normalisation / float64(w*h)
+			factors = append(factors, [3]float64{f[0] * scale, f[1] * scale, f[2] * scale})
+		}
+	}
+
+	var hash strings.Builder
+	encode83(&hash, (xComponents-1)+(yComponents-1)*9, 1)
+
+	dc, ac := factors[0], factors[1:]
+	maxValue := 1.0
+	if len(ac) > 0 {
+		actualMax := 0.0
+		for _, f := range ac {
+			actualMax = max(actualMax, math.Abs(f[0]), math.Abs(f[1]), math.Abs(f[2]))
+		}
+		quantisedMax := clamp(int(math.Floor(actualMax*166-0.5)), 0, 82)
+		maxValue = float64(quantisedMax+1) / 166
+		encode83(&hash, quantisedMax, 1)
+	} else {
+		encode83(&hash, 0, 1)
+	}
+
+	encode83(&hash, linearToSRGB(dc[0])<<16+linearToSRGB(dc[1])<<8+linearToSRGB(dc[2]), 4)
+	for _, f := range ac {
+		quant := func(v float64) int {
+			return clamp(int(math.Floor(signPow(v/maxValue, 0.5)*9+9.5)), 0, 18)
+		}
+		encode83(&hash, quant(f[0])*19*19+quant(f[1])*19+quant(f[2]), 2)
+	}
+	return hash.String()
+}
+
+func encode83(sb *strings.Builder, value, length int) {
+	for i := 1; i <= length; i++ {
+		digit := value / int(math.Pow(83, float64(length-i))) % 83
+		sb.WriteByte(base83Chars[digit])
+	}
+}
+
+func srgbToLinear(v int) float64 {
+	x := float64(v) / 255
+	if x <= 0.04045 {
+		return x / 12.92
+	}
+	return math.Pow((x+0.055)/1.055, 2.4)
+}
+
+func linearToSRGB(v float64) int {
+	v = math.Max(0, math.Min(1, v))
+	if v <= 0.0031308 {
+		return int(v*12.92*255 + 0.5)
+	}
+	return int((1.055*math.Pow(v, 1/2.4)-0.055)*255 + 0.5)
+}
+
+func signPow(v, exp float64) float64 {
+	return math.Copysign(math.Pow(math.Abs(v), exp), v)
+}
+
+func clamp(v, lo, hi int) int {
+	return max(lo, min(hi, v))
+}
@@ -0,0 +1,36 @@
+package media
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/gskll/chirpy2/internal/database"
+	"github.com/gskll/chirpy2/internal/storage"
+)
+
+// RemoveUnattached deletes uploads that weren't attached to a chirp within
+// maxAge, with their blobs, every interval until ctx is done.
+func RemoveUnattached(ctx context.Context, db *database.Queries, store storage.Storage, interval, maxAge time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			keys, err := db.DeleteUnattachedMedia(ctx, maxAge.Seconds())
+			if err != nil {
+				if ctx.Err() == nil {
+					slog.Error("delete unattached media", "error", err)
+				}
+				continue
+			}
+			// the rows are gone, a blob that fails to delete only takes up space
+			if err := storage.DeleteAll(ctx, store, keys...); err != nil && ctx.Err() == nil {
+				slog.Error("delete unattached media blobs", "error", err)
+			}
+		}
+	}
+}
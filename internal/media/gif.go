@@ -0,0 +1,72 @@
+package media
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+var errTruncatedGIF = errors.New("truncated GIF")
+
+// gifFrames walks the block structure of a GIF without decoding it, counting
+// its frames and their total pixels, which is what decoding every frame
+// allocates.
+func gifFrames(data []byte) (frames int, pixels int64, err error) {
+	// header and logical screen descriptor
+	if len(data) < 13 {
+		return 0, 0, errTruncatedGIF
+	}
+	i := 13
+	if flags := data[10]; flags&0x80 != 0 {
+		i += 3 << ((flags & 0x07) + 1)
+	}
+
+	for {
+		if i >= len(data) {
+			return 0, 0, errTruncatedGIF
+		}
+		switch data[i] {
+		case 0x21: // extension: label, then data sub-blocks
+			if i+2 > len(data) {
+				return 0, 0, errTruncatedGIF
+			}
+			i, err = skipSubBlocks(data, i+2)
+		case 0x2c: // image descriptor, optional color table, LZW code size, data sub-blocks
+			if i+10 > len(data) {
+				return 0, 0, errTruncatedGIF
+			}
+			w := int64(binary.LittleEndian.Uint16(data[i+5:]))
+			h := int64(binary.LittleEndian.Uint16(data[i+7:]))
+			frames++
+			pixels += w * h
+			flags := data[i+9]
+			i += 10
+			if flags&0x80 != 0 {
+				i += 3 << ((flags & 0x07) + 1)
+			}
+			i, err = skipSubBlocks(data, i+1)
+		case 0x3b: // trailer
+			return frames, pixels, nil
+		default:
+			return 0, 0, errors.New("invalid GIF block")
+		}
+		if err != nil {
+			return 0, 0, err
+		}
+	}
+}
+
+// skipSubBlocks returns the offset after the sub-blocks starting at i, each a
+// size byte followed by that many bytes, ended by a zero size.
+func skipSubBlocks(data []byte, i int) (int, error) {
+	for {
+		if i >= len(data) {
+			return 0, errTruncatedGIF
+		}
+		size := int(data[i])
+		i++
+		if size == 0 {
+			return i, nil
+		}
+		i += size
+	}
+}
@@ -0,0 +1,246 @@
+package media
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+
+	"golang.org/x/image/webp"
+)
+
+const (
+	// MaxPixels limits decoded images, a small file can otherwise expand to
+	// gigabytes in memory.
+	MaxPixels = 40_000_000
+	// MaxFrames limits animated GIFs.
+	MaxFrames = 300
+	// MaxGIFPixels limits the total pixels of an animation's frames. Frames
+	// take a byte per pixel, the same memory as a MaxPixels RGBA image.
+	MaxGIFPixels = 4 * MaxPixels
+	// MaxDecodes is how many images are decoded at once, each can take
+	// hundreds of MB.
+	MaxDecodes = 4
+
+	jpegQuality = 85
+)
+
+var (
+	ErrUnsupportedType = errors.New("unsupported image type, must be PNG, JPEG, GIF or WebP")
+	ErrTooManyPixels   = fmt.Errorf("image is too large, max %d pixels", MaxPixels)
+	ErrTooManyFrames   = fmt.Errorf("animation has too many frames, max %d", MaxFrames)
+	ErrAnimationSize   = fmt.Errorf("animation is too large, max %d pixels over all frames", MaxGIFPixels)
+)
+
+var decodeSlots = make(chan struct{}, MaxDecodes)
+
+// acquireDecode waits for one of MaxDecodes slots, call release when done.
+func acquireDecode(ctx context.Context) (release func(), err error) {
+	select {
+	case decodeSlots <- struct{}{}:
+		return func() { <-decodeSlots }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Image is an uploaded image after re-encoding.
+type Image struct {
+	Data        []byte
+	ContentType string
+	Width       int
+	Height      int
+}
+
+// Ext is the file extension for the image's content type.
+func (img Image) Ext() string {
+	switch img.ContentType {
+	case "image/jpeg":
+		return ".jpg"
+	case "image/gif":
+		return ".gif"
+	}
+	return ".png"
+}
+
+// Process sniffs the type of data, ignoring whatever the client claimed, and
+// re-encodes it. Re-encoding drops EXIF and every other metadata block, after
+// applying the EXIF orientation so photos stay the right way up, and anything
+// hidden after the image data. WebP is re-encoded as PNG. It waits for a
+// decode slot, the error is ctx's when ctx is done first.
+func Process(ctx context.Context, data []byte) (Image, error) {
+	contentType := http.DetectContentType(data)
+	switch contentType {
+	case "image/png", "image/jpeg", "image/gif", "image/webp":
+	default:
+		return Image{}, ErrUnsupportedType
+	}
+
+	var cfg image.Config
+	var err error
+	switch contentType {
+	case "image/png":
+		cfg, err = png.DecodeConfig(bytes.NewReader(data))
+	case "image/jpeg":
+		cfg, err = jpeg.DecodeConfig(bytes.NewReader(data))
+	case "image/gif":
+		cfg, err = gif.DecodeConfig(bytes.NewReader(data))
+	case "image/webp":
+		cfg, err = webp.DecodeConfig(bytes.NewReader(data))
+	}
+	if err != nil {
+		return Image{}, fmt.Errorf("invalid image: %w", err)
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 || cfg.Width*cfg.Height > MaxPixels {
+		return Image{}, ErrTooManyPixels
+	}
+
+	release, err := acquireDecode(ctx)
+	if err != nil {
+		return Image{}, err
+	}
+	defer release()
+
+	if contentType == "image/gif" {
+		return processGIF(data)
+	}
+
+	var img image.Image
+	switch contentType {
+	case "image/png":
+		img, err = png.Decode(bytes.NewReader(data))
+	case "image/jpeg":
+		img, err = jpeg.Decode(bytes.NewReader(data))
+		if err == nil {
+			img = orient(img, jpegOrientation(data))
+		}
+	case "image/webp":
+		img, err = webp.Decode(bytes.NewReader(data))
+	}
+	if err != nil {
+		return Image{}, fmt.Errorf("invalid image: %w", err)
+	}
+
+	if contentType != "image/jpeg" {
+		contentType = "image/png"
+	}
+	return encode(img, contentType)
+}
+
+// encode stores img as a JPEG or a PNG.
+func encode(img image.Image, contentType string) (Image, error) {
+	var buf bytes.Buffer
+	var err error
+	if contentType == "image/jpeg" {
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: jpegQuality})
+	} else {
+		err = png.Encode(&buf, img)
+	}
+	if err != nil {
+		return Image{}, err
+	}
+	return Image{
+		Data:        buf.Bytes(),
+		ContentType: contentType,
+		Width:       img.Bounds().Dx(),
+		Height:      img.Bounds().Dy(),
+	}, nil
+}
+
+// processGIF keeps every frame of an animation.
+func processGIF(data []byte) (Image, error) {
+	// decoding allocates every frame, count them first
+	frames, pixels, err := gifFrames(data)
+	if err != nil {
+		return Image{}, fmt.Errorf("invalid image: %w", err)
+	}
+	if frames > MaxFrames {
+		return Image{}, ErrTooManyFrames
+	}
+	if pixels > MaxGIFPixels {
+		return Image{}, ErrAnimationSize
+	}
+
+	anim, err := gif.DecodeAll(bytes.NewReader(data))
+	if err != nil {
+		return Image{}, fmt.Errorf("invalid image: %w", err)
+	}
+
+	var buf bytes.Buffer
+	if err := gif.EncodeAll(&buf, anim); err != nil {
+		return Image{}, err
+	}
+	return Image{
+		Data:        buf.Bytes(),
+		ContentType: "image/gif",
+		Width:       anim.Config.Width,
+		Height:      anim.Config.Height,
+	}, nil
+}
+
+// orient applies an EXIF orientation, 1 to 8, to img.
+func orient(img image.Image, orientation int) image.Image {
+	if orientation < 2 || orientation > 8 {
+		return img
+	}
+
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	// orientations 5 to 8 swap width and height
+	dw, dh := w, h
+	if orientation >= 5 {
+		dw, dh = h, w
+	}
+	// JPEGs decode to YCbCr or Gray, read those directly rather than
+	// converting a whole copy first
+	var at func(x, y int) color.RGBA
+	switch src := img.(type) {
+	case *image.YCbCr:
+		at = func(x, y int) color.RGBA {
+			c := src.YCbCrAt(b.Min.X+x, b.Min.Y+y)
+			r, g, bl := color.YCbCrToRGB(c.Y, c.Cb, c.Cr)
+			return color.RGBA{r, g, bl, 0xff}
+		}
+	case *image.Gray:
+		at = func(x, y int) color.RGBA {
+			v := src.GrayAt(b.Min.X+x, b.Min.Y+y).Y
+			return color.RGBA{v, v, v, 0xff}
+		}
+	default:
+		rgba := image.NewRGBA(image.Rect(0, 0, w, h))
+		draw.Draw(rgba, rgba.Bounds(), img, b.Min, draw.Src)
+		at = rgba.RGBAAt
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var dx, dy int
+			switch orientation {
+			case 2: // mirrored
+				dx, dy = w-1-x, y
+			case 3: // rotated 180
+				dx, dy = w-1-x, h-1-y
+			case 4: // mirrored vertically
+				dx, dy = x, h-1-y
+			case 5: // transposed
+				dx, dy = y, x
+			case 6: // rotated 90 clockwise
+				dx, dy = h-1-y, x
+			case 7: // transversed
+				dx, dy = h-1-y, w-1-x
+			case 8: // rotated 90 counter-clockwise
+				dx, dy = y, w-1-x
+			}
+			dst.SetRGBA(dx, dy, at(x, y))
+		}
+	}
+	return dst
+}
@@ -0,0 +1,287 @@
+package media
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+// a 1x1 lossless WebP
+const webpPixel = "UklGRhoAAABXRUJQVlA4TA0AAAAvAAAAEAcQERGIiP4HAA=="
+
+func testImage(w, h int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, color.RGBA{uint8(x * 40), uint8(y * 40), 200, 255})
+		}
+	}
+	return img
+}
+
+func encodePNG(t *testing.T, img image.Image) []byte {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatalf("png.Encode failed: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func encodeJPEG(t *testing.T, img image.Image) []byte {
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, nil); err != nil {
+		t.Fatalf("jpeg.Encode failed: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func encodeGIF(t *testing.T, anim *gif.GIF) []byte {
+	var buf bytes.Buffer
+	if err := gif.EncodeAll(&buf, anim); err != nil {
+		t.Fatalf("gif.EncodeAll failed: %v", err)
+	}
+	return buf.Bytes()
+}
+
+// withExif inserts an EXIF segment holding orientation and a GPS-like marker
+// string right after the JPEG's start of image.
+func withExif(jpegData []byte, order binary.ByteOrder, orientation uint16) []byte {
+	tiff := make([]byte, 8+2+12+4)
+	if order == binary.LittleEndian {
+		copy(tiff, "II")
+	} else {
+		copy(tiff, "MM")
+	}
+	order.PutUint16(tiff[2:], 42)
+	order.PutUint32(tiff[4:], 8)
+	order.PutUint16(tiff[8:], 1)
+	order.PutUint16(tiff[10:], exifOrientationTag)
+	order.PutUint16(tiff[12:], 3)
+	order.PutUint32(tiff[14:], 1)
+	order.PutUint16(tiff[18:], orientation)
+	tiff = append(tiff, "GPS 51.5N 0.1W"...)
+
+	payload := append([]byte("Exif\x00\x00"), tiff...)
+	segment := []byte{0xff, 0xe1, 0, 0}
+	binary.BigEndian.PutUint16(segment[2:], uint16(len(payload)+2))
+	segment = append(segment, payload...)
+
+	out := append([]byte{}, jpegData[:2]...)
+	out = append(out, segment...)
+	return append(out, jpegData[2:]...)
+}
+
+func TestProcess(t *testing.T) {
+	webpData, _ := base64.StdEncoding.DecodeString(webpPixel)
+	paletted := image.NewPaletted(image.Rect(0, 0, 4, 3), []color.Color{color.Black, color.White})
+	animation := &gif.GIF{Image: []*image.Paletted{paletted, paletted}, Delay: []int{10, 10}}
+	huge := &gif.GIF{
+		Image:  []*image.Paletted{image.NewPaletted(image.Rect(0, 0, 1, 1), []color.Color{color.Black})},
+		Delay:  []int{0},
+		Config: image.Config{Width: 10000, Height: 10000, ColorModel: color.Palette{color.Black}},
+	}
+
+	tests := []struct {
+		name        string
+		data        []byte
+		contentType string
+		width       int
+		height      int
+		err         error
+	}{
+		{"PNG", encodePNG(t, testImage(5, 3)), "image/png", 5, 3, nil},
+		{"JPEG", encodeJPEG(t, testImage(5, 3)), "image/jpeg", 5, 3, nil},
+		{"JPEG rotated by EXIF", withExif(encodeJPEG(t, testImage(5, 3)), binary.BigEndian, 6), "image/jpeg", 3, 5, nil},
+		{"JPEG mirrored by EXIF", withExif(encodeJPEG(t, testImage(5, 3)), binary.LittleEndian, 2), "image/jpeg", 5, 3, nil},
+		{"Animated GIF", encodeGIF(t, animation), "image/gif", 4, 3, nil},
+		{"WebP", webpData, "image/png", 1, 1, nil},
+		{"Trailing data dropped", append(encodePNG(t, testImage(2, 2)), "<script>"...), "image/png", 2, 2, nil},
+		{"Text", []byte("hello"), "", 0, 0, ErrUnsupportedType},
+		{"SVG", []byte(`<svg xmlns="http://www.w3.org/2000/svg"></svg>`), "", 0, 0, ErrUnsupportedType},
+		{"Too many pixels", encodeGIF(t, huge), "", 0, 0, ErrTooManyPixels},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			img, err := Process(context.Background(), tt.data)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("Expected error %v, got %v", tt.err, err)
+			}
+			if err != nil {
+				return
+			}
+			if img.ContentType != tt.contentType {
+				t.Errorf("Expected %s, got %s", tt.contentType, img.ContentType)
+			}
+			if img.Width != tt.width || img.Height != tt.height {
+				t.Errorf("Expected %dx%d, got %dx%d", tt.width, tt.height, img.Width, img.Height)
+			}
+			if bytes.Contains(img.Data, []byte("Exif")) || bytes.Contains(img.Data, []byte("GPS")) {
+				t.Errorf("Expected metadata to be stripped")
+			}
+			if bytes.Contains(img.Data, []byte("<script>")) {
+				t.Errorf("Expected trailing data to be dropped")
+			}
+		})
+	}
+}
+
+func TestProcessGIFFrames(t *testing.T) {
+	paletted := image.NewPaletted(image.Rect(0, 0, 2, 2), []color.Color{color.Black, color.White})
+	data := encodeGIF(t, &gif.GIF{Image: []*image.Paletted{paletted, paletted, paletted}, Delay: []int{5, 5, 5}})
+
+	img, err := Process(context.Background(), data)
+	if err != nil {
+		t.Fatalf("Process failed: %v", err)
+	}
+	anim, err := gif.DecodeAll(bytes.NewReader(img.Data))
+	if err != nil {
+		t.Fatalf("gif.DecodeAll failed: %v", err)
+	}
+	if len(anim.Image) != 3 {
+		t.Errorf("Expected 3 frames, got %d", len(anim.Image))
+	}
+}
+
+func TestOrient(t *testing.T) {
+	red := color.RGBA{255, 0, 0, 255}
+	blue := color.RGBA{0, 0, 255, 255}
+	src := image.NewRGBA(image.Rect(0, 0, 2, 1))
+	src.SetRGBA(0, 0, red)
+	src.SetRGBA(1, 0, blue)
+
+	tests := []struct {
+		name        string
+		orientation int
+		want        [][]color.RGBA
+	}{
+		{"Normal", 1, [][]color.RGBA{{red, blue}}},
+		{"Mirrored", 2, [][]color.RGBA{{blue, red}}},
+		{"Rotated 180", 3, [][]color.RGBA{{blue, red}}},
+		{"Rotated 90 clockwise", 6, [][]color.RGBA{{red}, {blue}}},
+		{"Rotated 90 counter-clockwise", 8, [][]color.RGBA{{blue}, {red}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := orient(src, tt.orientation)
+			if got.Bounds().Dx() != len(tt.want[0]) || got.Bounds().Dy() != len(tt.want) {
+				t.Fatalf("Expected %dx%d, got %v", len(tt.want[0]), len(tt.want), got.Bounds())
+			}
+			for y, row := range tt.want {
+				for x, want := range row {
+					if c := color.RGBAModel.Convert(got.At(x, y)); c != want {
+						t.Errorf("Expected %v at (%d, %d), got %v", want, x, y, c)
+					}
+				}
+			}
+		})
+	}
+}
+
+// rawGIF builds a GIF of blank w x h frames without encoding any
+// pixels, the limits are checked before decoding.
+func rawGIF(w, h uint16, frames int) []byte {
+	le := func(v uint16) []byte { return []byte{byte(v), byte(v >> 8)} }
+	data := append([]byte("GIF89a"), le(w)...)
+	data = append(data, le(h)...)
+	data = append(data, 0x80, 0, 0, 0, 0, 0, 0xff, 0xff, 0xff)
+	for range frames {
+		data = append(data, 0x2c, 0, 0, 0, 0)
+		data = append(data, le(w)...)
+		data = append(data, le(h)...)
+		data = append(data, 0, 2, 2, 0x4c, 0x01, 0)
+	}
+	return append(data, 0x3b)
+}
+
+func TestGIFFrames(t *testing.T) {
+	paletted := image.NewPaletted(image.Rect(0, 0, 3, 2), []color.Color{color.Black, color.White})
+	data := encodeGIF(t, &gif.GIF{Image: []*image.Paletted{paletted, paletted}, Delay: []int{5, 5}})
+
+	frames, pixels, err := gifFrames(data)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if frames != 2 || pixels != 12 {
+		t.Errorf("Expected 2 frames of 12 pixels, got %d frames of %d", frames, pixels)
+	}
+	if _, _, err := gifFrames(data[:len(data)-4]); err == nil {
+		t.Errorf("Expected an error for a truncated GIF")
+	}
+}
+
+func TestProcessGIFLimits(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    []byte
+		wantErr error
+	}{
+		{"Too many frames", rawGIF(2, 2, MaxFrames+1), ErrTooManyFrames},
+		// 6000x6000 fits MaxPixels, 5 frames of it don't fit MaxGIFPixels
+		{"Too many pixels over all frames", rawGIF(6000, 6000, 5), ErrAnimationSize},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Process(context.Background(), tt.data)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Expected %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestOrientYCbCr(t *testing.T) {
+	ycbcr := image.NewYCbCr(image.Rect(0, 0, 4, 2), image.YCbCrSubsampleRatio444)
+	for i := range ycbcr.Y {
+		ycbcr.Y[i] = uint8(i * 30)
+		ycbcr.Cb[i] = uint8(255 - i*20)
+		ycbcr.Cr[i] = uint8(i * 10)
+	}
+	rgba := image.NewRGBA(ycbcr.Bounds())
+	draw.Draw(rgba, rgba.Bounds(), ycbcr, image.Point{}, draw.Src)
+
+	for orientation := 2; orientation <= 8; orientation++ {
+		got, want := orient(ycbcr, orientation), orient(rgba, orientation)
+		if got.Bounds() != want.Bounds() {
+			t.Fatalf("Expected %v, got %v", want.Bounds(), got.Bounds())
+		}
+		for y := 0; y < want.Bounds().Dy(); y++ {
+			for x := 0; x < want.Bounds().Dx(); x++ {
+				if got.At(x, y) != want.At(x, y) {
+					t.Errorf("Orientation %d: expected %v at (%d, %d), got %v", orientation, want.At(x, y), x, y, got.At(x, y))
+				}
+			}
+		}
+	}
+}
+
+func TestAcquireDecode(t *testing.T) {
+	var releases []func()
+	for range MaxDecodes {
+		release, err := acquireDecode(context.Background())
+		if err != nil {
+			t.Fatalf("Expected a slot, got %v", err)
+		}
+		releases = append(releases, release)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := acquireDecode(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected %v with every slot taken, got %v", context.Canceled, err)
+	}
+	for _, release := range releases {
+		release()
+	}
+}
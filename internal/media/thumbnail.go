@@ -0,0 +1,90 @@
+package media
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+
+	xdraw "golang.org/x/image/draw"
+)
+
+// Size is a thumbnail size, images are scaled down to fit in a MaxEdge
+// square.
+type Size struct {
+	Name    string
+	MaxEdge int
+}
+
+var Sizes = []Size{
+	{Name: "small", MaxEdge: 160},
+	{Name: "medium", MaxEdge: 480},
+	{Name: "large", MaxEdge: 1080},
+}
+
+// blurhashEdge is the size the image is scaled to before computing its
+// blurhash, a blurred placeholder needs no more.
+const blurhashEdge = 32
+
+type Thumbnail struct {
+	Size string
+	Image
+}
+
+// Thumbnails scales data, an image stored by Process, to each of Sizes
+// smaller than it and computes its blurhash. JPEGs stay JPEGs, other
+// thumbnails are PNGs of the first frame. Like Process it waits for a decode
+// slot.
+func Thumbnails(ctx context.Context, data []byte) ([]Thumbnail, string, error) {
+	release, err := acquireDecode(ctx)
+	if err != nil {
+		return nil, "", err
+	}
+	defer release()
+
+	img, format, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", fmt.Errorf("invalid image: %w", err)
+	}
+	contentType := "image/png"
+	if format == "jpeg" {
+		contentType = "image/jpeg"
+	}
+
+	var thumbnails []Thumbnail
+	for _, size := range Sizes {
+		scaled, ok := scale(img, size.MaxEdge, xdraw.CatmullRom)
+		if !ok {
+			continue
+		}
+		encoded, err := encode(scaled, contentType)
+		if err != nil {
+			return nil, "", err
+		}
+		thumbnails = append(thumbnails, Thumbnail{Size: size.Name, Image: encoded})
+	}
+
+	small, ok := scale(img, blurhashEdge, xdraw.ApproxBiLinear)
+	if !ok {
+		small = img
+	}
+	return thumbnails, Blurhash(small), nil
+}
+
+// scale fits img in a maxEdge square, keeping its aspect ratio. It's false
+// when img already fits.
+func scale(img image.Image, maxEdge int, scaler xdraw.Scaler) (image.Image, bool) {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w <= maxEdge && h <= maxEdge {
+		return img, false
+	}
+
+	dw, dh := maxEdge, max(1, h*maxEdge/w)
+	if h > w {
+		dw, dh = max(1, w*maxEdge/h), maxEdge
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	scaler.Scale(dst, dst.Bounds(), img, b, xdraw.Src, nil)
+	return dst, true
+}
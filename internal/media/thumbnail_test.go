@@ -0,0 +1,131 @@
+package media
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"image/gif"
+	"testing"
+)
+
+func TestThumbnails(t *testing.T) {
+	tests := []struct {
+		name            string
+		data            func(t *testing.T) []byte
+		wantContentType string
+		// wantSizes are the expected thumbnails with their width and height
+		wantSizes map[string][2]int
+	}{
+		{
+			name:            "Wide JPEG",
+			data:            func(t *testing.T) []byte { return encodeJPEG(t, testImage(2000, 1000)) },
+			wantContentType: "image/jpeg",
+			wantSizes: map[string][2]int{
+				"small":  {160, 80},
+				"medium": {480, 240},
+				"large":  {1080, 540},
+			},
+		},
+		{
+			name:            "Tall PNG",
+			data:            func(t *testing.T) []byte { return encodePNG(t, testImage(300, 600)) },
+			wantContentType: "image/png",
+			wantSizes: map[string][2]int{
+				"small":  {80, 160},
+				"medium": {240, 480},
+			},
+		},
+		{
+			name:      "Smaller than every size",
+			data:      func(t *testing.T) []byte { return encodePNG(t, testImage(100, 100)) },
+			wantSizes: map[string][2]int{},
+		},
+		{
+			name: "GIF",
+			data: func(t *testing.T) []byte {
+				palette := color.Palette{color.Black, color.White}
+				frame := image.NewPaletted(image.Rect(0, 0, 320, 200), palette)
+				return encodeGIF(t, &gif.GIF{Image: []*image.Paletted{frame, frame}, Delay: []int{10, 10}})
+			},
+			wantContentType: "image/png",
+			wantSizes: map[string][2]int{
+				"small": {160, 100},
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			thumbnails, blurhash, err := Thumbnails(context.Background(), tc.data(t))
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if blurhash == "" {
+				t.Errorf("Expected a blurhash")
+			}
+			if len(thumbnails) != len(tc.wantSizes) {
+				t.Fatalf("Expected %d thumbnails, got %d", len(tc.wantSizes), len(thumbnails))
+			}
+			for _, thumbnail := range thumbnails {
+				want, ok := tc.wantSizes[thumbnail.Size]
+				if !ok {
+					t.Fatalf("Unexpected size %q", thumbnail.Size)
+				}
+				if thumbnail.Width != want[0] || thumbnail.Height != want[1] {
+					t.Errorf("Expected %s to be %dx%d, got %dx%d", thumbnail.Size, want[0], want[1], thumbnail.Width, thumbnail.Height)
+				}
+				if thumbnail.ContentType != tc.wantContentType {
+					t.Errorf("Expected content type %q, got %q", tc.wantContentType, thumbnail.ContentType)
+				}
+				cfg, _, err := image.DecodeConfig(bytes.NewReader(thumbnail.Data))
+				if err != nil {
+					t.Fatalf("Expected a valid image, got %v", err)
+				}
+				if cfg.Width != want[0] || cfg.Height != want[1] {
+					t.Errorf("Expected encoded %s to be %dx%d, got %dx%d", thumbnail.Size, want[0], want[1], cfg.Width, cfg.Height)
+				}
+			}
+		})
+	}
+}
+
+func TestThumbnailsInvalid(t *testing.T) {
+	if _, _, err := Thumbnails(context.Background(), []byte("not an image")); err == nil {
+		t.Errorf("Expected an error")
+	}
+}
+
+func TestBlurhash(t *testing.T) {
+	gradient := image.NewRGBA(image.Rect(0, 0, 8, 6))
+	for y := 0; y < 6; y++ {
+		for x := 0; x < 8; x++ {
+			gradient.Set(x, y, color.RGBA{uint8(x * 32), uint8(y * 40), 128, 255})
+		}
+	}
+	solid := image.NewRGBA(image.Rect(0, 0, 4, 4))
+	for y := 0; y < 4; y++ {
+		for x := 0; x < 4; x++ {
+			solid.Set(x, y, color.RGBA{255, 0, 0, 255})
+		}
+	}
+
+	// expected values from the reference implementation
+	tests := []struct {
+		name string
+		img  image.Image
+		want string
+	}{
+		{name: "Gradient", img: gradient, want: "LjF=ad3Ba|xuzONLfQnTeqf7fQf7"},
+		{name: "Solid", img: solid, want: "L~TI:j|cfQ|c|c$5fQ$5fQfQfQfQ"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got := Blurhash(tc.img)
+			if got != tc.want {
+				t.Errorf("Expected %q, got %q", tc.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,144 @@
+package media
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"io"
+	"log/slog"
+	"sync"
+
+	"github.com/google/uuid"
+
+	"github.com/gskll/chirpy2/internal/database"
+	"github.com/gskll/chirpy2/internal/storage"
+)
+
+// queueSize is how many uploads can wait for a worker. Uploads that don't fit
+// are processed when the server next starts.
+const queueSize = 256
+
+// Thumbnailer generates the thumbnails and blurhash of uploads in the
+// background, on a pool of workers.
+type Thumbnailer struct {
+	db      *database.Queries
+	storage storage.Storage
+	workers int
+	queue   chan uuid.UUID
+}
+
+func NewThumbnailer(db *database.Queries, store storage.Storage, workers int) *Thumbnailer {
+	return &Thumbnailer{
+		db:      db,
+		storage: store,
+		workers: workers,
+		queue:   make(chan uuid.UUID, queueSize),
+	}
+}
+
+// Enqueue is false when the queue is full.
+func (t *Thumbnailer) Enqueue(mediaID uuid.UUID) bool {
+	select {
+	case t.queue <- mediaID:
+		return true
+	default:
+		return false
+	}
+}
+
+// Run processes uploads until ctx is done, starting with those a previous run
+// didn't get to.
+func (t *Thumbnailer) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for range t.workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case mediaID := <-t.queue:
+					if err := t.Process(ctx, mediaID); err != nil && ctx.Err() == nil {
+						slog.Error("generate thumbnails", "media_id", mediaID, "error", err)
+					}
+				}
+			}
+		}()
+	}
+
+	t.enqueueBacklog(ctx)
+	wg.Wait()
+}
+
+// enqueueBacklog waits for room in the queue, unlike Enqueue.
+func (t *Thumbnailer) enqueueBacklog(ctx context.Context) {
+	backlog, err := t.db.ListUnprocessedMedia(ctx)
+	if err != nil {
+		if ctx.Err() == nil {
+			slog.Error("list unprocessed media", "error", err)
+		}
+		return
+	}
+	for _, mediaID := range backlog {
+		select {
+		case <-ctx.Done():
+			return
+		case t.queue <- mediaID:
+		}
+	}
+}
+
+// Process generates the thumbnails of an upload, see Thumbnails. Processing
+// it again replaces them.
+func (t *Thumbnailer) Process(ctx context.Context, mediaID uuid.UUID) error {
+	dbMedia, err := t.db.GetMediaUpload(ctx, mediaID)
+	if errors.Is(err, sql.ErrNoRows) {
+		// deleted with its chirp
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	blob, err := t.storage.Open(ctx, dbMedia.StorageKey)
+	if err != nil {
+		return err
+	}
+	data, err := io.ReadAll(blob)
+	blob.Close()
+	if err != nil {
+		return err
+	}
+
+	thumbnails, blurhash, err := Thumbnails(ctx, data)
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if err != nil {
+		// it won't decode any better on the next run
+		return errors.Join(err, t.db.SetMediaProcessed(ctx, database.SetMediaProcessedParams{ID: mediaID}))
+	}
+	for _, thumbnail := range thumbnails {
+		key := "media/" + mediaID.String() + "/" + thumbnail.Size + thumbnail.Ext()
+		err := t.storage.Put(ctx, key, bytes.NewReader(thumbnail.Data), int64(len(thumbnail.Data)), thumbnail.ContentType)
+		if err != nil {
+			return err
+		}
+		err = t.db.CreateMediaThumbnail(ctx, database.CreateMediaThumbnailParams{
+			MediaID:     mediaID,
+			Size:        thumbnail.Size,
+			ContentType: thumbnail.ContentType,
+			StorageKey:  key,
+			SizeBytes:   int64(len(thumbnail.Data)),
+			Width:       int32(thumbnail.Width),
+			Height:      int32(thumbnail.Height),
+		})
+		if err != nil {
+			// most likely the upload was deleted meanwhile
+			return errors.Join(err, t.storage.Delete(ctx, key))
+		}
+	}
+	return t.db.SetMediaProcessed(ctx, database.SetMediaProcessedParams{ID: mediaID, Blurhash: blurhash})
+}
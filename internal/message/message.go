@@ -0,0 +1,101 @@
+package message
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/gskll/chirpy2/internal/database"
+)
+
+// MaxMembers is the largest group conversation, including its creator.
+const MaxMembers = 8
+
+var ErrNoMembers = errors.New("conversation needs at least one other user")
+
+type Message struct {
+	ID             uuid.UUID `json:"id"`
+	ConversationId uuid.UUID `json:"conversation_id"`
+	SenderId       uuid.UUID `json:"sender_id"`
+	Body           string    `json:"body"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+func NewMessage(dbMessage database.DirectMessage) Message {
+	return Message{
+		ID:             dbMessage.ID,
+		ConversationId: dbMessage.ConversationID,
+		SenderId:       dbMessage.SenderID,
+		Body:           dbMessage.Body,
+		CreatedAt:      dbMessage.CreatedAt,
+	}
+}
+
+// Conversation is a 1:1 conversation when Direct, a group otherwise. Unread
+// counts the caller's unread messages.
+type Conversation struct {
+	ID            uuid.UUID   `json:"id"`
+	Direct        bool        `json:"direct"`
+	MemberIds     []uuid.UUID `json:"member_ids"`
+	Unread        int64       `json:"unread"`
+	LastMessageAt *time.Time  `json:"last_message_at,omitempty"`
+	CreatedAt     time.Time   `json:"created_at"`
+	UpdatedAt     time.Time   `json:"updated_at"`
+}
+
+func NewConversation(dbConversation database.Conversation, memberIDs []uuid.UUID) Conversation {
+	c := Conversation{
+		ID:        dbConversation.ID,
+		Direct:    dbConversation.DirectKey.Valid,
+		MemberIds: memberIDs,
+		CreatedAt: dbConversation.CreatedAt,
+		UpdatedAt: dbConversation.UpdatedAt,
+	}
+	if dbConversation.LastMessageAt.Valid {
+		c.LastMessageAt = &dbConversation.LastMessageAt.Time
+	}
+	return c
+}
+
+func NewConversationFromRow(row database.ListConversationsRow) Conversation {
+	c := NewConversation(database.Conversation{
+		ID:            row.ID,
+		DirectKey:     row.DirectKey,
+		LastMessageAt: row.LastMessageAt,
+		CreatedAt:     row.CreatedAt,
+		UpdatedAt:     row.UpdatedAt,
+	}, row.MemberIds)
+	c.Unread = row.UnreadCount
+	return c
+}
+
+// Members returns the other members of a conversation started by creator,
+// without duplicates or the creator.
+func Members(creator uuid.UUID, userIDs []uuid.UUID) ([]uuid.UUID, error) {
+	members := make([]uuid.UUID, 0, len(userIDs))
+	for _, id := range userIDs {
+		if id == creator || id == uuid.Nil || slices.Contains(members, id) {
+			continue
+		}
+		members = append(members, id)
+	}
+	if len(members) == 0 {
+		return nil, ErrNoMembers
+	}
+	if len(members)+1 > MaxMembers {
+		return nil, fmt.Errorf("conversation has too many members, max %d, got %d", MaxMembers, len(members)+1)
+	}
+	return members, nil
+}
+
+// DirectKey identifies the 1:1 conversation between two users, whichever of
+// them starts it.
+func DirectKey(a, b uuid.UUID) string {
+	if a.String() > b.String() {
+		a, b = b, a
+	}
+	return a.String() + ":" + b.String()
+}
@@ -0,0 +1,61 @@
+package message
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestMembers(t *testing.T) {
+	creator := uuid.New()
+	a := uuid.New()
+	b := uuid.New()
+	tooMany := make([]uuid.UUID, MaxMembers)
+	for i := range tooMany {
+		tooMany[i] = uuid.New()
+	}
+
+	tests := []struct {
+		name    string
+		userIDs []uuid.UUID
+		want    []uuid.UUID
+		wantErr bool
+	}{
+		{"Direct", []uuid.UUID{a}, []uuid.UUID{a}, false},
+		{"Group", []uuid.UUID{a, b}, []uuid.UUID{a, b}, false},
+		{"Duplicates and creator dropped", []uuid.UUID{a, creator, a, b}, []uuid.UUID{a, b}, false},
+		{"Max members", tooMany[:MaxMembers-1], tooMany[:MaxMembers-1], false},
+		{"Too many members", tooMany, nil, true},
+		{"Only the creator", []uuid.UUID{creator}, nil, true},
+		{"Empty", nil, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Members(creator, tt.userIDs)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Expected error %v, got %v", tt.wantErr, err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("Expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+
+	if _, err := Members(creator, nil); !errors.Is(err, ErrNoMembers) {
+		t.Errorf("Expected ErrNoMembers, got %v", err)
+	}
+}
+
+func TestDirectKey(t *testing.T) {
+	a := uuid.New()
+	b := uuid.New()
+	if DirectKey(a, b) != DirectKey(b, a) {
+		t.Errorf("Expected the same key both ways, got %s and %s", DirectKey(a, b), DirectKey(b, a))
+	}
+	if DirectKey(a, b) == DirectKey(a, uuid.New()) {
+		t.Errorf("Expected different keys for different users")
+	}
+}
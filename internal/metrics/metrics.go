@@ -0,0 +1,132 @@
+package metrics
+
+import (
+	"database/sql"
+	"math"
+	"net/http"
+	"sync/atomic"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	dto "github.com/prometheus/client_model/go"
+)
+
+const namespace = "chirpy"
+
+type Metrics struct {
+	registry *prometheus.Registry
+	// visitsBase is FileServerHits at the last ResetVisits, as float64 bits
+	visitsBase atomic.Uint64
+
+	RequestsTotal    *prometheus.CounterVec
+	RequestDuration  *prometheus.HistogramVec
+	RequestsInFlight prometheus.Gauge
+	FileServerHits   prometheus.Counter
+	RateLimited      *prometheus.CounterVec
+
+	ChirpsCreated     prometheus.Counter
+	ChirpsDeleted     prometheus.Counter
+	ChirpsFiltered    *prometheus.CounterVec
+	Logins            *prometheus.CounterVec
+	WebhooksProcessed *prometheus.CounterVec
+}
+
+func New(db *sql.DB) *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		RequestsTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "http_requests_total",
+			Help:      "HTTP requests by route pattern and status code.",
+		}, []string{"pattern", "code"}),
+		RequestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "HTTP request latency by route pattern.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"pattern"}),
+		RequestsInFlight: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "http_requests_in_flight",
+			Help:      "HTTP requests currently being served.",
+		}),
+		FileServerHits: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "fileserver_hits_total",
+			Help:      "Requests served under /app.",
+		}),
+		RateLimited: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "rate_limited_total",
+			Help:      "Requests rejected by the rate limiter, by route group.",
+		}, []string{"group"}),
+		ChirpsCreated: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "chirps_created_total",
+			Help:      "Chirps created.",
+		}),
+		ChirpsDeleted: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "chirps_deleted_total",
+			Help:      "Chirps deleted.",
+		}),
+		ChirpsFiltered: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "chirps_filtered_total",
+			Help:      "Chirps the content filter acted on, by action.",
+		}, []string{"action"}),
+		Logins: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "logins_total",
+			Help:      "Login attempts by result.",
+		}, []string{"result"}),
+		WebhooksProcessed: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "webhooks_processed_total",
+			Help:      "Polka webhooks by event and result.",
+		}, []string{"event", "result"}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.RequestsTotal,
+		m.RequestDuration,
+		m.RequestsInFlight,
+		m.FileServerHits,
+		m.RateLimited,
+		m.ChirpsCreated,
+		m.ChirpsDeleted,
+		m.ChirpsFiltered,
+		m.Logins,
+		m.WebhooksProcessed,
+	)
+	if db != nil {
+		m.registry.MustRegister(collectors.NewDBStatsCollector(db, "chirpy"))
+	}
+
+	return m
+}
+
+// Visits is the /app hit count shown on /admin/metrics. FileServerHits only
+// goes up, so resetting the count moves a baseline instead.
+func (m *Metrics) Visits() int64 {
+	return int64(counterValue(m.FileServerHits) - math.Float64frombits(m.visitsBase.Load()))
+}
+
+func (m *Metrics) ResetVisits() {
+	m.visitsBase.Store(math.Float64bits(counterValue(m.FileServerHits)))
+}
+
+func counterValue(c prometheus.Counter) float64 {
+	var metric dto.Metric
+	if err := c.Write(&metric); err != nil {
+		return 0
+	}
+	return metric.GetCounter().GetValue()
+}
+
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{Registry: m.registry})
+}
@@ -0,0 +1,18 @@
+package metrics
+
+import "testing"
+
+func TestVisits(t *testing.T) {
+	m := New(nil)
+
+	m.FileServerHits.Add(3)
+	if got := m.Visits(); got != 3 {
+		t.Errorf("Expected 3 visits, got %d", got)
+	}
+
+	m.ResetVisits()
+	m.FileServerHits.Inc()
+	if got := m.Visits(); got != 1 {
+		t.Errorf("Expected 1 visit after reset, got %d", got)
+	}
+}
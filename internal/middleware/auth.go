@@ -0,0 +1,157 @@
+package middleware
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+
+	"github.com/google/uuid"
+
+	"github.com/gskll/chirpy2/internal/auth"
+	"github.com/gskll/chirpy2/internal/database"
+	"github.com/gskll/chirpy2/internal/user"
+)
+
+type contextKey int
+
+const (
+	userIDKey contextKey = iota
+	scopesKey
+	loggerKey
+	requestInfoKey
+)
+
+// RequireAuth rejects requests without a valid access token and stores the
+// token's user and scopes in the request context.
+func (m *Middleware) RequireAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") == "" {
+			unauthorized(w, "", "missing bearer token")
+			return
+		}
+		ctx, err := m.authenticate(r)
+		if err != nil {
+			unauthorized(w, "invalid_token", err.Error())
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// OptionalAuth lets anonymous requests through but still rejects invalid
+// tokens, so a client never silently falls back to anonymous access.
+func (m *Middleware) OptionalAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		ctx, err := m.authenticate(r)
+		if err != nil {
+			unauthorized(w, "invalid_token", err.Error())
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// RequireScope must be wrapped by RequireAuth.
+func (m *Middleware) RequireScope(scope string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !HasScope(r.Context(), scope) {
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="chirpy", error="insufficient_scope", scope=%q`, scope))
+			respondWithError(w, http.StatusForbidden, "insufficient scope")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// RequireRole must be wrapped by RequireAuth. The role is read from the
+// database on every request so demotions and suspensions apply immediately.
+func (m *Middleware) RequireRole(role string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		dbUser, ok := m.activeUser(w, r)
+		if !ok {
+			return
+		}
+		if !user.HasRole(dbUser.Role, role) {
+			respondWithError(w, http.StatusForbidden, "forbidden")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// RequireActive must be wrapped by RequireAuth. It rejects suspended users,
+// their access tokens stay valid until they expire.
+func (m *Middleware) RequireActive(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := m.activeUser(w, r); ok {
+			next.ServeHTTP(w, r)
+		}
+	})
+}
+
+// activeUser loads the authenticated user, responding when they no longer
+// exist or are suspended.
+func (m *Middleware) activeUser(w http.ResponseWriter, r *http.Request) (database.User, bool) {
+	userId, _ := UserID(r.Context())
+	dbUser, err := m.cfg.Db.GetUser(r.Context(), userId)
+	if errors.Is(err, sql.ErrNoRows) {
+		unauthorized(w, "invalid_token", "user no longer exists")
+		return database.User{}, false
+	}
+	if err != nil {
+		Log(r.Context()).Error("load user", "error", err)
+		respondWithError(w, http.StatusInternalServerError, "internal server error")
+		return database.User{}, false
+	}
+	if dbUser.SuspendedAt.Valid {
+		respondWithError(w, http.StatusForbidden, "account suspended")
+		return database.User{}, false
+	}
+	return dbUser, true
+}
+
+func (m *Middleware) authenticate(r *http.Request) (context.Context, error) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		return nil, err
+	}
+	userId, claims, err := auth.ParseJWT(token, m.cfg.JWTSecret)
+	if err != nil {
+		return nil, fmt.Errorf("invalid or expired token")
+	}
+
+	ctx := context.WithValue(r.Context(), userIDKey, userId)
+	ctx = context.WithValue(ctx, scopesKey, claims.Scopes())
+	return withUser(ctx, userId), nil
+}
+
+func unauthorized(w http.ResponseWriter, errCode, msg string) {
+	challenge := `Bearer realm="chirpy"`
+	if errCode != "" {
+		challenge += fmt.Sprintf(`, error=%q, error_description=%q`, errCode, msg)
+	}
+	w.Header().Set("WWW-Authenticate", challenge)
+	respondWithError(w, http.StatusUnauthorized, msg)
+}
+
+// UserID returns the authenticated user, ok is false for anonymous requests.
+func UserID(ctx context.Context) (uuid.UUID, bool) {
+	userId, ok := ctx.Value(userIDKey).(uuid.UUID)
+	return userId, ok
+}
+
+func Scopes(ctx context.Context) []string {
+	scopes, _ := ctx.Value(scopesKey).([]string)
+	return scopes
+}
+
+func HasScope(ctx context.Context, scope string) bool {
+	return slices.Contains(Scopes(ctx), scope)
+}
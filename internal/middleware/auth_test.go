@@ -0,0 +1,116 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/gskll/chirpy2/internal/auth"
+	"github.com/gskll/chirpy2/internal/config"
+)
+
+func TestRequireAuth(t *testing.T) {
+	secret := "test-secret"
+	m := NewMiddleware(&config.ApiConfig{JWTSecret: secret})
+	userID := uuid.New()
+	validToken, _ := auth.MakeJWT(userID, secret, time.Hour, "admin")
+	expiredToken, _ := auth.MakeJWT(userID, secret, -time.Hour)
+
+	var gotUserID uuid.UUID
+	var gotScopes []string
+	handler := m.RequireAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotUserID, _ = UserID(r.Context())
+		gotScopes = Scopes(r.Context())
+	}))
+
+	tests := []struct {
+		name          string
+		authorization string
+		wantStatus    int
+		wantChallenge string
+	}{
+		{"Valid token", "Bearer " + validToken, http.StatusOK, ""},
+		{"Missing header", "", http.StatusUnauthorized, `Bearer realm="chirpy"`},
+		{"Malformed header", "Token abc", http.StatusUnauthorized, `error="invalid_token"`},
+		{"Expired token", "Bearer " + expiredToken, http.StatusUnauthorized, `error="invalid_token"`},
+		{"Wrong secret", "Bearer " + mustMakeJWT(t, userID, "other-secret"), http.StatusUnauthorized, `error="invalid_token"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotUserID = uuid.Nil
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.authorization != "" {
+				r.Header.Set("Authorization", tt.authorization)
+			}
+			w := httptest.NewRecorder()
+
+			handler.ServeHTTP(w, r)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("Expected status %d, got %d", tt.wantStatus, w.Code)
+			}
+			if tt.wantStatus == http.StatusOK {
+				if gotUserID != userID {
+					t.Errorf("Expected user %v in context, got %v", userID, gotUserID)
+				}
+				if len(gotScopes) != 1 || gotScopes[0] != "admin" {
+					t.Errorf("Unexpected scopes in context: %v", gotScopes)
+				}
+				return
+			}
+			if challenge := w.Header().Get("WWW-Authenticate"); !strings.Contains(challenge, tt.wantChallenge) {
+				t.Errorf("Expected WWW-Authenticate to contain %q, got %q", tt.wantChallenge, challenge)
+			}
+			if gotUserID != uuid.Nil {
+				t.Error("Handler should not have been called")
+			}
+		})
+	}
+}
+
+func TestOptionalAuth(t *testing.T) {
+	secret := "test-secret"
+	m := NewMiddleware(&config.ApiConfig{JWTSecret: secret})
+
+	var authenticated bool
+	handler := m.OptionalAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, authenticated = UserID(r.Context())
+	}))
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusOK || authenticated {
+		t.Errorf("Anonymous request: status %d, authenticated %v", w.Code, authenticated)
+	}
+
+	r = httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Authorization", "Bearer "+mustMakeJWT(t, uuid.New(), secret))
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusOK || !authenticated {
+		t.Errorf("Authenticated request: status %d, authenticated %v", w.Code, authenticated)
+	}
+
+	r = httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Authorization", "Bearer garbage")
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("Invalid token: expected 401, got %d", w.Code)
+	}
+}
+
+func mustMakeJWT(t *testing.T, userID uuid.UUID, secret string) string {
+	t.Helper()
+	token, err := auth.MakeJWT(userID, secret, time.Hour)
+	if err != nil {
+		t.Fatalf("MakeJWT returned an error: %v", err)
+	}
+	return token
+}
@@ -0,0 +1,45 @@
+package middleware
+
+import (
+	"net/http"
+	"net/netip"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+	trusted := []netip.Prefix{
+		netip.MustParsePrefix("10.0.0.0/8"),
+		netip.MustParsePrefix("::1/128"),
+	}
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		forwarded  []string
+		want       string
+	}{
+		{"Direct client", "203.0.113.7:5123", nil, "203.0.113.7"},
+		{"Untrusted peer ignores header", "203.0.113.7:5123", []string{"198.51.100.1"}, "203.0.113.7"},
+		{"Trusted proxy", "10.0.0.2:80", []string{"198.51.100.1"}, "198.51.100.1"},
+		{"Spoofed leftmost hop", "10.0.0.2:80", []string{"1.2.3.4, 198.51.100.1"}, "198.51.100.1"},
+		{"Chain of trusted proxies", "10.0.0.2:80", []string{"198.51.100.1, 10.0.0.3"}, "198.51.100.1"},
+		{"Multiple headers", "10.0.0.2:80", []string{"1.2.3.4", "198.51.100.1"}, "198.51.100.1"},
+		{"Only trusted hops", "10.0.0.2:80", []string{"10.0.0.4"}, "10.0.0.4"},
+		{"Invalid hop stops walk", "10.0.0.2:80", []string{"198.51.100.1, garbage"}, "10.0.0.2"},
+		{"IPv6 trusted proxy", "[::1]:80", []string{"2001:db8::1"}, "2001:db8::1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &http.Request{RemoteAddr: tt.remoteAddr, Header: http.Header{}}
+			for _, v := range tt.forwarded {
+				r.Header.Add("X-Forwarded-For", v)
+			}
+
+			got := ClientIP(r, trusted)
+			if got.String() != tt.want {
+				t.Errorf("ClientIP() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
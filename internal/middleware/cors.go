@@ -0,0 +1,46 @@
+package middleware
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+)
+
+const corsMaxAge = 10 * time.Minute
+
+// CORS allows cross-origin requests from the configured origins and answers
+// preflight requests directly.
+func (m *Middleware) CORS(next http.Handler) http.Handler {
+	allowAny := slices.Contains(m.cfg.AllowedOrigins, "*")
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Add("Vary", "Origin")
+		switch {
+		case allowAny:
+			w.Header().Set("Access-Control-Allow-Origin", "*")
+		case slices.Contains(m.cfg.AllowedOrigins, origin):
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+		default:
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE")
+			w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type, Last-Event-ID")
+			w.Header().Set("Access-Control-Max-Age", strconv.Itoa(int(corsMaxAge.Seconds())))
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		w.Header().Set("Access-Control-Expose-Headers", "Retry-After, RateLimit-Policy, RateLimit-Limit, RateLimit-Remaining, RateLimit-Reset")
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,54 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gskll/chirpy2/internal/config"
+)
+
+func TestCORS(t *testing.T) {
+	tests := []struct {
+		name          string
+		allowed       []string
+		method        string
+		origin        string
+		preflight     bool
+		wantStatus    int
+		wantAllowOrig string
+	}{
+		{"No origin", []string{"https://chirpy.dev"}, http.MethodGet, "", false, http.StatusOK, ""},
+		{"Any origin", []string{"*"}, http.MethodGet, "https://evil.example", false, http.StatusOK, "*"},
+		{"Allowed origin", []string{"https://chirpy.dev"}, http.MethodGet, "https://chirpy.dev", false, http.StatusOK, "https://chirpy.dev"},
+		{"Disallowed origin", []string{"https://chirpy.dev"}, http.MethodGet, "https://evil.example", false, http.StatusOK, ""},
+		{"Preflight", []string{"https://chirpy.dev"}, http.MethodOptions, "https://chirpy.dev", true, http.StatusNoContent, "https://chirpy.dev"},
+		{"Disallowed preflight falls through", []string{"https://chirpy.dev"}, http.MethodOptions, "https://evil.example", true, http.StatusOK, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewMiddleware(&config.ApiConfig{AllowedOrigins: tt.allowed})
+			handler := m.CORS(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			}))
+
+			req := httptest.NewRequest(tt.method, "/api/chirps", nil)
+			if tt.origin != "" {
+				req.Header.Set("Origin", tt.origin)
+			}
+			if tt.preflight {
+				req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+			}
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Errorf("Expected status %d, got %d", tt.wantStatus, rr.Code)
+			}
+			if got := rr.Header().Get("Access-Control-Allow-Origin"); got != tt.wantAllowOrig {
+				t.Errorf("Expected Access-Control-Allow-Origin %q, got %q", tt.wantAllowOrig, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,30 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Instrument records request metrics labelled by the ServeMux pattern that
+// served the request. The middleware between it and the mux must pass the
+// request on rather than a copy: the mux sets r.Pattern on the request it is
+// given. Requests answered before the mux, rate limited requests and CORS
+// preflights, are labelled "unmatched".
+func (m *Middleware) Instrument(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		m.cfg.Metrics.RequestsInFlight.Inc()
+		defer m.cfg.Metrics.RequestsInFlight.Dec()
+
+		rec := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+
+		pattern := r.Pattern
+		if pattern == "" {
+			pattern = "unmatched"
+		}
+		m.cfg.Metrics.RequestsTotal.WithLabelValues(pattern, strconv.Itoa(rec.status)).Inc()
+		m.cfg.Metrics.RequestDuration.WithLabelValues(pattern).Observe(time.Since(start).Seconds())
+	})
+}
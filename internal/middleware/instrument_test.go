@@ -0,0 +1,48 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/gskll/chirpy2/internal/config"
+	"github.com/gskll/chirpy2/internal/metrics"
+)
+
+func TestInstrument(t *testing.T) {
+	cfg := &config.ApiConfig{Metrics: metrics.New(nil), AllowedOrigins: []string{"*"}}
+	m := NewMiddleware(cfg)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/chirps/{chirpID}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+	handler := m.Instrument(m.CORS(mux))
+
+	for _, path := range []string{"/api/chirps/1", "/api/chirps/2", "/nope"} {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+	preflight := httptest.NewRequest(http.MethodOptions, "/api/chirps/1", nil)
+	preflight.Header.Set("Origin", "https://example.com")
+	preflight.Header.Set("Access-Control-Request-Method", http.MethodGet)
+	handler.ServeHTTP(httptest.NewRecorder(), preflight)
+
+	requests := cfg.Metrics.RequestsTotal
+	if got := testutil.ToFloat64(requests.WithLabelValues("GET /api/chirps/{chirpID}", "404")); got != 2 {
+		t.Errorf("Expected 2 requests labelled by pattern, got %v", got)
+	}
+	if got := testutil.ToFloat64(requests.WithLabelValues("unmatched", "404")); got != 1 {
+		t.Errorf("Expected 1 unmatched request, got %v", got)
+	}
+	if got := testutil.ToFloat64(requests.WithLabelValues("unmatched", "204")); got != 1 {
+		t.Errorf("Expected 1 unmatched preflight, got %v", got)
+	}
+	if got := testutil.CollectAndCount(cfg.Metrics.RequestDuration); got != 2 {
+		t.Errorf("Expected 2 latency series, got %d", got)
+	}
+	if got := testutil.ToFloat64(cfg.Metrics.RequestsInFlight); got != 0 {
+		t.Errorf("Expected no requests in flight, got %v", got)
+	}
+}
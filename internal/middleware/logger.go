@@ -0,0 +1,124 @@
+package middleware
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"regexp"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const RequestIDHeader = "X-Request-ID"
+
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+type requestInfo struct {
+	userID  uuid.UUID
+	traceID string
+}
+
+// Logger writes one structured log line per request and stores a request
+// scoped logger in the context, retrieve it with Log.
+func (m *Middleware) Logger(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		requestID := r.Header.Get(RequestIDHeader)
+		if !validRequestID.MatchString(requestID) {
+			requestID = uuid.NewString()
+		}
+		w.Header().Set(RequestIDHeader, requestID)
+
+		info := &requestInfo{}
+		logger := slog.Default().With("request_id", requestID)
+		ctx := context.WithValue(r.Context(), loggerKey, logger)
+		ctx = context.WithValue(ctx, requestInfoKey, info)
+
+		rec := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r.WithContext(ctx))
+
+		attrs := []any{
+			"method", r.Method,
+			"path", r.URL.Path,
+			"status", rec.status,
+			"bytes", rec.bytes,
+			"duration", time.Since(start),
+			"remote_ip", ClientIP(r, m.cfg.TrustedProxies).String(),
+		}
+		if info.userID != uuid.Nil {
+			attrs = append(attrs, "user_id", info.userID)
+		}
+		if info.traceID != "" {
+			attrs = append(attrs, "trace_id", info.traceID)
+		}
+
+		level := slog.LevelInfo
+		if rec.status >= http.StatusInternalServerError {
+			level = slog.LevelError
+		}
+		logger.Log(r.Context(), level, "request", attrs...)
+	})
+}
+
+// Log returns the request scoped logger, or the default logger outside of a
+// request.
+func Log(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(loggerKey).(*slog.Logger); ok {
+		return logger
+	}
+	return slog.Default()
+}
+
+// withUser records the authenticated user on the request log line and the
+// request scoped logger.
+func withUser(ctx context.Context, userID uuid.UUID) context.Context {
+	if info, ok := ctx.Value(requestInfoKey).(*requestInfo); ok {
+		info.userID = userID
+	}
+	return context.WithValue(ctx, loggerKey, Log(ctx).With("user_id", userID))
+}
+
+type responseRecorder struct {
+	http.ResponseWriter
+	status      int
+	bytes       int
+	wroteHeader bool
+}
+
+func (rec *responseRecorder) WriteHeader(code int) {
+	if !rec.wroteHeader {
+		rec.status = code
+		rec.wroteHeader = true
+	}
+	rec.ResponseWriter.WriteHeader(code)
+}
+
+func (rec *responseRecorder) Write(b []byte) (int, error) {
+	rec.wroteHeader = true
+	n, err := rec.ResponseWriter.Write(b)
+	rec.bytes += n
+	return n, err
+}
+
+func (rec *responseRecorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
+
+func (rec *responseRecorder) Flush() {
+	http.NewResponseController(rec.ResponseWriter).Flush()
+}
+
+// Hijack is needed by the websocket upgrader which type asserts http.Hijacker.
+func (rec *responseRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := rec.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("response writer does not support hijacking")
+	}
+	rec.status = http.StatusSwitchingProtocols
+	return hijacker.Hijack()
+}
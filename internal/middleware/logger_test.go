@@ -0,0 +1,94 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/gskll/chirpy2/internal/auth"
+	"github.com/gskll/chirpy2/internal/config"
+)
+
+func TestLogger(t *testing.T) {
+	var buf bytes.Buffer
+	defaultLogger := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&buf, nil)))
+	defer slog.SetDefault(defaultLogger)
+
+	secret := "test-secret"
+	m := NewMiddleware(&config.ApiConfig{JWTSecret: secret})
+	userID := uuid.New()
+	token, _ := auth.MakeJWT(userID, secret, time.Hour)
+
+	handler := m.Logger(m.RequireAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		Log(r.Context()).Info("inside handler")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte("hello"))
+	})))
+
+	tests := []struct {
+		name          string
+		requestID     string
+		wantRequestID string
+	}{
+		{"Accepts incoming request id", "abc-123", "abc-123"},
+		{"Replaces invalid request id", "not valid!", ""},
+		{"Generates missing request id", "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf.Reset()
+			r := httptest.NewRequest(http.MethodPost, "/api/chirps", nil)
+			r.Header.Set("Authorization", "Bearer "+token)
+			if tt.requestID != "" {
+				r.Header.Set(RequestIDHeader, tt.requestID)
+			}
+			w := httptest.NewRecorder()
+
+			handler.ServeHTTP(w, r)
+
+			requestID := w.Header().Get(RequestIDHeader)
+			if tt.wantRequestID != "" && requestID != tt.wantRequestID {
+				t.Errorf("Expected request id %q, got %q", tt.wantRequestID, requestID)
+			}
+			if _, err := uuid.Parse(requestID); tt.wantRequestID == "" && err != nil {
+				t.Errorf("Expected generated uuid request id, got %q", requestID)
+			}
+
+			dec := json.NewDecoder(&buf)
+			var handlerLine, requestLine map[string]any
+			if err := dec.Decode(&handlerLine); err != nil {
+				t.Fatalf("Failed to decode handler log line: %v", err)
+			}
+			if err := dec.Decode(&requestLine); err != nil {
+				t.Fatalf("Failed to decode request log line: %v", err)
+			}
+
+			if handlerLine["request_id"] != requestID || handlerLine["user_id"] != userID.String() {
+				t.Errorf("Handler log line missing request context: %v", handlerLine)
+			}
+			if requestLine["request_id"] != requestID {
+				t.Errorf("Expected request_id %q, got %v", requestID, requestLine["request_id"])
+			}
+			if requestLine["status"] != float64(http.StatusCreated) {
+				t.Errorf("Expected status 201, got %v", requestLine["status"])
+			}
+			if requestLine["bytes"] != float64(5) {
+				t.Errorf("Expected 5 bytes, got %v", requestLine["bytes"])
+			}
+			if requestLine["user_id"] != userID.String() {
+				t.Errorf("Expected user_id %v, got %v", userID, requestLine["user_id"])
+			}
+			if requestLine["remote_ip"] != "192.0.2.1" {
+				t.Errorf("Unexpected remote_ip %v", requestLine["remote_ip"])
+			}
+		})
+	}
+}
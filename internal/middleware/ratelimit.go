@@ -0,0 +1,57 @@
+package middleware
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gskll/chirpy2/internal/auth"
+	"github.com/gskll/chirpy2/internal/ratelimit"
+)
+
+// RateLimit limits requests per route group, keyed on the authenticated user
+// when a valid access token is sent and on the client IP otherwise.
+func (m *Middleware) RateLimit(limiter *ratelimit.Limiter, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		group, limit, ok := limiter.Group(r)
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		key := group + ":ip:" + ClientIP(r, m.cfg.TrustedProxies).String()
+		if token, err := auth.GetBearerToken(r.Header); err == nil {
+			if userId, err := auth.ValidateJWT(token, m.cfg.JWTSecret); err == nil {
+				key = group + ":user:" + userId.String()
+			}
+		}
+
+		res, err := limiter.Store.Take(r.Context(), key, limit)
+		if err != nil {
+			// fail open, an unavailable store should not take the API down
+			Log(r.Context()).Error("rate limit", "key", key, "error", err)
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Set("RateLimit-Policy", fmt.Sprintf("%d;w=%d", limit.Requests, int(limit.Per.Seconds())))
+		w.Header().Set("RateLimit-Limit", strconv.Itoa(res.Limit))
+		w.Header().Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+		w.Header().Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(res.Reset)))
+
+		if !res.Allowed {
+			m.cfg.Metrics.RateLimited.WithLabelValues(group).Inc()
+			w.Header().Set("Retry-After", strconv.Itoa(ceilSeconds(res.RetryAfter)))
+			respondWithError(w, http.StatusTooManyRequests, "rate limit exceeded")
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
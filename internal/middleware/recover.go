@@ -0,0 +1,50 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"runtime/debug"
+	"time"
+)
+
+// Recover turns a panicking handler into a 500 JSON error and logs the stack
+// trace instead of dropping the connection.
+func (m *Middleware) Recover(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rec := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+		defer func() {
+			err := recover()
+			if err == nil {
+				return
+			}
+			if err == http.ErrAbortHandler {
+				panic(err)
+			}
+
+			Log(r.Context()).Error("panic", "error", err, "stack", string(debug.Stack()))
+			if !rec.wroteHeader {
+				respondWithError(rec, http.StatusInternalServerError, "internal server error")
+			}
+		}()
+
+		next.ServeHTTP(rec, r)
+	})
+}
+
+// MaxBytes limits the request body, reading past the limit fails with an
+// *http.MaxBytesError which handlers report as 413.
+func (m *Middleware) MaxBytes(n int64, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.Body = http.MaxBytesReader(w, r.Body, n)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Timeout sets a deadline on the request context.
+func (m *Middleware) Timeout(d time.Duration, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), d)
+		defer cancel()
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
@@ -0,0 +1,100 @@
+package middleware
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gskll/chirpy2/internal/config"
+)
+
+func TestRecover(t *testing.T) {
+	m := NewMiddleware(&config.ApiConfig{})
+
+	tests := []struct {
+		name       string
+		handler    http.HandlerFunc
+		wantStatus int
+		wantError  string
+	}{
+		{
+			name:       "Panic before writing",
+			handler:    func(w http.ResponseWriter, r *http.Request) { panic("boom") },
+			wantStatus: http.StatusInternalServerError,
+			wantError:  "internal server error",
+		},
+		{
+			name: "Panic after writing keeps status",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusAccepted)
+				panic("boom")
+			},
+			wantStatus: http.StatusAccepted,
+		},
+		{
+			name:       "No panic",
+			handler:    func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusNoContent) },
+			wantStatus: http.StatusNoContent,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			m.Recover(tt.handler).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("Expected status %d, got %d", tt.wantStatus, w.Code)
+			}
+			if tt.wantError == "" {
+				return
+			}
+			var body map[string]string
+			if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+				t.Fatalf("Expected JSON error body: %v", err)
+			}
+			if body["error"] != tt.wantError {
+				t.Errorf("Expected error %q, got %q", tt.wantError, body["error"])
+			}
+		})
+	}
+}
+
+func TestMaxBytes(t *testing.T) {
+	m := NewMiddleware(&config.ApiConfig{})
+
+	var readErr error
+	handler := m.MaxBytes(4, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, readErr = io.ReadAll(r.Body)
+	}))
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/", strings.NewReader("1234")))
+	if readErr != nil {
+		t.Errorf("Body within limit should be readable: %v", readErr)
+	}
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/", strings.NewReader("12345")))
+	var maxBytesErr *http.MaxBytesError
+	if !errors.As(readErr, &maxBytesErr) {
+		t.Errorf("Expected *http.MaxBytesError, got %v", readErr)
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	m := NewMiddleware(&config.ApiConfig{})
+
+	var deadline time.Time
+	var ok bool
+	m.Timeout(time.Minute, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		deadline, ok = r.Context().Deadline()
+	})).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if !ok || time.Until(deadline) > time.Minute {
+		t.Errorf("Expected a deadline within a minute, got %v (set: %v)", deadline, ok)
+	}
+}
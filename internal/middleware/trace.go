@@ -0,0 +1,59 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/gskll/chirpy2/internal/tracing"
+)
+
+// Trace starts a server span per request, continuing any W3C trace context
+// sent by the caller. The span is renamed to the ServeMux pattern once the
+// mux has routed the request, so every handler in between must pass the
+// request on unchanged.
+func (m *Middleware) Trace(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		ctx, span := tracing.Tracer().Start(ctx, r.Method,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				semconv.HTTPRequestMethodKey.String(r.Method),
+				semconv.URLPath(r.URL.Path),
+				semconv.ClientAddress(ClientIP(r, m.cfg.TrustedProxies).String()),
+			),
+		)
+		defer span.End()
+
+		if span.SpanContext().IsValid() {
+			ctx = withTraceID(ctx, span.SpanContext().TraceID().String())
+		}
+
+		r = r.WithContext(ctx)
+		rec := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+
+		if r.Pattern != "" {
+			span.SetName(r.Pattern)
+			span.SetAttributes(semconv.HTTPRoute(r.Pattern))
+		}
+		span.SetAttributes(semconv.HTTPResponseStatusCode(rec.status))
+		if rec.status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(rec.status))
+		}
+	})
+}
+
+// withTraceID records the trace on the request log line and the request
+// scoped logger.
+func withTraceID(ctx context.Context, traceID string) context.Context {
+	if info, ok := ctx.Value(requestInfoKey).(*requestInfo); ok {
+		info.traceID = traceID
+	}
+	return context.WithValue(ctx, loggerKey, Log(ctx).With("trace_id", traceID))
+}
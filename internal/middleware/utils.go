@@ -0,0 +1,19 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+func respondWithJSON(w http.ResponseWriter, code int, payload any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+
+	if err := json.NewEncoder(w).Encode(payload); err != nil {
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+	}
+}
+
+func respondWithError(w http.ResponseWriter, code int, msg string) {
+	respondWithJSON(w, code, map[string]string{"error": msg})
+}
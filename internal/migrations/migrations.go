@@ -0,0 +1,73 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/pressly/goose/v3"
+	"github.com/pressly/goose/v3/lock"
+
+	"github.com/gskll/chirpy2/sql/schema"
+)
+
+var ErrSchemaBehind = errors.New("database schema is behind")
+
+// Migrator applies the migrations embedded from sql/schema. Up and Down hold a
+// Postgres advisory lock so instances starting together don't race.
+type Migrator struct {
+	provider *goose.Provider
+}
+
+func New(db *sql.DB) (*Migrator, error) {
+	locker, err := lock.NewPostgresSessionLocker()
+	if err != nil {
+		return nil, err
+	}
+	provider, err := goose.NewProvider(
+		goose.DialectPostgres,
+		db,
+		schema.FS,
+		goose.WithSessionLocker(locker),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{provider: provider}, nil
+}
+
+// Latest is the newest embedded migration, the version this binary expects.
+func (m *Migrator) Latest() int64 {
+	sources := m.provider.ListSources()
+	return sources[len(sources)-1].Version
+}
+
+func (m *Migrator) Up(ctx context.Context) ([]*goose.MigrationResult, error) {
+	return m.provider.Up(ctx)
+}
+
+// Down rolls back the most recent migration.
+func (m *Migrator) Down(ctx context.Context) (*goose.MigrationResult, error) {
+	return m.provider.Down(ctx)
+}
+
+func (m *Migrator) Status(ctx context.Context) ([]*goose.MigrationStatus, error) {
+	return m.provider.Status(ctx)
+}
+
+func (m *Migrator) Version(ctx context.Context) (int64, error) {
+	return m.provider.GetDBVersion(ctx)
+}
+
+// CheckCurrent returns ErrSchemaBehind if there are migrations left to apply.
+func (m *Migrator) CheckCurrent(ctx context.Context) error {
+	current, err := m.Version(ctx)
+	if err != nil {
+		return err
+	}
+	if latest := m.Latest(); current < latest {
+		return fmt.Errorf("%w: at version %d, want %d", ErrSchemaBehind, current, latest)
+	}
+	return nil
+}
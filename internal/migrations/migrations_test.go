@@ -0,0 +1,39 @@
+package migrations
+
+import (
+	"io/fs"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gskll/chirpy2/sql/schema"
+)
+
+func TestEmbeddedMigrations(t *testing.T) {
+	names, err := fs.Glob(schema.FS, "*.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) == 0 {
+		t.Fatal("Expected embedded migrations, got none")
+	}
+
+	for i, name := range names {
+		version, err := strconv.Atoi(strings.SplitN(name, "_", 2)[0])
+		if err != nil {
+			t.Errorf("Expected %s to start with a version number", name)
+			continue
+		}
+		if version != i+1 {
+			t.Errorf("Expected %s to be version %d, got %d", name, i+1, version)
+		}
+
+		data, err := fs.ReadFile(schema.FS, name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(data), "-- +goose Up") || !strings.Contains(string(data), "-- +goose Down") {
+			t.Errorf("Expected %s to have goose Up and Down sections", name)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package moderation
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/gskll/chirpy2/internal/database"
+)
+
+// Report categories, keep in sync with the reports.category check constraint.
+const (
+	Spam          = "spam"
+	Harassment    = "harassment"
+	Hate          = "hate"
+	Violence      = "violence"
+	Sexual        = "sexual"
+	SelfHarm      = "self_harm"
+	Impersonation = "impersonation"
+	Other         = "other"
+	// Filter reports are raised by the content filter, not by users.
+	Filter = "filter"
+)
+
+var categories = map[string]bool{
+	Spam: true, Harassment: true, Hate: true, Violence: true,
+	Sexual: true, SelfHarm: true, Impersonation: true, Other: true,
+}
+
+// ValidCategory reports whether users can report with category, Filter is
+// reserved for the content filter.
+func ValidCategory(category string) bool {
+	return categories[category]
+}
+
+// Report states. Reports start open and are resolved once, as actioned when a
+// moderator took action or dismissed when they didn't.
+const (
+	StatusOpen      = "open"
+	StatusActioned  = "actioned"
+	StatusDismissed = "dismissed"
+)
+
+func ValidStatus(status string) bool {
+	return status == StatusOpen || status == StatusActioned || status == StatusDismissed
+}
+
+// ValidResolution reports whether a report can be resolved to status.
+func ValidResolution(status string) bool {
+	return status == StatusActioned || status == StatusDismissed
+}
+
+// MaxCommentLength limits the free text a reporter can add, in bytes.
+const MaxCommentLength = 1000
+
+type Report struct {
+	ID             uuid.UUID  `json:"id"`
+	ReporterId     *uuid.UUID `json:"reporter_id"`
+	ReportedUserId uuid.UUID  `json:"reported_user_id"`
+	ChirpId        *uuid.UUID `json:"chirp_id"`
+	Category       string     `json:"category"`
+	Comment        string     `json:"comment"`
+	Status         string     `json:"status"`
+	ResolvedBy     *uuid.UUID `json:"resolved_by,omitempty"`
+	ResolvedAt     *time.Time `json:"resolved_at,omitempty"`
+	ResolutionNote string     `json:"resolution_note,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
+
+func NewReport(dbReport database.Report) Report {
+	r := Report{
+		ID:             dbReport.ID,
+		ReportedUserId: dbReport.ReportedUserID,
+		Category:       dbReport.Category,
+		Comment:        dbReport.Comment,
+		Status:         dbReport.Status,
+		ResolutionNote: dbReport.ResolutionNote.String,
+		CreatedAt:      dbReport.CreatedAt,
+		UpdatedAt:      dbReport.UpdatedAt,
+	}
+	if dbReport.ReporterID.Valid {
+		r.ReporterId = &dbReport.ReporterID.UUID
+	}
+	if dbReport.ChirpID.Valid {
+		r.ChirpId = &dbReport.ChirpID.UUID
+	}
+	if dbReport.ResolvedBy.Valid {
+		r.ResolvedBy = &dbReport.ResolvedBy.UUID
+	}
+	if dbReport.ResolvedAt.Valid {
+		r.ResolvedAt = &dbReport.ResolvedAt.Time
+	}
+	return r
+}
+
+// QueueItem is a report in the moderator queue. ReportCount is how many
+// reports in the same queue are about the same chirp, or the same user for
+// reports that aren't about a chirp.
+type QueueItem struct {
+	Report
+	ChirpBody   *string `json:"chirp_body,omitempty"`
+	ReportCount int64   `json:"report_count"`
+}
+
+func NewQueueItem(row database.ListReportsRow) QueueItem {
+	item := QueueItem{
+		Report: NewReport(database.Report{
+			ID:             row.ID,
+			ReporterID:     row.ReporterID,
+			ReportedUserID: row.ReportedUserID,
+			ChirpID:        row.ChirpID,
+			Category:       row.Category,
+			Comment:        row.Comment,
+			Status:         row.Status,
+			ResolvedBy:     row.ResolvedBy,
+			ResolvedAt:     row.ResolvedAt,
+			ResolutionNote: row.ResolutionNote,
+			CreatedAt:      row.CreatedAt,
+			UpdatedAt:      row.UpdatedAt,
+		}),
+		ReportCount: row.ReportCount,
+	}
+	if row.ChirpBody.Valid {
+		item.ChirpBody = &row.ChirpBody.String
+	}
+	return item
+}
@@ -0,0 +1,97 @@
+package moderation
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/gskll/chirpy2/internal/database"
+)
+
+func TestValidResolution(t *testing.T) {
+	tests := []struct {
+		status string
+		want   bool
+	}{
+		{StatusActioned, true},
+		{StatusDismissed, true},
+		{StatusOpen, false},
+		{"closed", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.status, func(t *testing.T) {
+			if got := ValidResolution(tt.status); got != tt.want {
+				t.Errorf("ValidResolution(%q) = %v, want %v", tt.status, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidCategory(t *testing.T) {
+	tests := []struct {
+		category string
+		want     bool
+	}{
+		{Spam, true},
+		{SelfHarm, true},
+		{"Spam", false},
+		{"", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.category, func(t *testing.T) {
+			if got := ValidCategory(tt.category); got != tt.want {
+				t.Errorf("ValidCategory(%q) = %v, want %v", tt.category, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewQueueItem(t *testing.T) {
+	chirpID := uuid.New()
+	resolvedAt := time.Now()
+
+	tests := []struct {
+		name         string
+		row          database.ListReportsRow
+		wantChirp    bool
+		wantResolved bool
+	}{
+		{
+			name: "Open chirp report",
+			row: database.ListReportsRow{
+				ChirpID:     uuid.NullUUID{UUID: chirpID, Valid: true},
+				Status:      StatusOpen,
+				ChirpBody:   sql.NullString{String: "buy followers", Valid: true},
+				ReportCount: 3,
+			},
+			wantChirp: true,
+		},
+		{
+			name: "Resolved user report",
+			row: database.ListReportsRow{
+				Status:     StatusDismissed,
+				ResolvedAt: sql.NullTime{Time: resolvedAt, Valid: true},
+			},
+			wantResolved: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			item := NewQueueItem(tt.row)
+			if (item.ChirpId != nil) != tt.wantChirp || (item.ChirpBody != nil) != tt.wantChirp {
+				t.Errorf("Expected chirp %v, got id %v body %v", tt.wantChirp, item.ChirpId, item.ChirpBody)
+			}
+			if (item.ResolvedAt != nil) != tt.wantResolved {
+				t.Errorf("Expected resolved %v, got %v", tt.wantResolved, item.ResolvedAt)
+			}
+			if item.ReportCount != tt.row.ReportCount {
+				t.Errorf("Expected report count %d, got %d", tt.row.ReportCount, item.ReportCount)
+			}
+		})
+	}
+}
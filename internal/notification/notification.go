@@ -0,0 +1,99 @@
+package notification
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/gskll/chirpy2/internal/database"
+)
+
+const (
+	Like          = "like"
+	Reply         = "reply"
+	Follow        = "follow"
+	FollowRequest = "follow_request"
+	Mention       = "mention"
+)
+
+var types = map[string]bool{Like: true, Reply: true, Follow: true, FollowRequest: true, Mention: true}
+
+func ValidType(t string) bool {
+	return types[t]
+}
+
+type Notification struct {
+	ID        uuid.UUID  `json:"id"`
+	Type      string     `json:"type"`
+	ActorId   uuid.UUID  `json:"actor_id"`
+	ChirpId   *uuid.UUID `json:"chirp_id,omitempty"`
+	ReadAt    *time.Time `json:"read_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+func NewNotification(dbNotification database.Notification) Notification {
+	n := Notification{
+		ID:        dbNotification.ID,
+		Type:      dbNotification.Type,
+		ActorId:   dbNotification.ActorID,
+		CreatedAt: dbNotification.CreatedAt,
+	}
+	if dbNotification.ChirpID.Valid {
+		n.ChirpId = &dbNotification.ChirpID.UUID
+	}
+	if dbNotification.ReadAt.Valid {
+		n.ReadAt = &dbNotification.ReadAt.Time
+	}
+	return n
+}
+
+// Group collapses similar notifications from the same day, e.g. every like
+// on the same chirp. ID is the most recent notification in the group.
+type Group struct {
+	ID       uuid.UUID   `json:"id"`
+	Type     string      `json:"type"`
+	ChirpId  *uuid.UUID  `json:"chirp_id,omitempty"`
+	ActorIds []uuid.UUID `json:"actor_ids"`
+	Count    int64       `json:"count"`
+	Unread   bool        `json:"unread"`
+	Summary  string      `json:"summary"`
+	LatestAt time.Time   `json:"latest_at"`
+}
+
+func NewGroup(row database.GetNotificationGroupsRow) Group {
+	g := Group{
+		ID:       row.ID,
+		Type:     row.Type,
+		ActorIds: row.ActorIds,
+		Count:    row.Count,
+		Unread:   row.Unread,
+		Summary:  Summary(row.Type, len(row.ActorIds)),
+		LatestAt: row.LatestAt,
+	}
+	if row.ChirpID.Valid {
+		g.ChirpId = &row.ChirpID.UUID
+	}
+	return g
+}
+
+func Summary(notificationType string, actors int) string {
+	who := "Someone"
+	if actors > 1 {
+		who = fmt.Sprintf("%d people", actors)
+	}
+
+	switch notificationType {
+	case Like:
+		return who + " liked your chirp"
+	case Reply:
+		return who + " replied to your chirp"
+	case Follow:
+		return who + " followed you"
+	case FollowRequest:
+		return who + " requested to follow you"
+	case Mention:
+		return who + " mentioned you"
+	}
+	return who + " interacted with you"
+}
@@ -0,0 +1,29 @@
+package notification
+
+import "testing"
+
+func TestSummary(t *testing.T) {
+	tests := []struct {
+		name             string
+		notificationType string
+		actors           int
+		want             string
+	}{
+		{"Single like", Like, 1, "Someone liked your chirp"},
+		{"Grouped likes", Like, 3, "3 people liked your chirp"},
+		{"Grouped replies", Reply, 3, "3 people replied to your chirp"},
+		{"Grouped follows", Follow, 2, "2 people followed you"},
+		{"Reply", Reply, 1, "Someone replied to your chirp"},
+		{"Follow request", FollowRequest, 1, "Someone requested to follow you"},
+		{"Mention", Mention, 1, "Someone mentioned you"},
+		{"Unknown type", "poke", 1, "Someone interacted with you"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Summary(tt.notificationType, tt.actors); got != tt.want {
+				t.Errorf("Summary() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,50 @@
+package ratelimit
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/gskll/chirpy2/internal/database"
+)
+
+// PostgresStore shares buckets between instances through the
+// rate_limit_buckets table.
+type PostgresStore struct {
+	db *database.Queries
+}
+
+func NewPostgresStore(db *database.Queries) *PostgresStore {
+	return &PostgresStore{db: db}
+}
+
+func (s *PostgresStore) Take(ctx context.Context, key string, limit Limit) (Result, error) {
+	row, err := s.db.TakeRateLimitToken(ctx, database.TakeRateLimitTokenParams{
+		Key:   key,
+		Burst: float64(limit.Requests),
+		Rate:  limit.rate(),
+	})
+	if err != nil {
+		return Result{}, err
+	}
+	return newResult(limit, row.Tokens, row.Allowed), nil
+}
+
+// Cleanup deletes buckets idle for longer than maxIdle every interval until
+// ctx is cancelled.
+func (s *PostgresStore) Cleanup(ctx context.Context, interval, maxIdle time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			err := s.db.DeleteStaleRateLimitBuckets(ctx, maxIdle.Seconds())
+			if err != nil && ctx.Err() == nil {
+				slog.Error("delete stale rate limit buckets", "error", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"time"
+)
+
+// Limit is a token bucket holding Requests tokens that refills completely
+// over Per.
+type Limit struct {
+	Requests int
+	Per      time.Duration
+}
+
+func (l Limit) rate() float64 {
+	return float64(l.Requests) / l.Per.Seconds()
+}
+
+type Result struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	RetryAfter time.Duration
+	Reset      time.Duration
+}
+
+// Store takes a token from the bucket identified by key.
+type Store interface {
+	Take(ctx context.Context, key string, limit Limit) (Result, error)
+}
+
+func newResult(limit Limit, tokens float64, allowed bool) Result {
+	rate := limit.rate()
+	res := Result{
+		Allowed:   allowed,
+		Limit:     limit.Requests,
+		Remaining: int(math.Floor(math.Max(tokens, 0))),
+		Reset:     time.Duration((float64(limit.Requests) - tokens) / rate * float64(time.Second)),
+	}
+	if !allowed {
+		res.RetryAfter = time.Duration((1 - tokens) / rate * float64(time.Second))
+	}
+	return res
+}
+
+type group struct {
+	name  string
+	limit Limit
+}
+
+func (g group) ServeHTTP(http.ResponseWriter, *http.Request) {}
+
+// Limiter assigns requests to named route groups, each with its own limit.
+// Groups are matched with ServeMux patterns so they follow the same rules as
+// the routes themselves.
+type Limiter struct {
+	Store  Store
+	groups *http.ServeMux
+}
+
+func NewLimiter(store Store) *Limiter {
+	return &Limiter{Store: store, groups: http.NewServeMux()}
+}
+
+func (l *Limiter) AddGroup(name string, limit Limit, patterns ...string) {
+	for _, pattern := range patterns {
+		l.groups.Handle(pattern, group{name: name, limit: limit})
+	}
+}
+
+// Group returns the group matching r, if any.
+func (l *Limiter) Group(r *http.Request) (name string, limit Limit, ok bool) {
+	h, pattern := l.groups.Handler(r)
+	g, isGroup := h.(group)
+	if pattern == "" || !isGroup {
+		return "", Limit{}, false
+	}
+	return g.name, g.limit, true
+}
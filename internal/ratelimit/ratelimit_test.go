@@ -0,0 +1,93 @@
+package ratelimit
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestMemoryStoreTake(t *testing.T) {
+	now := time.Date(2024, 10, 11, 15, 0, 0, 0, time.UTC)
+	store := NewMemoryStore()
+	store.now = func() time.Time { return now }
+
+	limit := Limit{Requests: 3, Per: 3 * time.Second}
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		res, err := store.Take(ctx, "key", limit)
+		if err != nil {
+			t.Fatalf("Take() returned an error: %v", err)
+		}
+		if !res.Allowed {
+			t.Fatalf("Request %d should be allowed", i)
+		}
+		if res.Remaining != 2-i {
+			t.Errorf("Expected %d remaining, got %d", 2-i, res.Remaining)
+		}
+	}
+
+	res, _ := store.Take(ctx, "key", limit)
+	if res.Allowed {
+		t.Fatal("Request over the limit should be denied")
+	}
+	if res.RetryAfter != time.Second {
+		t.Errorf("Expected Retry-After of 1s, got %v", res.RetryAfter)
+	}
+
+	if res, _ := store.Take(ctx, "other", limit); !res.Allowed {
+		t.Error("Buckets should be independent per key")
+	}
+
+	now = now.Add(time.Second)
+	if res, _ := store.Take(ctx, "key", limit); !res.Allowed {
+		t.Error("Bucket should refill over time")
+	}
+}
+
+func TestMemoryStoreSweep(t *testing.T) {
+	now := time.Now()
+	store := NewMemoryStore()
+	store.now = func() time.Time { return now }
+
+	limit := Limit{Requests: 1, Per: time.Second}
+	store.Take(context.Background(), "idle", limit)
+
+	now = now.Add(sweepInterval)
+	store.Take(context.Background(), "active", limit)
+
+	if _, ok := store.buckets["idle"]; ok {
+		t.Error("Refilled bucket should have been swept")
+	}
+	if _, ok := store.buckets["active"]; !ok {
+		t.Error("Active bucket should be kept")
+	}
+}
+
+func TestLimiterGroup(t *testing.T) {
+	limiter := NewLimiter(NewMemoryStore())
+	limiter.AddGroup("auth", Limit{Requests: 10, Per: time.Minute}, "POST /api/login")
+	limiter.AddGroup("api", Limit{Requests: 300, Per: time.Minute}, "/api/")
+
+	tests := []struct {
+		method, path string
+		wantGroup    string
+		wantOK       bool
+	}{
+		{"POST", "/api/login", "auth", true},
+		{"GET", "/api/login", "api", true},
+		{"GET", "/api/chirps", "api", true},
+		{"GET", "/app/index.html", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, tt.path, nil)
+			group, _, ok := limiter.Group(r)
+			if group != tt.wantGroup || ok != tt.wantOK {
+				t.Errorf("Group() = (%q, %v), want (%q, %v)", group, ok, tt.wantGroup, tt.wantOK)
+			}
+		})
+	}
+}
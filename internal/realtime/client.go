@@ -0,0 +1,303 @@
+package realtime
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+
+	"github.com/gskll/chirpy2/internal/events"
+)
+
+const (
+	writeWait      = 10 * time.Second
+	pongWait       = 60 * time.Second
+	pingPeriod     = (pongWait * 9) / 10
+	maxMessageSize = 4096
+	sendBufferSize = 64
+)
+
+// ValidateFunc checks an access token and returns its user and expiry.
+type ValidateFunc func(token string) (uuid.UUID, time.Time, error)
+
+// Relations are the other users that change what a client receives.
+type Relations struct {
+	// Hidden are left out of the timeline, because of a block either way or a
+	// mute.
+	Hidden []uuid.UUID
+	// Following can be received when they only publish to their followers.
+	Following []uuid.UUID
+}
+
+// RelationsFunc loads the relations of the client's user.
+type RelationsFunc func() (Relations, error)
+
+// Client is a single authenticated websocket connection. Events are queued on
+// a per-connection send buffer; a client that lets the buffer fill up is
+// disconnected instead of stalling publishers.
+type Client struct {
+	conn          *websocket.Conn
+	logger        *slog.Logger
+	broker        events.Broker
+	validate      ValidateFunc
+	loadRelations RelationsFunc
+	userID        uuid.UUID
+
+	send      chan []byte
+	done      chan struct{}
+	closeOnce sync.Once
+
+	mu        sync.Mutex
+	topics    map[string]bool
+	expiresAt time.Time
+	reauth    chan struct{}
+
+	// only used by eventPump
+	hidden    map[uuid.UUID]bool
+	following map[uuid.UUID]bool
+}
+
+// NewClient creates a client for userID. relations may be nil, the client's
+// timeline then only holds its own chirps.
+func NewClient(conn *websocket.Conn, logger *slog.Logger, broker events.Broker, validate ValidateFunc, relations RelationsFunc, userID uuid.UUID, expiresAt time.Time) *Client {
+	return &Client{
+		conn:          conn,
+		logger:        logger,
+		broker:        broker,
+		validate:      validate,
+		loadRelations: relations,
+		userID:        userID,
+		send:          make(chan []byte, sendBufferSize),
+		done:          make(chan struct{}),
+		topics:        make(map[string]bool),
+		expiresAt:     expiresAt,
+		reauth:        make(chan struct{}, 1),
+	}
+}
+
+// Serve runs the connection until the client disconnects, falls behind, its
+// token expires without being refreshed or shutdown is closed.
+func (c *Client) Serve(shutdown <-chan struct{}) {
+	sub := c.broker.Subscribe(0)
+	defer sub.Close()
+
+	go c.writePump(shutdown)
+	go c.eventPump(sub)
+	c.readPump()
+}
+
+func (c *Client) readPump() {
+	defer c.shutdown(websocket.CloseNormalClosure, "")
+
+	c.conn.SetReadLimit(maxMessageSize)
+	c.conn.SetReadDeadline(time.Now().Add(pongWait))
+	c.conn.SetPongHandler(func(string) error {
+		return c.conn.SetReadDeadline(time.Now().Add(pongWait))
+	})
+
+	for {
+		var msg clientMessage
+		if err := c.conn.ReadJSON(&msg); err != nil {
+			var syntaxErr *json.SyntaxError
+			var typeErr *json.UnmarshalTypeError
+			if errors.As(err, &syntaxErr) || errors.As(err, &typeErr) {
+				c.enqueue(errorMessage("invalid message"))
+				continue
+			}
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				c.logger.Warn("websocket read", "error", err)
+			}
+			return
+		}
+		c.handle(msg)
+	}
+}
+
+func (c *Client) handle(msg clientMessage) {
+	switch msg.Type {
+	case "subscribe", "unsubscribe":
+		if !validTopics[msg.Topic] {
+			c.enqueue(errorMessage("unknown topic"))
+			return
+		}
+		c.mu.Lock()
+		c.topics[msg.Topic] = msg.Type == "subscribe"
+		c.mu.Unlock()
+		c.enqueue(serverMessage{Type: msg.Type + "d", Topic: msg.Topic})
+	case "auth":
+		userID, expiresAt, err := c.validate(msg.Token)
+		if err != nil {
+			c.enqueue(errorMessage(err.Error()))
+			return
+		}
+		if userID != c.userID {
+			c.enqueue(errorMessage("token belongs to a different user"))
+			return
+		}
+		c.mu.Lock()
+		c.expiresAt = expiresAt
+		c.mu.Unlock()
+		select {
+		case c.reauth <- struct{}{}:
+		default:
+		}
+		c.enqueue(serverMessage{Type: "authenticated"})
+	default:
+		c.enqueue(errorMessage("unknown message type"))
+	}
+}
+
+func (c *Client) eventPump(sub *events.Subscription) {
+	c.reloadRelations()
+	for {
+		select {
+		case <-c.done:
+			return
+		case event, ok := <-sub.C:
+			if !ok {
+				c.shutdown(websocket.CloseTryAgainLater, "client too slow")
+				return
+			}
+			if event.Type == events.RelationshipsChanged && event.UserID == c.userID {
+				c.reloadRelations()
+				continue
+			}
+			if event.Type == events.UserSuspended && event.UserID == c.userID {
+				c.shutdown(websocket.ClosePolicyViolation, "account suspended")
+				return
+			}
+			if event.Type == events.UserDeleted && event.UserID == c.userID {
+				c.shutdown(websocket.ClosePolicyViolation, "account deleted")
+				return
+			}
+			topic, ok := topicFor(event, c.userID)
+			if !ok || !c.subscribed(topic) {
+				continue
+			}
+			if topic == TopicTimeline && !c.canReceive(event) {
+				continue
+			}
+			c.enqueue(serverMessage{
+				Type:  "event",
+				Topic: topic,
+				ID:    event.ID,
+				Event: event.Type,
+				Data:  event.Data,
+			})
+		}
+	}
+}
+
+// canReceive checks a timeline event against the client's relations with its
+// author and the event's audience. The timeline only holds the user's own
+// chirps and those of accounts they follow.
+func (c *Client) canReceive(event events.Event) bool {
+	if event.UserID == c.userID {
+		return true
+	}
+	if !c.following[event.UserID] || c.hidden[event.UserID] {
+		return false
+	}
+	audience := event.Audience
+	return audience.Public() || audience.Followers || slices.Contains(audience.UserIDs, c.userID)
+}
+
+// reloadRelations keeps the previous relations if loading fails, none on the
+// first load.
+func (c *Client) reloadRelations() {
+	if c.loadRelations == nil {
+		return
+	}
+	relations, err := c.loadRelations()
+	if err != nil {
+		c.logger.Error("websocket load relations", "error", err)
+		return
+	}
+	c.hidden = make(map[uuid.UUID]bool, len(relations.Hidden))
+	for _, id := range relations.Hidden {
+		c.hidden[id] = true
+	}
+	c.following = make(map[uuid.UUID]bool, len(relations.Following))
+	for _, id := range relations.Following {
+		c.following[id] = true
+	}
+}
+
+func (c *Client) writePump(shutdown <-chan struct{}) {
+	ping := time.NewTicker(pingPeriod)
+	defer ping.Stop()
+
+	expiry := time.NewTimer(time.Until(c.tokenExpiry()))
+	defer expiry.Stop()
+
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-shutdown:
+			c.shutdown(websocket.CloseGoingAway, "server shutting down")
+			return
+		case msg := <-c.send:
+			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := c.conn.WriteMessage(websocket.TextMessage, msg); err != nil {
+				c.shutdown(websocket.CloseInternalServerErr, "")
+				return
+			}
+		case <-ping.C:
+			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				c.shutdown(websocket.CloseInternalServerErr, "")
+				return
+			}
+		case <-c.reauth:
+			expiry.Reset(time.Until(c.tokenExpiry()))
+		case <-expiry.C:
+			c.shutdown(websocket.ClosePolicyViolation, "token expired")
+			return
+		}
+	}
+}
+
+// enqueue never blocks: if the send buffer is full the client is dropped.
+func (c *Client) enqueue(msg serverMessage) {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		c.logger.Error("websocket marshal", "error", err)
+		return
+	}
+	select {
+	case c.send <- data:
+	case <-c.done:
+	default:
+		c.shutdown(websocket.CloseTryAgainLater, "client too slow")
+	}
+}
+
+func (c *Client) shutdown(code int, reason string) {
+	c.closeOnce.Do(func() {
+		close(c.done)
+		c.conn.WriteControl(
+			websocket.CloseMessage,
+			websocket.FormatCloseMessage(code, reason),
+			time.Now().Add(writeWait),
+		)
+		c.conn.Close()
+	})
+}
+
+func (c *Client) subscribed(topic string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.topics[topic]
+}
+
+func (c *Client) tokenExpiry() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.expiresAt
+}
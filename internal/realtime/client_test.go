@@ -0,0 +1,286 @@
+package realtime
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+
+	"github.com/gskll/chirpy2/internal/events"
+)
+
+func TestTopicFor(t *testing.T) {
+	me := uuid.New()
+	other := uuid.New()
+
+	tests := []struct {
+		name      string
+		event     events.Event
+		wantTopic string
+		wantOK    bool
+	}{
+		{"Chirp created by anyone", events.Event{Type: events.ChirpCreated, UserID: other}, TopicTimeline, true},
+		{"Chirp deleted", events.Event{Type: events.ChirpDeleted, UserID: me}, TopicTimeline, true},
+		{"Mention of me", events.Event{Type: events.ChirpMentioned, UserID: me}, TopicMentions, true},
+		{"Mention of someone else", events.Event{Type: events.ChirpMentioned, UserID: other}, "", false},
+		{"Notification for me", events.Event{Type: "notification.created", UserID: me}, TopicNotifications, true},
+		{"Notification for someone else", events.Event{Type: "notification.created", UserID: other}, "", false},
+		{"Message to me", events.Event{Type: events.MessageCreated, UserID: other, Audience: events.Audience{Restricted: true, UserIDs: []uuid.UUID{me}}}, TopicMessages, true},
+		{"Message I sent", events.Event{Type: events.MessageCreated, UserID: me, Audience: events.Audience{Restricted: true, UserIDs: []uuid.UUID{other}}}, TopicMessages, true},
+		{"Message to someone else", events.Event{Type: events.MessageCreated, UserID: other, Audience: events.Audience{Restricted: true, UserIDs: []uuid.UUID{uuid.New()}}}, "", false},
+		{"Unknown event", events.Event{Type: "user.upgraded", UserID: me}, "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			topic, ok := topicFor(tt.event, me)
+			if topic != tt.wantTopic || ok != tt.wantOK {
+				t.Errorf("topicFor() = (%q, %v), want (%q, %v)", topic, ok, tt.wantTopic, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestClientSubscribeAndReceive(t *testing.T) {
+	broker := events.NewMemoryBroker(10)
+	userID := uuid.New()
+	validate := func(token string) (uuid.UUID, time.Time, error) {
+		return userID, time.Now().Add(time.Hour), nil
+	}
+
+	upgrader := websocket.Upgrader{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Errorf("Upgrade failed: %v", err)
+			return
+		}
+		NewClient(conn, slog.Default(), broker, validate, nil, userID, time.Now().Add(time.Hour)).Serve(nil)
+	}))
+	defer srv.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	if err := conn.WriteJSON(clientMessage{Type: "subscribe", Topic: TopicTimeline}); err != nil {
+		t.Fatalf("WriteJSON failed: %v", err)
+	}
+	var ack serverMessage
+	if err := conn.ReadJSON(&ack); err != nil {
+		t.Fatalf("ReadJSON failed: %v", err)
+	}
+	if ack.Type != "subscribed" || ack.Topic != TopicTimeline {
+		t.Fatalf("Unexpected ack: %+v", ack)
+	}
+
+	broker.Publish(context.Background(), events.ChirpCreated, userID, map[string]string{"body": "hi"})
+
+	var msg serverMessage
+	if err := conn.ReadJSON(&msg); err != nil {
+		t.Fatalf("ReadJSON failed: %v", err)
+	}
+	if msg.Type != "event" || msg.Event != events.ChirpCreated || string(msg.Data) != `{"body":"hi"}` {
+		t.Errorf("Unexpected event message: %+v", msg)
+	}
+}
+
+func TestClientRelations(t *testing.T) {
+	broker := events.NewMemoryBroker(10)
+	userID := uuid.New()
+	blocked := uuid.New()
+	muted := uuid.New()
+	followed := uuid.New()
+	validate := func(token string) (uuid.UUID, time.Time, error) {
+		return userID, time.Now().Add(time.Hour), nil
+	}
+	loads := make(chan struct{}, 2)
+	relations := Relations{Hidden: []uuid.UUID{blocked}, Following: []uuid.UUID{followed, muted}}
+	loadRelations := func() (Relations, error) {
+		defer func() { loads <- struct{}{} }()
+		return relations, nil
+	}
+
+	upgrader := websocket.Upgrader{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Errorf("Upgrade failed: %v", err)
+			return
+		}
+		NewClient(conn, slog.Default(), broker, validate, loadRelations, userID, time.Now().Add(time.Hour)).Serve(nil)
+	}))
+	defer srv.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	if err := conn.WriteJSON(clientMessage{Type: "subscribe", Topic: TopicTimeline}); err != nil {
+		t.Fatalf("WriteJSON failed: %v", err)
+	}
+	var ack serverMessage
+	if err := conn.ReadJSON(&ack); err != nil {
+		t.Fatalf("ReadJSON failed: %v", err)
+	}
+	<-loads
+
+	ctx := context.Background()
+	followers := events.Audience{Restricted: true, Followers: true}
+	mentioned := events.Audience{Restricted: true, UserIDs: []uuid.UUID{userID}}
+	broker.Publish(ctx, events.ChirpCreated, blocked, map[string]string{"body": "blocked"})
+	broker.Publish(ctx, events.ChirpCreated, muted, map[string]string{"body": "before mute"})
+	broker.PublishTo(ctx, events.ChirpCreated, followed, followers, map[string]string{"body": "followed"})
+	broker.PublishTo(ctx, events.ChirpCreated, uuid.New(), followers, map[string]string{"body": "not followed"})
+	broker.PublishTo(ctx, events.ChirpCreated, userID, followers, map[string]string{"body": "mine"})
+	broker.PublishTo(ctx, events.ChirpCreated, followed, mentioned, map[string]string{"body": "mentioned"})
+	broker.PublishTo(ctx, events.ChirpCreated, uuid.New(), mentioned, map[string]string{"body": "mentioned by a stranger"})
+	broker.Publish(ctx, events.ChirpCreated, uuid.New(), map[string]string{"body": "stranger"})
+	broker.PublishTo(ctx, events.ChirpCreated, uuid.New(), events.Audience{Restricted: true}, map[string]string{"body": "unlisted"})
+	relations = Relations{Hidden: []uuid.UUID{blocked, muted}}
+	broker.Publish(ctx, events.RelationshipsChanged, userID, nil)
+	<-loads
+	broker.Publish(ctx, events.ChirpCreated, muted, map[string]string{"body": "after mute"})
+	broker.PublishTo(ctx, events.ChirpCreated, followed, followers, map[string]string{"body": "after unfollow"})
+	broker.Publish(ctx, events.ChirpCreated, userID, map[string]string{"body": "mine again"})
+
+	for _, want := range []string{`{"body":"before mute"}`, `{"body":"followed"}`, `{"body":"mine"}`, `{"body":"mentioned"}`, `{"body":"mine again"}`} {
+		var msg serverMessage
+		if err := conn.ReadJSON(&msg); err != nil {
+			t.Fatalf("ReadJSON failed: %v", err)
+		}
+		if string(msg.Data) != want {
+			t.Errorf("Expected %s, got %s", want, msg.Data)
+		}
+	}
+}
+
+func TestClientClosedForAccount(t *testing.T) {
+	tests := []struct {
+		event  string
+		reason string
+	}{
+		{events.UserSuspended, "account suspended"},
+		{events.UserDeleted, "account deleted"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.event, func(t *testing.T) {
+			testClientClosed(t, tt.event, tt.reason)
+		})
+	}
+}
+
+func testClientClosed(t *testing.T, eventType, reason string) {
+	broker := events.NewMemoryBroker(10)
+	userID := uuid.New()
+	validate := func(token string) (uuid.UUID, time.Time, error) {
+		return userID, time.Now().Add(time.Hour), nil
+	}
+
+	upgrader := websocket.Upgrader{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Errorf("Upgrade failed: %v", err)
+			return
+		}
+		NewClient(conn, slog.Default(), broker, validate, nil, userID, time.Now().Add(time.Hour)).Serve(nil)
+	}))
+	defer srv.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	if err := conn.WriteJSON(clientMessage{Type: "subscribe", Topic: TopicNotifications}); err != nil {
+		t.Fatalf("WriteJSON failed: %v", err)
+	}
+	var ack serverMessage
+	if err := conn.ReadJSON(&ack); err != nil {
+		t.Fatalf("ReadJSON failed: %v", err)
+	}
+
+	ctx := context.Background()
+	restricted := events.Audience{Restricted: true}
+	broker.PublishTo(ctx, eventType, uuid.New(), restricted, nil)
+	broker.Publish(ctx, events.NotificationCreated, userID, map[string]string{"type": "follow"})
+
+	// someone else's account leaves the connection open
+	var msg serverMessage
+	if err := conn.ReadJSON(&msg); err != nil {
+		t.Fatalf("ReadJSON failed: %v", err)
+	}
+	if msg.Event != events.NotificationCreated {
+		t.Errorf("Expected %s, got %+v", events.NotificationCreated, msg)
+	}
+
+	broker.PublishTo(ctx, eventType, userID, restricted, nil)
+	err = conn.ReadJSON(&msg)
+	var closeErr *websocket.CloseError
+	if !errors.As(err, &closeErr) || closeErr.Code != websocket.ClosePolicyViolation || closeErr.Text != reason {
+		t.Errorf("Expected a policy violation close with %q, got %v", reason, err)
+	}
+}
+
+func TestCanReceive(t *testing.T) {
+	me := uuid.New()
+	followed := uuid.New()
+	stranger := uuid.New()
+	blocked := uuid.New()
+	c := &Client{
+		userID:    me,
+		hidden:    map[uuid.UUID]bool{blocked: true},
+		following: map[uuid.UUID]bool{followed: true, blocked: true},
+	}
+
+	public := events.Audience{}
+	followers := events.Audience{Restricted: true, Followers: true}
+	mentioned := events.Audience{Restricted: true, UserIDs: []uuid.UUID{me}}
+	mentionedOther := events.Audience{Restricted: true, UserIDs: []uuid.UUID{stranger}}
+	unlisted := events.Audience{Restricted: true}
+
+	tests := []struct {
+		name     string
+		author   uuid.UUID
+		audience events.Audience
+		want     bool
+	}{
+		{"Mine, unlisted", me, unlisted, true},
+		{"Followed, public", followed, public, true},
+		{"Followed, followers", followed, followers, true},
+		{"Followed, mentions me", followed, mentioned, true},
+		{"Followed, mentions someone else", followed, mentionedOther, false},
+		{"Followed, unlisted", followed, unlisted, false},
+		{"Stranger, public", stranger, public, false},
+		{"Stranger, followers", stranger, followers, false},
+		{"Stranger, mentions me", stranger, mentioned, false},
+		{"Blocked, public", blocked, public, false},
+		{"Blocked, mentions me", blocked, mentioned, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			event := events.Event{Type: events.ChirpCreated, UserID: tt.author, Audience: tt.audience}
+			if got := c.canReceive(event); got != tt.want {
+				t.Errorf("Expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,60 @@
+package realtime
+
+import (
+	"encoding/json"
+	"slices"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"github.com/gskll/chirpy2/internal/events"
+)
+
+const (
+	TopicTimeline      = "timeline"
+	TopicMentions      = "mentions"
+	TopicNotifications = "notifications"
+	TopicMessages      = "messages"
+)
+
+var validTopics = map[string]bool{
+	TopicTimeline:      true,
+	TopicMentions:      true,
+	TopicNotifications: true,
+	TopicMessages:      true,
+}
+
+type clientMessage struct {
+	Type  string `json:"type"`
+	Topic string `json:"topic,omitempty"`
+	Token string `json:"token,omitempty"`
+}
+
+type serverMessage struct {
+	Type  string          `json:"type"`
+	Topic string          `json:"topic,omitempty"`
+	ID    uint64          `json:"id,omitempty"`
+	Event string          `json:"event,omitempty"`
+	Data  json.RawMessage `json:"data,omitempty"`
+	Error string          `json:"error,omitempty"`
+}
+
+func errorMessage(msg string) serverMessage {
+	return serverMessage{Type: "error", Error: msg}
+}
+
+// topicFor maps a broker event to the topic it is delivered on for userID.
+func topicFor(event events.Event, userID uuid.UUID) (string, bool) {
+	switch {
+	case event.Type == events.ChirpCreated || event.Type == events.ChirpDeleted:
+		return TopicTimeline, true
+	case event.Type == events.ChirpMentioned && event.UserID == userID:
+		return TopicMentions, true
+	case strings.HasPrefix(event.Type, "notification.") && event.UserID == userID:
+		return TopicNotifications, true
+	case event.Type == events.MessageCreated &&
+		(event.UserID == userID || slices.Contains(event.Audience.UserIDs, userID)):
+		return TopicMessages, true
+	}
+	return "", false
+}
@@ -0,0 +1,44 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+)
+
+var ErrNotFound = errors.New("blob not found")
+
+// Storage holds media blobs under slash separated keys such as
+// "media/<id>/original.png". Local stores them on disk, S3 in a bucket of any
+// S3-compatible service.
+type Storage interface {
+	// Put stores size bytes from r under key, replacing any existing blob.
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	// Open returns ErrNotFound when there's no blob under key.
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete removing a missing blob isn't an error.
+	Delete(ctx context.Context, key string) error
+}
+
+// DeleteAll deletes every key, carrying on past failures, which are returned
+// joined.
+func DeleteAll(ctx context.Context, s Storage, keys ...string) error {
+	var errs []error
+	for _, key := range keys {
+		if err := s.Delete(ctx, key); err != nil {
+			errs = append(errs, fmt.Errorf("delete %s: %w", key, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// validKey rejects keys that could escape the storage root.
+func validKey(key string) error {
+	if key == "" || strings.HasPrefix(key, "/") || path.Clean(key) != key || key == ".." || strings.HasPrefix(key, "../") {
+		return fmt.Errorf("invalid storage key %q", key)
+	}
+	return nil
+}
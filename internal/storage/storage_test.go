@@ -0,0 +1,203 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLocal(t *testing.T) {
+	s, err := NewLocal(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewLocal failed: %v", err)
+	}
+	testStorage(t, s)
+}
+
+func TestS3(t *testing.T) {
+	srv := httptest.NewServer(newFakeS3("media"))
+	defer srv.Close()
+
+	s, err := NewS3(S3Config{
+		Endpoint:  strings.TrimPrefix(srv.URL, "http://"),
+		Bucket:    "media",
+		Region:    "us-east-1",
+		AccessKey: "minioadmin",
+		SecretKey: "minioadmin",
+	})
+	if err != nil {
+		t.Fatalf("NewS3 failed: %v", err)
+	}
+	testStorage(t, s)
+}
+
+func testStorage(t *testing.T, s Storage) {
+	ctx := context.Background()
+	key := "media/1/original.png"
+	data := []byte("not really a png")
+
+	if _, err := s.Open(ctx, key); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Expected ErrNotFound before Put, got %v", err)
+	}
+	if err := s.Put(ctx, key, bytes.NewReader(data), int64(len(data)), "image/png"); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if err := s.Put(ctx, key, bytes.NewReader(data), int64(len(data)), "image/png"); err != nil {
+		t.Fatalf("Put to replace failed: %v", err)
+	}
+
+	rc, err := s.Open(ctx, key)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	got, err := io.ReadAll(rc)
+	rc.Close()
+	if err != nil {
+		t.Fatalf("ReadAll failed: %v", err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Errorf("Expected %q, got %q", data, got)
+	}
+
+	if err := s.Delete(ctx, key); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if _, err := s.Open(ctx, key); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound after Delete, got %v", err)
+	}
+	if err := s.Delete(ctx, key); err != nil {
+		t.Errorf("Expected deleting a missing blob to succeed, got %v", err)
+	}
+
+	for _, bad := range []string{"", "/etc/passwd", "../outside", "media/../../outside"} {
+		if err := s.Put(ctx, bad, bytes.NewReader(data), int64(len(data)), "image/png"); err == nil {
+			t.Errorf("Expected an error for key %q", bad)
+		}
+	}
+}
+
+// fakeS3 is an in-memory stand-in for MinIO, serving path-style object
+// requests for a single bucket without checking signatures.
+type fakeS3 struct {
+	bucket string
+
+	mu      sync.Mutex
+	objects map[string]fakeObject
+}
+
+type fakeObject struct {
+	data        []byte
+	contentType string
+}
+
+func newFakeS3(bucket string) *fakeS3 {
+	return &fakeS3{bucket: bucket, objects: make(map[string]fakeObject)}
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	key, ok := strings.CutPrefix(r.URL.Path, "/"+f.bucket+"/")
+	if !ok || key == "" {
+		f.error(w, http.StatusNotFound, "NoSuchBucket")
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	switch r.Method {
+	case http.MethodPut:
+		data, err := readPayload(r)
+		if err != nil {
+			f.error(w, http.StatusBadRequest, "IncompleteBody")
+			return
+		}
+		f.objects[key] = fakeObject{data: data, contentType: r.Header.Get("Content-Type")}
+		w.Header().Set("ETag", `"`+strconv.Itoa(len(data))+`"`)
+	case http.MethodGet, http.MethodHead:
+		obj, ok := f.objects[key]
+		if !ok {
+			f.error(w, http.StatusNotFound, "NoSuchKey")
+			return
+		}
+		w.Header().Set("Content-Type", obj.contentType)
+		w.Header().Set("Content-Length", strconv.Itoa(len(obj.data)))
+		w.Header().Set("ETag", `"`+strconv.Itoa(len(obj.data))+`"`)
+		w.Header().Set("Last-Modified", time.Now().UTC().Format(http.TimeFormat))
+		if r.Method == http.MethodGet {
+			w.Write(obj.data)
+		}
+	case http.MethodDelete:
+		delete(f.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		f.error(w, http.StatusMethodNotAllowed, "MethodNotAllowed")
+	}
+}
+
+func (f *fakeS3) error(w http.ResponseWriter, status int, code string) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	fmt.Fprintf(w, `<?xml version="1.0" encoding="UTF-8"?><Error><Code>%s</Code><Message>%s</Message></Error>`, code, code)
+}
+
+// readPayload decodes the aws-chunked encoding clients use to sign uploads
+// sent without TLS.
+func readPayload(r *http.Request) ([]byte, error) {
+	if !strings.HasPrefix(r.Header.Get("X-Amz-Content-Sha256"), "STREAMING-") {
+		return io.ReadAll(r.Body)
+	}
+	var data []byte
+	br := bufio.NewReader(r.Body)
+	for {
+		line, err := br.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		sizeHex, _, _ := strings.Cut(strings.TrimSpace(line), ";")
+		size, err := strconv.ParseInt(sizeHex, 16, 64)
+		if err != nil {
+			return nil, err
+		}
+		if size == 0 {
+			return data, nil
+		}
+		chunk := make([]byte, size+2)
+		if _, err := io.ReadFull(br, chunk); err != nil {
+			return nil, err
+		}
+		data = append(data, chunk[:size]...)
+	}
+}
+
+func TestDeleteAll(t *testing.T) {
+	s, err := NewLocal(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewLocal failed: %v", err)
+	}
+	ctx := context.Background()
+	for _, key := range []string{"media/a", "media/b"} {
+		if err := s.Put(ctx, key, strings.NewReader("x"), 1, "text/plain"); err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+	}
+
+	err = DeleteAll(ctx, s, "media/a", "../escape", "media/b", "media/missing")
+	if err == nil || !strings.Contains(err.Error(), "../escape") {
+		t.Errorf("Expected an error for the invalid key, got %v", err)
+	}
+	for _, key := range []string{"media/a", "media/b"} {
+		if _, err := s.Open(ctx, key); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Expected %s to be deleted, got %v", key, err)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package tracing
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/gskll/chirpy2/internal/database"
+)
+
+// DB wraps the database.DBTX given to database.New so every sqlc query runs
+// in a child span named after the query.
+type DB struct {
+	db database.DBTX
+}
+
+func WrapDB(db database.DBTX) *DB {
+	return &DB{db: db}
+}
+
+func (d *DB) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	ctx, span := startQuerySpan(ctx, query)
+	defer span.End()
+
+	res, err := d.db.ExecContext(ctx, query, args...)
+	recordError(span, err)
+	return res, err
+}
+
+func (d *DB) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	ctx, span := startQuerySpan(ctx, query)
+	defer span.End()
+
+	stmt, err := d.db.PrepareContext(ctx, query)
+	recordError(span, err)
+	return stmt, err
+}
+
+func (d *DB) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	ctx, span := startQuerySpan(ctx, query)
+	defer span.End()
+
+	rows, err := d.db.QueryContext(ctx, query, args...)
+	recordError(span, err)
+	return rows, err
+}
+
+func (d *DB) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	ctx, span := startQuerySpan(ctx, query)
+	defer span.End()
+
+	row := d.db.QueryRowContext(ctx, query, args...)
+	recordError(span, row.Err())
+	return row
+}
+
+func startQuerySpan(ctx context.Context, query string) (context.Context, trace.Span) {
+	return Tracer().Start(ctx, QueryName(query),
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.DBSystemPostgreSQL,
+			semconv.DBQueryText(query),
+		),
+	)
+}
+
+func recordError(span trace.Span, err error) {
+	if err == nil {
+		return
+	}
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
+
+// QueryName extracts the query name from the "-- name: GetChirp :one" header
+// sqlc puts at the top of every generated query.
+func QueryName(query string) string {
+	line, _, _ := strings.Cut(strings.TrimSpace(query), "\n")
+	fields := strings.Fields(line)
+	if len(fields) >= 3 && fields[0] == "--" && fields[1] == "name:" {
+		return fields[2]
+	}
+	return "query"
+}
+
+var _ database.DBTX = (*DB)(nil)
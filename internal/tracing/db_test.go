@@ -0,0 +1,25 @@
+package tracing
+
+import "testing"
+
+func TestQueryName(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		want  string
+	}{
+		{"sqlc one", "-- name: GetChirp :one\nSELECT id FROM chirps\nWHERE id=$1\n", "GetChirp"},
+		{"sqlc exec with leading whitespace", "\n-- name: DeleteUsers :exec\nDELETE FROM users\n", "DeleteUsers"},
+		{"Raw query", "SELECT 1", "query"},
+		{"Other comment", "-- just a comment\nSELECT 1", "query"},
+		{"Empty", "", "query"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := QueryName(tt.query); got != tt.want {
+				t.Errorf("QueryName() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,24 @@
+package user
+
+const (
+	RoleUser      = "user"
+	RoleModerator = "moderator"
+	RoleAdmin     = "admin"
+)
+
+// roleRank orders roles so each one has the permissions of those below it.
+var roleRank = map[string]int{
+	RoleUser:      1,
+	RoleModerator: 2,
+	RoleAdmin:     3,
+}
+
+func ValidRole(role string) bool {
+	_, ok := roleRank[role]
+	return ok
+}
+
+// HasRole reports whether role grants at least the permissions of want.
+func HasRole(role, want string) bool {
+	return ValidRole(role) && roleRank[role] >= roleRank[want]
+}
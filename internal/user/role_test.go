@@ -0,0 +1,27 @@
+package user
+
+import "testing"
+
+func TestHasRole(t *testing.T) {
+	tests := []struct {
+		name string
+		role string
+		want string
+		ok   bool
+	}{
+		{"Admin has admin", RoleAdmin, RoleAdmin, true},
+		{"Admin has moderator", RoleAdmin, RoleModerator, true},
+		{"Moderator has user", RoleModerator, RoleUser, true},
+		{"Moderator lacks admin", RoleModerator, RoleAdmin, false},
+		{"User lacks moderator", RoleUser, RoleModerator, false},
+		{"Unknown role has nothing", "root", RoleUser, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := HasRole(tt.role, tt.want); got != tt.ok {
+				t.Errorf("HasRole(%q, %q) = %v, want %v", tt.role, tt.want, got, tt.ok)
+			}
+		})
+	}
+}
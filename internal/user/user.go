@@ -14,6 +14,8 @@ type User struct {
 	UpdatedAt    time.Time `json:"updated_at"`
 	Email        string    `json:"email"`
 	IsChirpyRed  bool      `json:"is_chirpy_red"`
+	Role         string    `json:"role"`
+	IsProtected  bool      `json:"is_protected"`
 	Token        string    `json:"token,omitempty"`
 	RefreshToken string    `json:"refresh_token,omitempty"`
 }
@@ -25,6 +27,8 @@ func NewUser(dbUser database.User) User {
 		UpdatedAt:   dbUser.UpdatedAt,
 		Email:       dbUser.Email,
 		IsChirpyRed: dbUser.IsChirpyRed,
+		Role:        dbUser.Role,
+		IsProtected: dbUser.IsProtected,
 	}
 }
 
@@ -35,6 +39,8 @@ func NewUserWithTokens(dbUser database.User, token, refreshToken string) User {
 		UpdatedAt:    dbUser.UpdatedAt,
 		Email:        dbUser.Email,
 		IsChirpyRed:  dbUser.IsChirpyRed,
+		Role:         dbUser.Role,
+		IsProtected:  dbUser.IsProtected,
 		Token:        token,
 		RefreshToken: refreshToken,
 	}
@@ -0,0 +1,45 @@
+-- name: CreateAuditEvent :exec
+INSERT INTO audit_events (id, actor_id, action, target_user_id, ip, user_agent, detail, created_at)
+VALUES (
+    gen_random_uuid(),
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    $6,
+    NOW()
+);
+
+-- name: ListAuditEvents :many
+SELECT * FROM audit_events
+WHERE (sqlc.narg('actor_id')::uuid IS NULL OR actor_id = sqlc.narg('actor_id')::uuid)
+  AND (sqlc.narg('target_user_id')::uuid IS NULL OR target_user_id = sqlc.narg('target_user_id')::uuid)
+  AND (@action::text = '' OR action = @action::text)
+  AND (@before_seq::bigint = 0 OR seq < @before_seq::bigint)
+ORDER BY seq DESC
+LIMIT @page_limit;
+
+-- name: ListUserSecurityActivity :many
+SELECT * FROM audit_events
+WHERE (actor_id = @user_id::uuid OR target_user_id = @user_id::uuid)
+  AND (@before_seq::bigint = 0 OR seq < @before_seq::bigint)
+ORDER BY seq DESC
+LIMIT @page_limit;
+
+-- name: FindAuditChainBreaks :many
+SELECT seq FROM (
+    SELECT
+        e.seq,
+        e.hash = audit_event_hash(e) AS hash_ok,
+        e.prev_hash = COALESCE(LAG(e.hash) OVER (ORDER BY e.seq), ''::bytea) AS link_ok,
+        e.seq = COALESCE(LAG(e.seq) OVER (ORDER BY e.seq), 0) + 1 AS seq_ok
+    FROM audit_events e
+) checked
+WHERE NOT (hash_ok AND link_ok AND seq_ok)
+ORDER BY seq;
+
+-- name: GetAuditChainHead :one
+SELECT seq, hash FROM audit_events
+ORDER BY seq DESC
+LIMIT 1;
@@ -1,23 +1,35 @@
 -- name: CreateChirp :one
-INSERT INTO chirps (id, created_at, updated_at, body, user_id)
+INSERT INTO chirps (id, created_at, updated_at, body, user_id, visibility, reply_policy, reply_to_id, mentions)
 VALUES (
     gen_random_uuid(),
     NOW(),
     NOW(),
     $1,
-    $2
+    $2,
+    $3,
+    $4,
+    $5,
+    $6
 )
 RETURNING *;
 
 -- name: GetChirps :many
 SELECT * FROM chirps
+WHERE hidden_at IS NULL
+    AND visibility <> 'unlisted'
+    AND user_id NOT IN (
+        SELECT muted_id FROM user_mutes WHERE user_mutes.user_id = @viewer_id::uuid
+    )
+    AND chirp_visible_to(chirps, @viewer_id::uuid)
 ORDER BY
     CASE WHEN @sort::text = 'asc' THEN created_at END ASC,
     CASE WHEN @sort::text = 'desc' THEN created_at END DESC;
 
 -- name: GetChirpsByAuthor :many
 SELECT * FROM chirps
-WHERE user_id = $1
+WHERE user_id = $1 AND (hidden_at IS NULL OR @include_hidden::bool)
+    AND (visibility <> 'unlisted' OR user_id = @viewer_id::uuid)
+    AND chirp_visible_to(chirps, @viewer_id::uuid)
 ORDER BY
     CASE WHEN @sort::text = 'asc' THEN created_at END ASC,
     CASE WHEN @sort::text = 'desc' THEN created_at END DESC;
@@ -29,3 +41,57 @@ WHERE id=$1;
 -- name: DeleteChirp :exec
 DELETE FROM chirps
 WHERE id=$1;
+
+-- name: CreateChirpAt :one
+INSERT INTO chirps (id, created_at, updated_at, body, user_id)
+VALUES (
+    gen_random_uuid(),
+    @created_at,
+    @created_at,
+    @body,
+    @user_id
+)
+RETURNING *;
+
+-- name: CountChirpsByAuthor :one
+SELECT COUNT(*) FROM chirps
+WHERE user_id = $1;
+
+-- name: HideChirp :one
+UPDATE chirps
+SET hidden_at = NOW(), hidden_reason = $1
+WHERE id = $2
+RETURNING *;
+
+-- name: UnhideChirp :one
+UPDATE chirps
+SET hidden_at = NULL, hidden_reason = NULL
+WHERE id = $1
+RETURNING *;
+
+-- name: ChirpVisibleTo :one
+SELECT chirp_visible_to(chirps, @viewer_id::uuid)::bool AS visible
+FROM chirps
+WHERE id = @id::uuid;
+
+-- name: CanReplyTo :one
+SELECT (
+    user_id = @user_id::uuid
+    OR reply_policy = 'everyone'
+    OR (reply_policy = 'mentioned' AND @user_id::uuid = ANY(mentions))
+    OR (reply_policy = 'followers' AND EXISTS (
+        SELECT 1 FROM follows
+        WHERE follower_id = @user_id::uuid AND followed_id = chirps.user_id AND status = 'accepted'
+    ))
+)::bool AS allowed
+FROM chirps
+WHERE id = @id::uuid;
+
+-- name: LikeChirp :execrows
+INSERT INTO chirp_likes (user_id, chirp_id, created_at)
+VALUES ($1, $2, NOW())
+ON CONFLICT (user_id, chirp_id) DO NOTHING;
+
+-- name: UnlikeChirp :execrows
+DELETE FROM chirp_likes
+WHERE user_id = $1 AND chirp_id = $2;
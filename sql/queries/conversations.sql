@@ -0,0 +1,94 @@
+-- name: CreateConversation :one
+INSERT INTO conversations (id, direct_key, last_message_at, created_at, updated_at)
+VALUES (gen_random_uuid(), $1, NULL, NOW(), NOW())
+ON CONFLICT (direct_key) DO NOTHING
+RETURNING *;
+
+-- name: GetConversationByDirectKey :one
+SELECT * FROM conversations
+WHERE direct_key = $1;
+
+-- name: AddConversationMember :exec
+INSERT INTO conversation_members (conversation_id, user_id, last_read_at, created_at)
+VALUES ($1, $2, NULL, NOW());
+
+-- name: GetConversationForMember :one
+SELECT conversations.id, conversations.direct_key, conversations.last_message_at, conversations.created_at, conversations.updated_at
+FROM conversations
+JOIN conversation_members ON conversation_members.conversation_id = conversations.id
+WHERE conversations.id = $1 AND conversation_members.user_id = $2;
+
+-- name: ListConversationMembers :many
+SELECT user_id FROM conversation_members
+WHERE conversation_id = $1
+ORDER BY created_at, user_id;
+
+-- name: ListConversations :many
+SELECT
+    conversations.id,
+    conversations.direct_key,
+    conversations.last_message_at,
+    conversations.created_at,
+    conversations.updated_at,
+    (
+        SELECT array_agg(members.user_id ORDER BY members.created_at, members.user_id)
+        FROM conversation_members members
+        WHERE members.conversation_id = conversations.id
+    )::uuid[] AS member_ids,
+    (
+        SELECT COUNT(*) FROM direct_messages
+        WHERE direct_messages.conversation_id = conversations.id
+            AND direct_messages.sender_id <> @user_id
+            AND direct_messages.created_at > COALESCE(conversation_members.last_read_at, '-infinity')
+            AND direct_messages.sender_id NOT IN (
+                SELECT blocked_id FROM user_blocks WHERE blocker_id = @user_id
+                UNION ALL
+                SELECT blocker_id FROM user_blocks WHERE blocked_id = @user_id
+            )
+    ) AS unread_count
+FROM conversations
+JOIN conversation_members ON conversation_members.conversation_id = conversations.id
+WHERE conversation_members.user_id = @user_id
+    AND (
+        sqlc.narg('before')::timestamp IS NULL
+        OR COALESCE(conversations.last_message_at, conversations.created_at) < sqlc.narg('before')::timestamp
+    )
+ORDER BY COALESCE(conversations.last_message_at, conversations.created_at) DESC
+LIMIT @max_results;
+
+-- name: ListConversationRecipients :many
+SELECT user_id FROM conversation_members
+WHERE conversation_id = @conversation_id
+    AND user_id <> @sender_id
+    AND user_id NOT IN (
+        SELECT blocked_id FROM user_blocks WHERE blocker_id = @sender_id
+        UNION ALL
+        SELECT blocker_id FROM user_blocks WHERE blocked_id = @sender_id
+    );
+
+-- name: CreateDirectMessage :one
+INSERT INTO direct_messages (id, conversation_id, sender_id, body, created_at)
+VALUES (gen_random_uuid(), $1, $2, $3, NOW())
+RETURNING *;
+
+-- name: TouchConversation :exec
+UPDATE conversations
+SET last_message_at = $2, updated_at = NOW()
+WHERE id = $1;
+
+-- name: ListDirectMessages :many
+SELECT id, conversation_id, sender_id, body, created_at FROM direct_messages
+WHERE conversation_id = @conversation_id
+    AND sender_id NOT IN (
+        SELECT blocked_id FROM user_blocks WHERE blocker_id = @viewer_id
+        UNION ALL
+        SELECT blocker_id FROM user_blocks WHERE blocked_id = @viewer_id
+    )
+    AND (sqlc.narg('before')::timestamp IS NULL OR created_at < sqlc.narg('before')::timestamp)
+ORDER BY created_at DESC
+LIMIT @max_results;
+
+-- name: MarkConversationRead :exec
+UPDATE conversation_members
+SET last_read_at = NOW()
+WHERE conversation_id = $1 AND user_id = $2;
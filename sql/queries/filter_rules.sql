@@ -0,0 +1,22 @@
+-- name: ListFilterRules :many
+SELECT * FROM filter_rules
+ORDER BY pattern;
+
+-- name: UpsertFilterRule :one
+INSERT INTO filter_rules (id, pattern, action, created_by, created_at, updated_at)
+VALUES (
+    gen_random_uuid(),
+    $1,
+    $2,
+    $3,
+    NOW(),
+    NOW()
+)
+ON CONFLICT (pattern) DO UPDATE
+SET action = EXCLUDED.action, updated_at = NOW()
+RETURNING *;
+
+-- name: DeleteFilterRule :one
+DELETE FROM filter_rules
+WHERE id = $1
+RETURNING *;
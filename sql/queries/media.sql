@@ -0,0 +1,81 @@
+-- name: CreateMediaUpload :one
+INSERT INTO media_uploads (id, user_id, chirp_id, position, alt_text, content_type, storage_key, size_bytes, width, height, created_at)
+VALUES ($1, $2, NULL, 0, '', $3, $4, $5, $6, $7, NOW())
+RETURNING *;
+
+-- name: GetMediaUpload :one
+SELECT * FROM media_uploads
+WHERE id = $1;
+
+-- name: AttachMediaUpload :execrows
+UPDATE media_uploads
+SET chirp_id = $1, position = $2, alt_text = $3
+WHERE id = $4 AND user_id = $5 AND chirp_id IS NULL;
+
+-- name: ListChirpMedia :many
+SELECT * FROM media_uploads
+WHERE chirp_id = ANY(@chirp_ids::uuid[])
+ORDER BY chirp_id, position;
+
+-- name: ListUnprocessedMedia :many
+SELECT id FROM media_uploads
+WHERE processed_at IS NULL
+ORDER BY created_at;
+
+-- name: SetMediaProcessed :exec
+UPDATE media_uploads
+SET blurhash = $2, processed_at = NOW()
+WHERE id = $1;
+
+-- name: CreateMediaThumbnail :exec
+INSERT INTO media_thumbnails (media_id, size, content_type, storage_key, size_bytes, width, height, created_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7, NOW())
+ON CONFLICT (media_id, size) DO UPDATE
+SET content_type = EXCLUDED.content_type,
+    storage_key = EXCLUDED.storage_key,
+    size_bytes = EXCLUDED.size_bytes,
+    width = EXCLUDED.width,
+    height = EXCLUDED.height;
+
+-- name: GetMediaThumbnail :one
+SELECT * FROM media_thumbnails
+WHERE media_id = $1 AND size = $2;
+
+-- name: ListMediaThumbnails :many
+SELECT * FROM media_thumbnails
+WHERE media_id = ANY(@media_ids::uuid[])
+ORDER BY media_id, width;
+
+-- name: DeleteUnattachedMedia :many
+-- returns the storage keys of the deleted uploads and their thumbnails, whose
+-- blobs are left to delete
+WITH deleted AS (
+    DELETE FROM media_uploads
+    WHERE chirp_id IS NULL AND created_at < NOW() - make_interval(secs => @max_age_seconds::float8)
+    RETURNING id, storage_key
+)
+SELECT deleted.storage_key FROM deleted
+UNION ALL
+SELECT media_thumbnails.storage_key FROM media_thumbnails
+JOIN deleted ON deleted.id = media_thumbnails.media_id;
+
+-- name: ListChirpMediaKeys :many
+SELECT storage_key FROM media_uploads
+WHERE chirp_id = $1
+UNION ALL
+SELECT media_thumbnails.storage_key FROM media_thumbnails
+JOIN media_uploads ON media_uploads.id = media_thumbnails.media_id
+WHERE media_uploads.chirp_id = $1;
+
+-- name: ListMediaKeys :many
+SELECT storage_key FROM media_uploads
+UNION ALL
+SELECT storage_key FROM media_thumbnails;
+
+-- name: ListUserMediaKeys :many
+SELECT storage_key FROM media_uploads
+WHERE user_id = $1
+UNION ALL
+SELECT media_thumbnails.storage_key FROM media_thumbnails
+JOIN media_uploads ON media_uploads.id = media_thumbnails.media_id
+WHERE media_uploads.user_id = $1;
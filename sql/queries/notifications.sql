@@ -0,0 +1,94 @@
+-- name: CreateNotification :one
+INSERT INTO notifications (id, created_at, updated_at, user_id, actor_id, type, chirp_id, read_at)
+SELECT gen_random_uuid(), NOW(), NOW(), $1, $2, $3, $4, NULL
+WHERE NOT EXISTS (
+    SELECT 1 FROM notification_mutes
+    WHERE notification_mutes.user_id = $1 AND notification_mutes.type = $3
+)
+AND NOT EXISTS (
+    SELECT 1 FROM user_blocks
+    WHERE (blocker_id = $1 AND blocked_id = $2) OR (blocker_id = $2 AND blocked_id = $1)
+)
+AND NOT EXISTS (
+    SELECT 1 FROM user_mutes
+    WHERE user_mutes.user_id = $1 AND user_mutes.muted_id = $2
+)
+RETURNING *;
+
+-- name: GetNotificationGroups :many
+SELECT
+    (array_agg(id ORDER BY created_at DESC))[1]::uuid AS id,
+    type,
+    chirp_id,
+    array_agg(DISTINCT actor_id)::uuid[] AS actor_ids,
+    COUNT(*) AS count,
+    bool_or(read_at IS NULL) AS unread,
+    MAX(created_at)::timestamp AS latest_at
+FROM notifications
+WHERE notifications.user_id = @user_id
+    AND (NOT @unread_only::bool OR read_at IS NULL)
+    AND type NOT IN (
+        SELECT notification_mutes.type FROM notification_mutes
+        WHERE notification_mutes.user_id = @user_id
+    )
+    AND actor_id NOT IN (
+        SELECT blocked_id FROM user_blocks WHERE blocker_id = @user_id
+        UNION ALL
+        SELECT blocker_id FROM user_blocks WHERE blocked_id = @user_id
+        UNION ALL
+        SELECT muted_id FROM user_mutes WHERE user_mutes.user_id = @user_id
+    )
+GROUP BY type, chirp_id, date_trunc('day', created_at)
+HAVING sqlc.narg('before')::timestamp IS NULL OR MAX(created_at) < sqlc.narg('before')::timestamp
+ORDER BY latest_at DESC
+LIMIT @max_results;
+
+-- name: GetNotification :one
+SELECT * FROM notifications
+WHERE id = $1 AND user_id = $2;
+
+-- name: MarkNotificationGroupRead :exec
+UPDATE notifications
+SET read_at = NOW(), updated_at = NOW()
+FROM notifications target
+WHERE target.id = $1
+    AND notifications.user_id = target.user_id
+    AND notifications.type = target.type
+    AND notifications.chirp_id IS NOT DISTINCT FROM target.chirp_id
+    AND date_trunc('day', notifications.created_at) = date_trunc('day', target.created_at)
+    AND notifications.read_at IS NULL;
+
+-- name: MarkAllNotificationsRead :exec
+UPDATE notifications
+SET read_at = NOW(), updated_at = NOW()
+WHERE user_id = $1 AND read_at IS NULL;
+
+-- name: CountUnreadNotifications :one
+SELECT COUNT(*) FROM notifications
+WHERE notifications.user_id = $1
+    AND read_at IS NULL
+    AND type NOT IN (
+        SELECT notification_mutes.type FROM notification_mutes
+        WHERE notification_mutes.user_id = $1
+    )
+    AND actor_id NOT IN (
+        SELECT blocked_id FROM user_blocks WHERE blocker_id = $1
+        UNION ALL
+        SELECT blocker_id FROM user_blocks WHERE blocked_id = $1
+        UNION ALL
+        SELECT muted_id FROM user_mutes WHERE user_mutes.user_id = $1
+    );
+
+-- name: GetNotificationMutes :many
+SELECT * FROM notification_mutes
+WHERE user_id = $1
+ORDER BY type;
+
+-- name: MuteNotificationType :exec
+INSERT INTO notification_mutes (user_id, type, created_at)
+VALUES ($1, $2, NOW())
+ON CONFLICT (user_id, type) DO NOTHING;
+
+-- name: UnmuteNotificationType :exec
+DELETE FROM notification_mutes
+WHERE user_id = $1 AND type = $2;
@@ -0,0 +1,14 @@
+-- name: TakeRateLimitToken :one
+INSERT INTO rate_limit_buckets AS b (key, tokens, allowed, updated_at)
+VALUES (@key, @burst::float8 - 1, true, NOW())
+ON CONFLICT (key) DO UPDATE
+SET
+    allowed = LEAST(@burst::float8, b.tokens + EXTRACT(EPOCH FROM NOW() - b.updated_at)::float8 * @rate::float8) >= 1,
+    tokens = LEAST(@burst::float8, b.tokens + EXTRACT(EPOCH FROM NOW() - b.updated_at)::float8 * @rate::float8)
+        - CASE WHEN LEAST(@burst::float8, b.tokens + EXTRACT(EPOCH FROM NOW() - b.updated_at)::float8 * @rate::float8) >= 1 THEN 1 ELSE 0 END,
+    updated_at = NOW()
+RETURNING tokens, allowed;
+
+-- name: DeleteStaleRateLimitBuckets :exec
+DELETE FROM rate_limit_buckets
+WHERE updated_at < NOW() - make_interval(secs => @max_idle_seconds::float8);
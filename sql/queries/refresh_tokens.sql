@@ -4,7 +4,7 @@ VALUES (
     $1,
     NOW(),
     NOW(),
-    NOW() + INTERVAL '60 days',
+    NOW() + make_interval(secs => @ttl_seconds::float8),
     NULL,
     $2
 );
@@ -17,3 +17,12 @@ WHERE token = $1;
 UPDATE refresh_tokens
 SET revoked_at = NOW(), updated_at = NOW()
 WHERE token = $1;
+
+-- name: RevokeUserRefreshTokens :execrows
+UPDATE refresh_tokens
+SET revoked_at = NOW(), updated_at = NOW()
+WHERE user_id = $1 AND revoked_at IS NULL AND expires_at > NOW();
+
+-- name: CountActiveRefreshTokens :one
+SELECT COUNT(*) FROM refresh_tokens
+WHERE user_id = $1 AND revoked_at IS NULL AND expires_at > NOW();
@@ -0,0 +1,84 @@
+-- name: BlockUser :exec
+INSERT INTO user_blocks (blocker_id, blocked_id, created_at)
+VALUES ($1, $2, NOW())
+ON CONFLICT (blocker_id, blocked_id) DO NOTHING;
+
+-- name: UnblockUser :execrows
+DELETE FROM user_blocks
+WHERE blocker_id = $1 AND blocked_id = $2;
+
+-- name: ListBlockedUsers :many
+SELECT * FROM user_blocks
+WHERE blocker_id = $1
+ORDER BY created_at DESC;
+
+-- name: IsBlocked :one
+SELECT EXISTS (
+    SELECT 1 FROM user_blocks
+    WHERE (blocker_id = @user_id::uuid AND blocked_id = @other_id::uuid)
+        OR (blocker_id = @other_id::uuid AND blocked_id = @user_id::uuid)
+);
+
+-- name: MuteUser :exec
+INSERT INTO user_mutes (user_id, muted_id, created_at)
+VALUES ($1, $2, NOW())
+ON CONFLICT (user_id, muted_id) DO NOTHING;
+
+-- name: UnmuteUser :execrows
+DELETE FROM user_mutes
+WHERE user_id = $1 AND muted_id = $2;
+
+-- name: ListMutedUsers :many
+SELECT * FROM user_mutes
+WHERE user_id = $1
+ORDER BY created_at DESC;
+
+-- name: ListHiddenUsers :many
+SELECT blocked_id AS hidden_id FROM user_blocks WHERE blocker_id = @user_id::uuid
+UNION
+SELECT blocker_id FROM user_blocks WHERE blocked_id = @user_id::uuid
+UNION
+SELECT muted_id FROM user_mutes WHERE user_mutes.user_id = @user_id::uuid;
+
+-- name: FollowUser :one
+INSERT INTO follows (follower_id, followed_id, status, created_at, updated_at)
+VALUES ($1, $2, $3, NOW(), NOW())
+ON CONFLICT (follower_id, followed_id) DO NOTHING
+RETURNING *;
+
+-- name: GetFollow :one
+SELECT * FROM follows
+WHERE follower_id = $1 AND followed_id = $2;
+
+-- name: UnfollowUser :execrows
+DELETE FROM follows
+WHERE follower_id = $1 AND followed_id = $2;
+
+-- name: ListFollowRequests :many
+SELECT * FROM follows
+WHERE followed_id = $1 AND status = 'pending'
+ORDER BY created_at DESC;
+
+-- name: ApproveFollowRequest :execrows
+UPDATE follows
+SET status = 'accepted', updated_at = NOW()
+WHERE follower_id = $1 AND followed_id = $2 AND status = 'pending';
+
+-- name: DenyFollowRequest :execrows
+DELETE FROM follows
+WHERE follower_id = $1 AND followed_id = $2 AND status = 'pending';
+
+-- name: ApproveAllFollowRequests :many
+UPDATE follows
+SET status = 'accepted', updated_at = NOW()
+WHERE followed_id = $1 AND status = 'pending'
+RETURNING follower_id;
+
+-- name: DeleteFollowsBetween :exec
+DELETE FROM follows
+WHERE (follower_id = @user_id::uuid AND followed_id = @other_id::uuid)
+    OR (follower_id = @other_id::uuid AND followed_id = @user_id::uuid);
+
+-- name: ListFollowedUsers :many
+SELECT followed_id FROM follows
+WHERE follower_id = $1 AND status = 'accepted';
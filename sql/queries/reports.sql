@@ -0,0 +1,47 @@
+-- name: CreateReport :one
+INSERT INTO reports (id, reporter_id, reported_user_id, chirp_id, category, comment, status, created_at, updated_at)
+VALUES (
+    gen_random_uuid(),
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    'open',
+    NOW(),
+    NOW()
+)
+RETURNING *;
+
+-- name: GetReport :one
+SELECT * FROM reports
+WHERE id = $1;
+
+-- name: ListReports :many
+SELECT
+    r.*,
+    c.body AS chirp_body,
+    COUNT(*) OVER (PARTITION BY r.reported_user_id, r.chirp_id) AS report_count
+FROM reports r
+LEFT JOIN chirps c ON c.id = r.chirp_id
+WHERE r.status = @status
+  AND (@category::text = '' OR r.category = @category::text)
+  AND (sqlc.narg('reported_user_id')::uuid IS NULL OR r.reported_user_id = sqlc.narg('reported_user_id')::uuid)
+ORDER BY r.created_at ASC
+LIMIT @page_limit OFFSET @page_offset;
+
+-- name: ResolveReport :one
+UPDATE reports
+SET status = $1, resolved_by = $2, resolution_note = $3, resolved_at = NOW(), updated_at = NOW()
+WHERE id = $4 AND status = 'open'
+RETURNING *;
+
+-- name: ResolveChirpReports :execrows
+UPDATE reports
+SET status = 'actioned', resolved_by = $1, resolution_note = $2, resolved_at = NOW(), updated_at = NOW()
+WHERE chirp_id = $3 AND status = 'open';
+
+-- name: ResolveUserReports :execrows
+UPDATE reports
+SET status = 'actioned', resolved_by = $1, resolution_note = $2, resolved_at = NOW(), updated_at = NOW()
+WHERE reported_user_id = $3 AND status = 'open';
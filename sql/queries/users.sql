@@ -22,7 +22,64 @@ SET email = $1, hashed_password = $2, updated_at = NOW()
 WHERE id = $3
 RETURNING *;
 
--- name: UpgradeUser :exec
+-- name: UpgradeUser :execrows
 UPDATE users
 SET is_chirpy_red = true, updated_at = NOW()
 WHERE id = $1;
+
+-- name: GetUser :one
+SELECT * FROM users
+WHERE id = $1;
+
+-- name: ListUsers :many
+SELECT * FROM users
+ORDER BY created_at ASC
+LIMIT $1 OFFSET $2;
+
+-- name: DeleteUser :execrows
+DELETE FROM users
+WHERE id = $1;
+
+-- name: SetUserChirpyRed :exec
+UPDATE users
+SET is_chirpy_red = $1, updated_at = NOW()
+WHERE id = $2;
+
+-- name: UpdateUserPassword :exec
+UPDATE users
+SET hashed_password = $1, updated_at = NOW()
+WHERE id = $2;
+
+-- name: SearchUsers :many
+SELECT * FROM users
+WHERE (@query::text = '' OR email ILIKE '%' || @query::text || '%')
+  AND (@role::text = '' OR role = @role::text)
+ORDER BY created_at ASC
+LIMIT @page_limit OFFSET @page_offset;
+
+-- name: SetUserProtected :one
+UPDATE users
+SET is_protected = $1, updated_at = NOW()
+WHERE id = $2
+RETURNING *;
+
+-- name: SetUserRole :exec
+UPDATE users
+SET role = $1, updated_at = NOW()
+WHERE id = $2;
+
+-- name: SuspendUser :one
+UPDATE users
+SET suspended_at = NOW(), suspended_reason = $1, updated_at = NOW()
+WHERE id = $2
+RETURNING *;
+
+-- name: UnsuspendUser :one
+UPDATE users
+SET suspended_at = NULL, suspended_reason = NULL, updated_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: CountUsersByID :one
+SELECT COUNT(*) FROM users
+WHERE id = ANY(@ids::uuid[]);
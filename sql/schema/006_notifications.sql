@@ -0,0 +1,24 @@
+-- +goose Up
+CREATE TABLE notifications (
+    id UUID NOT NULL PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users ON DELETE CASCADE,
+    actor_id UUID NOT NULL REFERENCES users ON DELETE CASCADE,
+    type TEXT NOT NULL,
+    chirp_id UUID REFERENCES chirps ON DELETE CASCADE,
+    read_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX notifications_user_id_created_at_idx ON notifications (user_id, created_at DESC);
+
+CREATE TABLE notification_mutes (
+    user_id UUID NOT NULL REFERENCES users ON DELETE CASCADE,
+    type TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (user_id, type)
+);
+
+-- +goose Down
+DROP TABLE notification_mutes;
+DROP TABLE notifications;
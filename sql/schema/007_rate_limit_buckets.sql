@@ -0,0 +1,12 @@
+-- +goose Up
+CREATE UNLOGGED TABLE rate_limit_buckets (
+    key TEXT NOT NULL PRIMARY KEY,
+    tokens DOUBLE PRECISION NOT NULL,
+    allowed BOOL NOT NULL,
+    updated_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX rate_limit_buckets_updated_at_idx ON rate_limit_buckets (updated_at);
+
+-- +goose Down
+DROP TABLE rate_limit_buckets;
@@ -0,0 +1,24 @@
+-- +goose Up
+ALTER TABLE users
+ADD COLUMN role TEXT NOT NULL DEFAULT 'user' CHECK (role IN ('user', 'moderator', 'admin')),
+ADD COLUMN suspended_at TIMESTAMP,
+ADD COLUMN suspended_reason TEXT;
+
+CREATE TABLE audit_events (
+    id UUID NOT NULL PRIMARY KEY,
+    actor_id UUID,
+    action TEXT NOT NULL,
+    target_user_id UUID,
+    detail JSONB NOT NULL DEFAULT '{}',
+    created_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX audit_events_target_user_id_idx ON audit_events (target_user_id, created_at);
+
+-- +goose Down
+DROP TABLE audit_events;
+
+ALTER TABLE users
+DROP COLUMN IF EXISTS suspended_reason,
+DROP COLUMN IF EXISTS suspended_at,
+DROP COLUMN IF EXISTS role;
@@ -0,0 +1,105 @@
+-- +goose Up
+ALTER TABLE audit_events
+ADD COLUMN seq BIGINT,
+ADD COLUMN ip TEXT,
+ADD COLUMN user_agent TEXT,
+ADD COLUMN prev_hash BYTEA,
+ADD COLUMN hash BYTEA;
+
+-- audit_event_hash chains an event to the one before it, changing any field of
+-- an event changes its hash and breaks every link after it.
+-- +goose StatementBegin
+CREATE FUNCTION audit_event_hash(e audit_events) RETURNS BYTEA AS $$
+    SELECT sha256(e.prev_hash || convert_to(concat_ws(
+        E'\x1f',
+        e.seq::text,
+        COALESCE(e.actor_id::text, ''),
+        e.action,
+        COALESCE(e.target_user_id::text, ''),
+        COALESCE(e.ip, ''),
+        COALESCE(e.user_agent, ''),
+        e.detail::text,
+        to_char(e.created_at, 'YYYY-MM-DD"T"HH24:MI:SS.US')
+    ), 'UTF8'))
+$$ LANGUAGE sql STABLE;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+DO $$
+DECLARE
+    event RECORD;
+    prev BYTEA := '';
+    n BIGINT := 0;
+BEGIN
+    FOR event IN SELECT id FROM audit_events ORDER BY created_at, id LOOP
+        n := n + 1;
+        UPDATE audit_events SET seq = n, prev_hash = prev WHERE id = event.id;
+        UPDATE audit_events e SET hash = audit_event_hash(e) WHERE id = event.id
+        RETURNING hash INTO prev;
+    END LOOP;
+END
+$$;
+-- +goose StatementEnd
+
+ALTER TABLE audit_events
+ALTER COLUMN seq SET NOT NULL,
+ALTER COLUMN prev_hash SET NOT NULL,
+ALTER COLUMN hash SET NOT NULL,
+ADD CONSTRAINT audit_events_seq_key UNIQUE (seq);
+
+CREATE INDEX audit_events_actor_id_idx ON audit_events (actor_id, seq);
+CREATE INDEX audit_events_action_idx ON audit_events (action, seq);
+
+-- writers are serialized on an advisory lock so each event links to the
+-- previous one, whoever inserts it
+-- +goose StatementBegin
+CREATE FUNCTION audit_events_chain() RETURNS trigger AS $$
+DECLARE
+    head audit_events%ROWTYPE;
+BEGIN
+    PERFORM pg_advisory_xact_lock(hashtext('audit_events_chain'));
+    SELECT * INTO head FROM audit_events ORDER BY seq DESC LIMIT 1;
+    NEW.seq := COALESCE(head.seq, 0) + 1;
+    NEW.prev_hash := COALESCE(head.hash, ''::bytea);
+    NEW.hash := audit_event_hash(NEW);
+    RETURN NEW;
+END
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE FUNCTION audit_events_immutable() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_events is append-only';
+END
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+CREATE TRIGGER audit_events_chain BEFORE INSERT ON audit_events
+FOR EACH ROW EXECUTE FUNCTION audit_events_chain();
+
+CREATE TRIGGER audit_events_immutable BEFORE UPDATE OR DELETE ON audit_events
+FOR EACH ROW EXECUTE FUNCTION audit_events_immutable();
+
+CREATE TRIGGER audit_events_no_truncate BEFORE TRUNCATE ON audit_events
+FOR EACH STATEMENT EXECUTE FUNCTION audit_events_immutable();
+
+-- +goose Down
+DROP TRIGGER audit_events_no_truncate ON audit_events;
+DROP TRIGGER audit_events_immutable ON audit_events;
+DROP TRIGGER audit_events_chain ON audit_events;
+DROP FUNCTION audit_events_immutable();
+DROP FUNCTION audit_events_chain();
+
+DROP FUNCTION audit_event_hash(audit_events);
+
+DROP INDEX audit_events_action_idx;
+DROP INDEX audit_events_actor_id_idx;
+
+ALTER TABLE audit_events
+DROP CONSTRAINT audit_events_seq_key,
+DROP COLUMN hash,
+DROP COLUMN prev_hash,
+DROP COLUMN user_agent,
+DROP COLUMN ip,
+DROP COLUMN seq;
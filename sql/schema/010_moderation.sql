@@ -0,0 +1,40 @@
+-- +goose Up
+ALTER TABLE chirps
+ADD COLUMN hidden_at TIMESTAMP,
+ADD COLUMN hidden_reason TEXT;
+
+CREATE TABLE reports (
+    id UUID NOT NULL PRIMARY KEY,
+    reporter_id UUID NOT NULL REFERENCES users ON DELETE CASCADE,
+    reported_user_id UUID NOT NULL REFERENCES users ON DELETE CASCADE,
+    chirp_id UUID REFERENCES chirps ON DELETE SET NULL,
+    category TEXT NOT NULL CHECK (
+        category IN ('spam', 'harassment', 'hate', 'violence', 'sexual', 'self_harm', 'impersonation', 'other')
+    ),
+    comment TEXT NOT NULL DEFAULT '',
+    status TEXT NOT NULL DEFAULT 'open' CHECK (status IN ('open', 'actioned', 'dismissed')),
+    resolved_by UUID REFERENCES users ON DELETE SET NULL,
+    resolved_at TIMESTAMP,
+    resolution_note TEXT,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL
+);
+
+-- a user can only have one open report per chirp, or per user for reports
+-- that aren't about a chirp
+CREATE UNIQUE INDEX reports_open_key ON reports (
+    reporter_id,
+    reported_user_id,
+    COALESCE(chirp_id, '00000000-0000-0000-0000-000000000000')
+) WHERE status = 'open';
+
+CREATE INDEX reports_status_created_at_idx ON reports (status, created_at);
+CREATE INDEX reports_reported_user_id_idx ON reports (reported_user_id);
+CREATE INDEX reports_chirp_id_idx ON reports (chirp_id);
+
+-- +goose Down
+DROP TABLE reports;
+
+ALTER TABLE chirps
+DROP COLUMN IF EXISTS hidden_reason,
+DROP COLUMN IF EXISTS hidden_at;
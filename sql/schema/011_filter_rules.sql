@@ -0,0 +1,29 @@
+-- +goose Up
+CREATE TABLE filter_rules (
+    id UUID NOT NULL PRIMARY KEY,
+    pattern TEXT NOT NULL UNIQUE,
+    action TEXT NOT NULL CHECK (action IN ('mask', 'reject', 'flag')),
+    created_by UUID REFERENCES users ON DELETE SET NULL,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL
+);
+
+-- chirps flagged by the filter are reported without a reporter
+ALTER TABLE reports
+ALTER COLUMN reporter_id DROP NOT NULL,
+DROP CONSTRAINT reports_category_check,
+ADD CONSTRAINT reports_category_check CHECK (
+    category IN ('spam', 'harassment', 'hate', 'violence', 'sexual', 'self_harm', 'impersonation', 'other', 'filter')
+);
+
+-- +goose Down
+DELETE FROM reports WHERE reporter_id IS NULL OR category = 'filter';
+
+ALTER TABLE reports
+ALTER COLUMN reporter_id SET NOT NULL,
+DROP CONSTRAINT reports_category_check,
+ADD CONSTRAINT reports_category_check CHECK (
+    category IN ('spam', 'harassment', 'hate', 'violence', 'sexual', 'self_harm', 'impersonation', 'other')
+);
+
+DROP TABLE filter_rules;
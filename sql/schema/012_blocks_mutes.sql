@@ -0,0 +1,23 @@
+-- +goose Up
+CREATE TABLE user_blocks (
+    blocker_id UUID NOT NULL REFERENCES users ON DELETE CASCADE,
+    blocked_id UUID NOT NULL REFERENCES users ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (blocker_id, blocked_id),
+    CHECK (blocker_id <> blocked_id)
+);
+
+-- blocks apply both ways, so they are looked up from either side
+CREATE INDEX user_blocks_blocked_id_idx ON user_blocks (blocked_id);
+
+CREATE TABLE user_mutes (
+    user_id UUID NOT NULL REFERENCES users ON DELETE CASCADE,
+    muted_id UUID NOT NULL REFERENCES users ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (user_id, muted_id),
+    CHECK (user_id <> muted_id)
+);
+
+-- +goose Down
+DROP TABLE user_mutes;
+DROP TABLE user_blocks;
@@ -0,0 +1,22 @@
+-- +goose Up
+ALTER TABLE users
+ADD COLUMN is_protected BOOLEAN NOT NULL DEFAULT false;
+
+-- a follow of a protected account is pending until its owner approves it
+CREATE TABLE follows (
+    follower_id UUID NOT NULL REFERENCES users ON DELETE CASCADE,
+    followed_id UUID NOT NULL REFERENCES users ON DELETE CASCADE,
+    status TEXT NOT NULL CHECK (status IN ('pending', 'accepted')),
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (follower_id, followed_id),
+    CHECK (follower_id <> followed_id)
+);
+
+CREATE INDEX follows_followed_id_status_idx ON follows (followed_id, status);
+
+-- +goose Down
+DROP TABLE follows;
+
+ALTER TABLE users
+DROP COLUMN IF EXISTS is_protected;
@@ -0,0 +1,47 @@
+-- +goose Up
+ALTER TABLE chirps
+ADD COLUMN visibility TEXT NOT NULL DEFAULT 'public' CHECK (visibility IN ('public', 'unlisted', 'followers', 'mentioned')),
+ADD COLUMN reply_policy TEXT NOT NULL DEFAULT 'everyone' CHECK (reply_policy IN ('everyone', 'followers', 'mentioned')),
+ADD COLUMN reply_to_id UUID REFERENCES chirps ON DELETE SET NULL,
+ADD COLUMN mentions UUID[] NOT NULL DEFAULT '{}';
+
+CREATE INDEX chirps_reply_to_id_idx ON chirps (reply_to_id);
+
+-- chirp_visible_to is whether viewer may read c, anonymous viewers are the
+-- nil uuid. Blocks apply either way, mentioned users can always read the
+-- chirp and protected accounts are otherwise limited to their followers.
+-- Listings also leave out unlisted chirps and muted authors.
+-- +goose StatementBegin
+CREATE FUNCTION chirp_visible_to(c chirps, viewer UUID) RETURNS BOOLEAN AS $$
+    SELECT c.user_id = viewer OR (
+        NOT EXISTS (
+            SELECT 1 FROM user_blocks
+            WHERE (blocker_id = c.user_id AND blocked_id = viewer)
+                OR (blocker_id = viewer AND blocked_id = c.user_id)
+        )
+        AND (
+            viewer = ANY(c.mentions)
+            OR CASE c.visibility
+                WHEN 'mentioned' THEN false
+                WHEN 'followers' THEN EXISTS (
+                    SELECT 1 FROM follows
+                    WHERE follower_id = viewer AND followed_id = c.user_id AND status = 'accepted'
+                )
+                ELSE NOT (SELECT is_protected FROM users WHERE users.id = c.user_id) OR EXISTS (
+                    SELECT 1 FROM follows
+                    WHERE follower_id = viewer AND followed_id = c.user_id AND status = 'accepted'
+                )
+            END
+        )
+    )
+$$ LANGUAGE sql STABLE;
+-- +goose StatementEnd
+
+-- +goose Down
+DROP FUNCTION chirp_visible_to(chirps, UUID);
+
+ALTER TABLE chirps
+DROP COLUMN IF EXISTS mentions,
+DROP COLUMN IF EXISTS reply_to_id,
+DROP COLUMN IF EXISTS reply_policy,
+DROP COLUMN IF EXISTS visibility;
@@ -0,0 +1,35 @@
+-- +goose Up
+CREATE TABLE conversations (
+    id UUID NOT NULL PRIMARY KEY,
+    -- direct_key is the sorted pair of member ids of a 1:1 conversation, so
+    -- two users only ever have one. Groups have none
+    direct_key TEXT UNIQUE,
+    last_message_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL
+);
+
+CREATE TABLE conversation_members (
+    conversation_id UUID NOT NULL REFERENCES conversations ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users ON DELETE CASCADE,
+    last_read_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (conversation_id, user_id)
+);
+
+CREATE INDEX conversation_members_user_id_idx ON conversation_members (user_id);
+
+CREATE TABLE direct_messages (
+    id UUID NOT NULL PRIMARY KEY,
+    conversation_id UUID NOT NULL REFERENCES conversations ON DELETE CASCADE,
+    sender_id UUID NOT NULL REFERENCES users ON DELETE CASCADE,
+    body TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX direct_messages_conversation_id_created_at_idx ON direct_messages (conversation_id, created_at DESC);
+
+-- +goose Down
+DROP TABLE direct_messages;
+DROP TABLE conversation_members;
+DROP TABLE conversations;
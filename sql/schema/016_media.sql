@@ -0,0 +1,21 @@
+-- +goose Up
+-- media_uploads are re-encoded images, attached to at most one chirp. Blobs
+-- live in the media storage under storage_key
+CREATE TABLE media_uploads (
+    id UUID NOT NULL PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users ON DELETE CASCADE,
+    chirp_id UUID REFERENCES chirps ON DELETE CASCADE,
+    position INTEGER NOT NULL DEFAULT 0,
+    alt_text TEXT NOT NULL DEFAULT '',
+    content_type TEXT NOT NULL,
+    storage_key TEXT NOT NULL,
+    size_bytes BIGINT NOT NULL,
+    width INTEGER NOT NULL,
+    height INTEGER NOT NULL,
+    created_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX media_uploads_chirp_id_idx ON media_uploads (chirp_id, position);
+
+-- +goose Down
+DROP TABLE media_uploads;
@@ -0,0 +1,27 @@
+-- +goose Up
+-- processed_at is set once an upload's thumbnails and blurhash are generated
+ALTER TABLE media_uploads
+    ADD COLUMN blurhash TEXT NOT NULL DEFAULT '',
+    ADD COLUMN processed_at TIMESTAMP;
+
+CREATE INDEX media_uploads_unprocessed_idx ON media_uploads (created_at) WHERE processed_at IS NULL;
+
+-- media_thumbnails are scaled down copies of an upload, one per size
+CREATE TABLE media_thumbnails (
+    media_id UUID NOT NULL REFERENCES media_uploads ON DELETE CASCADE,
+    size TEXT NOT NULL,
+    content_type TEXT NOT NULL,
+    storage_key TEXT NOT NULL,
+    size_bytes BIGINT NOT NULL,
+    width INTEGER NOT NULL,
+    height INTEGER NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (media_id, size)
+);
+
+-- +goose Down
+DROP TABLE media_thumbnails;
+DROP INDEX media_uploads_unprocessed_idx;
+ALTER TABLE media_uploads
+    DROP COLUMN processed_at,
+    DROP COLUMN blurhash;